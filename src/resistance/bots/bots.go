@@ -0,0 +1,43 @@
+// Package bots has the strategies bot players can be played by. Importing
+// it registers them with the game package.
+package bots
+
+import (
+	"math/rand"
+	"resistance/game"
+	"time"
+)
+
+const (
+	RANDOM_STRATEGY    = "Random"
+	HEURISTIC_STRATEGY = "Heuristic"
+)
+
+func init() {
+	rand.Seed(time.Now().UnixNano())
+	game.RegisterStrategy(RANDOM_STRATEGY, func() game.Strategy { return new(Random) })
+	game.RegisterStrategy(HEURISTIC_STRATEGY, func() game.Strategy { return new(Heuristic) })
+}
+
+// isSpy determines whether the bot the state is for is a spy.
+func isSpy(state *game.GameState) bool {
+	return state.You.Role == game.ROLE_SPY_NAME
+}
+
+// getUserIds gets the user ids of the given players.
+func getUserIds(players []game.PlayerState) []int {
+	userIds := make([]int, len(players))
+	for index, player := range players {
+		userIds[index] = player.UserId
+	}
+	return userIds
+}
+
+// shuffle gives the players in a random order.
+func shuffle(players []game.PlayerState) []game.PlayerState {
+	shuffled := make([]game.PlayerState, len(players))
+	for index, randomIndex := range rand.Perm(len(players)) {
+		shuffled[index] = players[randomIndex]
+	}
+	return shuffled
+}
@@ -0,0 +1,133 @@
+package bots
+
+import (
+	"resistance/game"
+	"sort"
+)
+
+// Heuristic plays the way a careful beginner would. As resistance it
+// suspects everyone who was on a failed mission and keeps them off its
+// teams. As a spy it gets a spy onto every team and fails missions, but
+// never with more fail cards than needed.
+type Heuristic struct {
+}
+
+func (strategy *Heuristic) Name() string {
+	return HEURISTIC_STRATEGY
+}
+
+// ProposeTeam puts the bot itself on the team, then the players it trusts
+// the most. A spy trusts the resistance the most, so it ends up the only spy.
+func (strategy *Heuristic) ProposeTeam(state *game.GameState) []int {
+	suspicion := getSuspicion(state)
+	spies := getSpies(state)
+
+	candidates := shuffle(state.Players)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Username == state.Leader || candidates[j].Username == state.Leader {
+			return candidates[i].Username == state.Leader
+		}
+		if isSpy(state) && spies[candidates[i].Username] != spies[candidates[j].Username] {
+			return !spies[candidates[i].Username]
+		}
+		return suspicion[candidates[i].Username] < suspicion[candidates[j].Username]
+	})
+	return getUserIds(candidates[:state.TeamSize])
+}
+
+// Vote approves a team with a spy on it as a spy. As resistance it
+// approves a team only if no one on it is more suspect than the players
+// left off it.
+func (strategy *Heuristic) Vote(state *game.GameState) bool {
+	if isSpy(state) {
+		spies := getSpies(state)
+		for _, username := range state.Team {
+			if spies[username] {
+				return true
+			}
+		}
+		return false
+	}
+
+	suspicion := getSuspicion(state)
+	onTeam := make(map[string]bool)
+	mostSuspectOnTeam := 0.0
+	for _, username := range state.Team {
+		onTeam[username] = true
+		if suspicion[username] > mostSuspectOnTeam {
+			mostSuspectOnTeam = suspicion[username]
+		}
+	}
+	for _, player := range state.Players {
+		if !onTeam[player.Username] && suspicion[player.Username] < mostSuspectOnTeam {
+			return false
+		}
+	}
+	return true
+}
+
+// MissionOutcome fails the mission as a spy, unless another spy on the team
+// will already do it. When a mission needs two fails, every spy fails it.
+func (strategy *Heuristic) MissionOutcome(state *game.GameState) bool {
+	if !isSpy(state) {
+		return true
+	}
+
+	if requiresTwoFails(state) {
+		return false
+	}
+
+	// The spy first in alphabetical order fails it, so only one does
+	spies := getSpies(state)
+	for _, username := range state.Team {
+		if spies[username] && username < getUsername(state) {
+			return true
+		}
+	}
+	return false
+}
+
+// getSuspicion works out how suspect every player is from the failed
+// missions they were on. Each fail card is shared among the team.
+func getSuspicion(state *game.GameState) map[string]float64 {
+	suspicion := make(map[string]float64)
+	for _, mission := range state.Missions {
+		numFails, _ := mission["numFails"].(int)
+		team, _ := mission["team"].([]string)
+		if numFails <= 0 || len(team) == 0 {
+			continue
+		}
+		for _, username := range team {
+			suspicion[username] += float64(numFails) / float64(len(team))
+		}
+	}
+	return suspicion
+}
+
+// getSpies gets the spies the bot knows of, itself included.
+func getSpies(state *game.GameState) map[string]bool {
+	spies := make(map[string]bool)
+	if isSpy(state) {
+		spies[getUsername(state)] = true
+		for _, username := range state.You.Spies {
+			spies[username] = true
+		}
+	}
+	return spies
+}
+
+// getUsername gets the username of the bot the state is for.
+func getUsername(state *game.GameState) string {
+	for _, player := range state.Players {
+		if player.UserId == state.You.UserId {
+			return player.Username
+		}
+	}
+	return ""
+}
+
+// requiresTwoFails determines whether the current mission takes two fail
+// cards to fail.
+func requiresTwoFails(state *game.GameState) bool {
+	return len(state.Players) >= 7 && state.MissionNum == 4
+}
@@ -0,0 +1,30 @@
+package bots
+
+import (
+	"math/rand"
+	"resistance/game"
+)
+
+// Random picks teams, votes and, as a spy, plays cards at random. Good for
+// filling a seat, not for winning.
+type Random struct {
+}
+
+func (strategy *Random) Name() string {
+	return RANDOM_STRATEGY
+}
+
+func (strategy *Random) ProposeTeam(state *game.GameState) []int {
+	return getUserIds(shuffle(state.Players)[:state.TeamSize])
+}
+
+func (strategy *Random) Vote(state *game.GameState) bool {
+	return rand.Intn(2) == 0
+}
+
+func (strategy *Random) MissionOutcome(state *game.GameState) bool {
+	if !isSpy(state) {
+		return true
+	}
+	return rand.Intn(2) == 0
+}
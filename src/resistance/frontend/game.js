@@ -11,60 +11,213 @@ function parseUrlParams() {
 function handleMessage(message) {
   object = JSON.parse(message);
 
+  // answer heartbeats so the proxy knows we're still here
+  if (object.message == Messages.PING) {
+    socket.send(JSON.stringify({"message": Messages.PONG}));
+    return;
+  }
+
+  // messages published to the game are numbered. Skip any we've already
+  // seen, which can happen while catching up after a reconnect.
+  if ("seq" in object) {
+    if (object.epoch != epoch) {
+      // the server started numbering over, after a restart or once it
+      // forgot the game's messages, so we may have missed some
+      var firstEpoch = epoch == null;
+      epoch = object.epoch;
+      lastSeq = 0;
+      if (!firstEpoch) {
+        catchUp();
+      }
+    }
+    if (object.seq <= lastSeq) {
+      return;
+    }
+    lastSeq = object.seq;
+  }
+
   handleAnyErrors(object);
 
+  if (spectating) {
+    handleSpectatorMessage(object);
+    return;
+  }
+
   switch(object.message) {
-    case "playerConnectSuccessful":
+    case Messages.PROTOCOL_ERROR:
+      handleProtocolError(object);
+      break;
+    case Messages.PLAYER_CONNECT_SUCCESSFUL:
       handlePlayerConnectSuccessful(object);
       break;
-    case "players":
+    case Messages.PLAYERS:
       handlePlayers(object);
       break;
-    case "gameStarted":
+    case Messages.GAME_STARTED:
       handleGameStart(object);
+      // roles have just been handed out
+      sendResistanceMessage(Messages.QUERY_GAME_STATE);
       break;
-    case "queryRoleResult":
+    case Messages.QUERY_ROLE_RESULT:
       handleQueryRoleResult(object);
       break;
-    case "missionPreparation":
+    case Messages.MISSION_PREPARATION:
       handleMissionPreparation(object);
       break;
-    case "queryLeaderResult":
+    case Messages.QUERY_LEADER_RESULT:
       handleQueryLeaderResult(object);
       break;
-    case "teamApproval":
+    case Messages.TEAM_APPROVAL:
       handleTeamApproval(object);
       break;
-    case "approveTeamUpdate":
+    case Messages.APPROVE_TEAM_UPDATE:
       handleApproveTeamUpdate(object);
       break;
-    case "missionStarted":
+    case Messages.MISSION_STARTED:
       handleMissionStarted(object);
       break;
-    case "queryIsOnMissionResult":
+    case Messages.QUERY_IS_ON_MISSION_RESULT:
       handleQueryIsOnMissionResult(object);
       break;
-    case "gameOver":
+    case Messages.GAME_OVER:
       handleGameOver(object);
       break;
-    case "missions":
+    case Messages.MISSIONS:
       handleMissions(object);
       break;
-    case "gameResume":
+    case Messages.GAME_RESUME:
       handleGameResume(object);
       break;
-    case "gamePause":
+    case Messages.GAME_PAUSE:
       handleGamePause(object);
       break;
-    case "showText":
+    case Messages.SHOW_TEXT:
       handleShowText(object);
       break;
+    case Messages.RESUME_RESULT:
+      handleResumeResult(object);
+      break;
+    case Messages.GAME_STATE:
+      handleGameState(object.gameState);
+      break;
+    case Messages.CHAT:
+      handleChat(object);
+      break;
+    case Messages.TIMER:
+      handleTimer(object.timeLeft);
+      break;
+    case Messages.GRACE_PERIOD_OVER:
+      handleGracePeriodOver(object.players);
+      break;
+    case Messages.PLAYER_REPLACED:
+      handlePlayerReplaced(object);
+      break;
+    case Messages.GAME_ABANDONED:
+      handleGameAbandoned(object);
+      break;
+    case Messages.KICKED:
+      handleKicked(object);
+      break;
     default:
       // used for debugging
       // alert("Unknown message: " + object.message);
   }
 }
 
+// handleSpectatorMessage handles the messages for someone watching the game.
+// Spectators can't do anything, so whenever the game moves on they just
+// redraw it from the state.
+function handleSpectatorMessage(object) {
+  switch(object.message) {
+    case Messages.PROTOCOL_ERROR:
+      handleProtocolError(object);
+      break;
+    case Messages.PLAYER_CONNECT_SUCCESSFUL:
+      clearActionDiv();
+      document.getElementById("action").appendChild(document.createTextNode("You are watching this game."));
+      handleGameState(object.gameState);
+      if (lastSeq > 0) {
+        sendResistanceMessage(Messages.RESUME, {"lastSeq": lastSeq, "epoch": epoch});
+      }
+      break;
+    case Messages.PLAYERS:
+      handlePlayers(object);
+      break;
+    case Messages.APPROVE_TEAM_UPDATE:
+      handleApproveTeamUpdate(object);
+      break;
+    case Messages.GAME_OVER:
+      handleGameOver(object);
+      break;
+    case Messages.MISSIONS:
+      handleMissions(object);
+      break;
+    case Messages.GAME_RESUME:
+      handleGameResume(object);
+      break;
+    case Messages.GAME_PAUSE:
+      handleGamePause(object);
+      break;
+    case Messages.SHOW_TEXT:
+      handleShowText(object);
+      break;
+    case Messages.RESUME_RESULT:
+      handleResumeResult(object);
+      break;
+    case Messages.GAME_STATE:
+      handleGameState(object.gameState);
+      break;
+    case Messages.CHAT:
+      handleChat(object);
+      break;
+    case Messages.TIMER:
+      handleTimer(object.timeLeft);
+      break;
+    case Messages.GRACE_PERIOD_OVER:
+      handleGracePeriodOver(object.players);
+      break;
+    case Messages.PLAYER_REPLACED:
+      handlePlayerReplaced(object);
+      break;
+    case Messages.GAME_ABANDONED:
+      handleGameAbandoned(object);
+      break;
+    case Messages.GAME_STARTED:
+    case Messages.MISSION_PREPARATION:
+    case Messages.TEAM_APPROVAL:
+    case Messages.MISSION_STARTED:
+      sendResistanceMessage(Messages.QUERY_GAME_STATE);
+      break;
+  }
+}
+
+// handleSpectators lists who is watching the game.
+function handleSpectators(spectators) {
+  var spectatorsSpan = document.getElementById("spectators");
+  spectatorsSpan.innerHTML = "";
+  if (spectators && spectators.length > 0) {
+    spectatorsSpan.appendChild(document.createTextNode(spectators.join(", ")));
+  } else {
+    spectatorsSpan.appendChild(document.createTextNode("no one"));
+  }
+}
+
+// handleInviteCode shows the host of a private game the link to invite
+// people with. Nobody else gets the code.
+function handleInviteCode(inviteCode) {
+  var inviteInfo = document.getElementById("inviteInfo");
+  if (!inviteCode) {
+    inviteInfo.style.display = "none";
+    return;
+  }
+
+  var inviteLink = document.getElementById("inviteLink");
+  inviteLink.href = "/game.html?gameId=" + encodeURIComponent(gameId) + "&invite=" + inviteCode;
+  inviteLink.innerHTML = "";
+  inviteLink.appendChild(document.createTextNode(inviteLink.href));
+  inviteInfo.style.display = "block";
+}
+
 function handleAnyErrors(parsedMessage) {
   var div = document.getElementById("alerts");
   if ("errorMessage" in parsedMessage) {
@@ -75,8 +228,33 @@ function handleAnyErrors(parsedMessage) {
 }
 
 function handlePlayerConnectSuccessful(parsedMessage) {
+  myUserId = parsedMessage.userId;
+  isHost = parsedMessage.isHost;
+  botStrategies = parsedMessage.strategies || [];
+  // we may be reconnecting, so start from scratch
+  drawLobby(parsedMessage.gameState);
+
+  gameInProgress = parsedMessage.updateGameProgress;
+  if (parsedMessage.gameState) {
+    handleGameState(parsedMessage.gameState);
+  }
+  if (lastSeq > 0) {
+    // we've been here before, ask for just what we missed
+    sendResistanceMessage(Messages.RESUME, {"lastSeq": lastSeq, "epoch": epoch});
+  } else if (parsedMessage.updateGameProgress) {
+    sendResistanceMessage(Messages.UPDATE_GAME_PROGRESS);
+  }
+
+  sendResistanceMessage(Messages.GET_PLAYERS);
+}
+
+// drawLobby draws what there is to do in the lobby: the host's controls
+// for the host, and waiting for everyone else. Drawn again whenever the
+// host changes.
+function drawLobby(gameState) {
+  clearActionDiv();
   var actionDiv = document.getElementById("action");
-  if (parsedMessage.isHost) {
+  if (isHost) {
     startButton = document.createElement("input");
     startButton.type = "button";
     startButton.value = "Start";
@@ -86,25 +264,237 @@ function handlePlayerConnectSuccessful(parsedMessage) {
       startGame();
     }
     actionDiv.appendChild(startButton);
+    addBotControls(actionDiv, botStrategies);
+    addSpyChatControl(actionDiv, gameState && gameState.spyChat);
+    addLockLobbyControl(actionDiv, gameState && gameState.locked);
+    if (gameState) {
+      addTimeLimitControls(actionDiv, gameState.timeLimits);
+    }
   } else {
     actionDiv.appendChild(document.createTextNode("Waiting for host to start game..."));
   }
+}
+
+// addBotControls lets the host fill empty seats in the lobby with bots.
+function addBotControls(actionDiv, strategies) {
+  if (!strategies || strategies.length == 0) {
+    return;
+  }
+
+  var botControls = document.createElement("span");
+  botControls.id = "botControls";
+
+  var strategySelect = document.createElement("select");
+  for (var i = 0; i < strategies.length; i++) {
+    var option = document.createElement("option");
+    option.value = strategies[i];
+    option.text = strategies[i];
+    strategySelect.appendChild(option);
+  }
+  botControls.appendChild(strategySelect);
+
+  var addBotButton = document.createElement("input");
+  addBotButton.type = "button";
+  addBotButton.value = "Add bot";
+  addBotButton.onclick = function () {
+    sendResistanceMessage(Messages.ADD_BOT, {"strategy": strategySelect.value});
+  }
+  botControls.appendChild(addBotButton);
+
+  actionDiv.appendChild(botControls);
+}
+
+// addSpyChatControl lets the host decide whether the spies get a chat
+// channel of their own.
+function addSpyChatControl(actionDiv, enabled) {
+  var spyChatControl = document.createElement("span");
+  spyChatControl.id = "spyChatControl";
+
+  var checkbox = document.createElement("input");
+  checkbox.type = "checkbox";
+  checkbox.id = "spyChatCheckbox";
+  checkbox.checked = enabled;
+  checkbox.onchange = function () {
+    sendResistanceMessage(Messages.SET_SPY_CHAT, {"spyChat": checkbox.checked});
+  }
+  spyChatControl.appendChild(checkbox);
+
+  var label = document.createElement("label");
+  label.htmlFor = "spyChatCheckbox";
+  label.appendChild(document.createTextNode("Spies can chat among themselves"));
+  spyChatControl.appendChild(label);
+
+  actionDiv.appendChild(spyChatControl);
+}
+
+// addLockLobbyControl lets the host lock the lobby, so nobody new can join.
+function addLockLobbyControl(actionDiv, locked) {
+  var lockLobbyControl = document.createElement("span");
+  lockLobbyControl.id = "lockLobbyControl";
+
+  var checkbox = document.createElement("input");
+  checkbox.type = "checkbox";
+  checkbox.id = "lockLobbyCheckbox";
+  checkbox.checked = locked;
+  checkbox.onchange = function () {
+    sendResistanceMessage(Messages.LOCK_LOBBY, {"locked": checkbox.checked});
+  }
+  lockLobbyControl.appendChild(checkbox);
+
+  var label = document.createElement("label");
+  label.htmlFor = "lockLobbyCheckbox";
+  label.appendChild(document.createTextNode("Lock the lobby"));
+  lockLobbyControl.appendChild(label);
+
+  actionDiv.appendChild(lockLobbyControl);
+}
+
+// addTimeLimitControls lets the host set how many seconds everyone gets
+// for each move, 0 for as long as they like.
+function addTimeLimitControls(actionDiv, timeLimits) {
+  var timeLimitControls = document.createElement("div");
+  timeLimitControls.id = "timeLimitControls";
+  timeLimitControls.appendChild(document.createTextNode(
+    "Seconds to pick a team, vote, play a card, and come back after leaving: "));
+
+  var inputs = {};
+  var sendTimeLimits = function () {
+    sendResistanceMessage(Messages.SET_TIME_LIMITS, {"timeLimits": {
+      "team": parseInt(inputs.team.value, 10) || 0,
+      "vote": parseInt(inputs.vote.value, 10) || 0,
+      "mission": parseInt(inputs.mission.value, 10) || 0,
+      "disconnect": parseInt(inputs.disconnect.value, 10) || 0}});
+  }
+  var names = ["team", "vote", "mission", "disconnect"];
+  for (var i = 0; i < names.length; i++) {
+    var input = document.createElement("input");
+    input.type = "number";
+    input.min = 0;
+    input.max = 600;
+    input.className = "timeLimit";
+    input.value = timeLimits[names[i]];
+    input.onchange = sendTimeLimits;
+    inputs[names[i]] = input;
+    timeLimitControls.appendChild(input);
+  }
+
+  actionDiv.appendChild(timeLimitControls);
+}
+
+// handleTimer shows how long is left for the move the game is waiting on,
+// counting down on its own until the server says otherwise.
+function handleTimer(timeLeft) {
+  if (timerInterval != null) {
+    clearInterval(timerInterval);
+    timerInterval = null;
+  }
 
-  if (parsedMessage.updateGameProgress) {
-    sendResistanceMessage("updateGameProgress");
+  var timerInfo = document.getElementById("timerInfo");
+  if (!timeLeft) {
+    timerInfo.style.display = "none";
+    return;
   }
 
-  sendResistanceMessage("getPlayers");
+  var deadline = Date.now() + timeLeft * 1000;
+  var showTimeLeft = function () {
+    var secondsLeft = Math.max(0, Math.ceil((deadline - Date.now()) / 1000));
+    document.getElementById("timeLeft").innerHTML =
+      Math.floor(secondsLeft / 60) + ":" + ("0" + secondsLeft % 60).slice(-2);
+  }
+  showTimeLeft();
+  timerInterval = setInterval(showTimeLeft, 1000);
+  timerInfo.style.display = "block";
+}
+
+// handleChat adds something someone said to the chat log.
+function handleChat(chatInfo) {
+  var chatLog = document.getElementById("chatLog");
+  var line = document.createElement("div");
+  if (chatInfo.channel == "spies") {
+    line.className = "spyChat";
+  }
+  var time = chatInfo.time.substring(11, 16);
+  var prefix = chatInfo.channel == "spies" ? " (spies) " : " ";
+  line.appendChild(document.createTextNode(time + prefix + chatInfo.username + ": " + chatInfo.text));
+  chatLog.appendChild(line);
+  chatLog.scrollTop = chatLog.scrollHeight;
+}
+
+// handleChatLog redraws the whole chat log, from the state of the game.
+// Spectators can read the chat but not say anything, and only spies get to
+// pick the spies' channel, if the host allowed it.
+function handleChatLog(state) {
+  document.getElementById("chatLog").innerHTML = "";
+  for (var i = 0; i < state.chat.length; i++) {
+    handleChat(state.chat[i]);
+  }
+
+  document.getElementById("chatControls").style.display = spectating ? "none" : "inline";
+  var canTalkAsSpy = state.spyChat && state.phase != "lobby" && state.you.role == "Spy";
+  document.getElementById("chatChannel").style.display = canTalkAsSpy ? "inline" : "none";
+  if (!canTalkAsSpy) {
+    document.getElementById("chatChannel").value = "all";
+  }
+}
+
+function sendChat() {
+  var chatText = document.getElementById("chatText");
+  if (chatText.value.trim() == "") {
+    return;
+  }
+  sendResistanceMessage(Messages.SEND_CHAT, {
+    "channel": document.getElementById("chatChannel").value,
+    "text": chatText.value});
+  chatText.value = "";
 }
 
 function handlePlayers(parsedMessage) {
+  handleSpectators(parsedMessage.spectators);
   if ("players" in parsedMessage) {
+    var bots = {};
+    if (parsedMessage.bots) {
+      for (var i = 0; i < parsedMessage.bots.length; i++) {
+        bots[parsedMessage.bots[i].Username] = parsedMessage.bots[i].UserId;
+      }
+    }
+
+    // the host may have just changed, ask for what the lobby needs to be
+    // drawn again
+    if ("hostId" in parsedMessage && (parsedMessage.hostId == myUserId) != isHost) {
+      isHost = parsedMessage.hostId == myUserId;
+      sendResistanceMessage(Messages.QUERY_GAME_STATE);
+    }
+
+    // only the host has the lobby controls, and only in the lobby
+    var lobbyControls = document.getElementById("startButton") != null;
+    var lockCheckbox = document.getElementById("lockLobbyCheckbox");
+    if (lockCheckbox != null) {
+      lockCheckbox.checked = parsedMessage.locked;
+    }
+
+    var playerIds = parsedMessage.playerIds || [];
     var playersTable = document.getElementById("players");
     playersTable.innerHTML = ""
     for (var i = 0; i < parsedMessage.players.length; i++) {
       var row = playersTable.insertRow(-1);
       var cell = row.insertCell(0);
-      cell.innerHTML = parsedMessage.players[i];
+      cell.appendChild(document.createTextNode(parsedMessage.players[i]));
+      if (playerIds[i] == parsedMessage.hostId) {
+        cell.appendChild(document.createTextNode(" (host)"));
+      }
+      if (parsedMessage.players[i] in bots) {
+        cell.appendChild(document.createTextNode(" (bot)"));
+        if (document.getElementById("botControls") != null) {
+          cell.appendChild(getRemoveBotButton(bots[parsedMessage.players[i]]));
+        }
+      } else if (lobbyControls && playerIds[i] != myUserId) {
+        cell.appendChild(getKickPlayerButton(playerIds[i]));
+        cell.appendChild(getTransferHostButton(playerIds[i], parsedMessage.players[i]));
+      }
+      if (lobbyControls && playerIds.length == parsedMessage.players.length) {
+        cell.appendChild(getMoveSeatButton(playerIds, i, -1));
+        cell.appendChild(getMoveSeatButton(playerIds, i, 1));
+      }
     }
     if (parsedMessage.players.length >= 5) {
       var button = document.getElementById("startButton");
@@ -115,13 +505,86 @@ function handlePlayers(parsedMessage) {
   }
 }
 
+function getRemoveBotButton(userId) {
+  var removeButton = document.createElement("input");
+  removeButton.type = "button";
+  removeButton.value = "Remove";
+  removeButton.onclick = function () {
+    sendResistanceMessage(Messages.REMOVE_BOT, {"userId": userId});
+  }
+  return removeButton;
+}
+
+function getKickPlayerButton(userId) {
+  var kickButton = document.createElement("input");
+  kickButton.type = "button";
+  kickButton.value = "Kick";
+  kickButton.onclick = function () {
+    sendResistanceMessage(Messages.KICK_PLAYER, {"userId": userId});
+  }
+  return kickButton;
+}
+
+function getTransferHostButton(userId, username) {
+  var hostButton = document.createElement("input");
+  hostButton.type = "button";
+  hostButton.value = "Make host";
+  hostButton.onclick = function () {
+    if (confirm("Make " + username + " the host? You won't be able to start the game any more.")) {
+      sendResistanceMessage(Messages.TRANSFER_HOST, {"userId": userId});
+    }
+  }
+  return hostButton;
+}
+
+// getMoveSeatButton moves the player in the given seat one seat up or down.
+// Leaders take turns in seat order.
+function getMoveSeatButton(playerIds, seat, direction) {
+  var moveButton = document.createElement("input");
+  moveButton.type = "button";
+  moveButton.value = direction < 0 ? "\u25B2" : "\u25BC";
+  var otherSeat = seat + direction;
+  if (otherSeat < 0 || otherSeat >= playerIds.length) {
+    moveButton.disabled = true;
+  }
+  moveButton.onclick = function () {
+    var seats = playerIds.slice();
+    seats[seat] = playerIds[otherSeat];
+    seats[otherSeat] = playerIds[seat];
+    sendResistanceMessage(Messages.SET_SEAT_ORDER, {"players": seats});
+  }
+  return moveButton;
+}
+
+// handleKicked sends a player the host kicked out of the lobby back home.
+function handleKicked(parsedMessage) {
+  alert("The host removed you from this game.");
+  window.location.assign("/home.html");
+}
+
 function handleGameStart(parsedMessage) {
-  // remove the start button if it exists
+  // remove the start button and bot controls if they exist
   var startButton = document.getElementById("startButton");
+  var botControls = document.getElementById("botControls");
   var actionDiv = document.getElementById("action");
   if (startButton != null && actionDiv != null) {
       actionDiv.removeChild(startButton);
   }
+  if (botControls != null && actionDiv != null) {
+      actionDiv.removeChild(botControls);
+  }
+  var spyChatControl = document.getElementById("spyChatControl");
+  if (spyChatControl != null && actionDiv != null) {
+      actionDiv.removeChild(spyChatControl);
+  }
+  var timeLimitControls = document.getElementById("timeLimitControls");
+  if (timeLimitControls != null && actionDiv != null) {
+      actionDiv.removeChild(timeLimitControls);
+  }
+  var lockLobbyControl = document.getElementById("lockLobbyControl");
+  if (lockLobbyControl != null && actionDiv != null) {
+      actionDiv.removeChild(lockLobbyControl);
+  }
 
   // Show button to get role
   var button = document.getElementById("showRoleButton");
@@ -131,14 +594,18 @@ function handleGameStart(parsedMessage) {
     button.value = "Show Role";
     button.id = "showRoleButton";
     button.onclick = function () {
-      sendResistanceMessage("queryRole");
+      sendResistanceMessage(Messages.QUERY_ROLE);
     }
     document.getElementById("roleInfo").appendChild(button);
   }
 }
 
 function handleQueryRoleResult(parsedMessage) {
-  var role = document.createTextNode(parsedMessage.role);
+  var roleText = parsedMessage.role;
+  if (privateState != null && privateState.spies.length > 0) {
+    roleText += " (the other spies: " + privateState.spies.join(", ") + ")";
+  }
+  var role = document.createTextNode(roleText);
 
   hideRole = function() {
     document.getElementById("showRoleButton").style.display = "inline";
@@ -155,7 +622,7 @@ function handleQueryRoleResult(parsedMessage) {
 function handleMissionPreparation(parsedMessage) {
   // A mission needs to be sent, but which team?
   // Send a message to see if I'm the leader.
-  sendResistanceMessage("queryLeader");
+  sendResistanceMessage(Messages.QUERY_LEADER);
 }
 
 function handleQueryLeaderResult(parsedMessage) {
@@ -202,7 +669,7 @@ function handleQueryLeaderResult(parsedMessage) {
         alert("Please select a team of " + parsedMessage["teamSize"] + ".");
       } else {
         submitButton.disabled = true;
-        sendResistanceMessage("startMission", {"team": userIds});
+        sendResistanceMessage(Messages.START_MISSION, {"team": userIds});
       }
       return true;
     }
@@ -228,7 +695,7 @@ function handleTeamApproval(parsedMessage) {
   yesButton.onclick = function() {
     yesButton.disabled = true;
     noButton.disabled = true;
-    sendResistanceMessage("approveTeam", {"vote":true});
+    sendResistanceMessage(Messages.APPROVE_TEAM, {"vote":true});
   }
   actionDiv.appendChild(yesButton);
 
@@ -238,7 +705,7 @@ function handleTeamApproval(parsedMessage) {
   noButton.onclick = function() {
     yesButton.disabled = true;
     noButton.disabled = true;
-    sendResistanceMessage("approveTeam", {"vote":false});
+    sendResistanceMessage(Messages.APPROVE_TEAM, {"vote":false});
   }
   actionDiv.appendChild(noButton);
 }
@@ -256,7 +723,7 @@ function handleApproveTeamUpdate(parsedMessage) {
 }
 
 function handleMissionStarted(parsedMessage) {
-  sendResistanceMessage("queryIsOnMission");
+  sendResistanceMessage(Messages.QUERY_IS_ON_MISSION);
 }
 
 function handleQueryIsOnMissionResult(parsedMessage) {
@@ -274,7 +741,7 @@ function handleQueryIsOnMissionResult(parsedMessage) {
     successButton.onclick = function() {
       successButton.disabled = true;
       failButton.disabled = true;
-      sendResistanceMessage("missionOutcome", {"outcome":true});
+      sendResistanceMessage(Messages.MISSION_OUTCOME, {"outcome":true});
     }
     actionDiv.appendChild(successButton);
 
@@ -284,13 +751,14 @@ function handleQueryIsOnMissionResult(parsedMessage) {
     failButton.onclick = function() {
       successButton.disabled = true;
       failButton.disabled = true;
-      sendResistanceMessage("missionOutcome", {"outcome":false});
+      sendResistanceMessage(Messages.MISSION_OUTCOME, {"outcome":false});
     }
     actionDiv.appendChild(failButton);
   }
 }
 
 function handleGameOver(parsedMessage) {
+  handleTimer(0);
   alert("Game Over. The " + parsedMessage.winner + " team wins!");
   window.location.assign("/home.html");
 }
@@ -332,11 +800,32 @@ function handleMissions(parsedMessage) {
   }
 
   missionInfoDiv.appendChild(table);
+
+  if (parsedMessage.spyOdds) {
+    handleSpyOdds(parsedMessage.spyOdds);
+  }
+}
+
+// handleSpyOdds shows how likely each player is to be a spy, worked out by
+// the game server from the public record. Ranked games don't get the odds.
+function handleSpyOdds(spyOdds) {
+  var spyOddsTable = document.getElementById("spyOdds");
+  spyOddsTable.innerHTML = "";
+  for (var username in spyOdds) {
+    var row = spyOddsTable.insertRow(-1);
+    var cell1 = row.insertCell(0);
+    var cell2 = row.insertCell(1);
+    cell1.appendChild(document.createTextNode(username));
+    cell2.appendChild(document.createTextNode(Math.round(spyOdds[username] * 100) + "%"));
+  }
+  document.getElementById("spyOddsInfo").style.display = "block";
 }
 
 function handleGameResume(parsedMessage) {
   var overlayMessage = document.getElementById("overlayMessage");
   overlayMessage.style.display = "none";
+  overlayMessage.innerHTML = "";
+  overlayMessage.appendChild(document.createTextNode("Waiting for all players to join..."));
 
   var overlayDiv = document.getElementById("overlay");
   overlayDiv.style.display = "none";
@@ -350,12 +839,200 @@ function handleGamePause(parsedMessage) {
   overlayDiv.style.display = "block";
 }
 
+// handleGracePeriodOver tells everyone the players the game is waiting on
+// are out of time to come back, and gives the host the choice of who to
+// replace them with, or of giving up on the game.
+function handleGracePeriodOver(usernames) {
+  var overlayMessage = document.getElementById("overlayMessage");
+  overlayMessage.innerHTML = "";
+  overlayMessage.appendChild(document.createTextNode(usernames.join(", ") + " didn't come back in time."));
+  addBreak(overlayMessage);
+
+  if (!isHost || spectating) {
+    overlayMessage.appendChild(document.createTextNode("The host can replace them, or abandon the game."));
+    return;
+  }
+
+  for (var i = 0; i < usernames.length; i++) {
+    overlayMessage.appendChild(getReplacementControls(usernames[i]));
+  }
+
+  var abandonButton = document.createElement("input");
+  abandonButton.type = "button";
+  abandonButton.value = "Abandon game";
+  abandonButton.onclick = function () {
+    if (confirm("Nobody will win this game. Abandon it?")) {
+      sendResistanceMessage(Messages.ABANDON_GAME);
+    }
+  }
+  overlayMessage.appendChild(abandonButton);
+}
+
+// getReplacementControls lets the host replace the given player with a bot
+// or with someone else, who then has to load the game page.
+function getReplacementControls(username) {
+  var replacementControls = document.createElement("div");
+  replacementControls.appendChild(document.createTextNode("Replace " + username + " with "));
+
+  var strategySelect = document.createElement("select");
+  for (var i = 0; i < botStrategies.length; i++) {
+    var option = document.createElement("option");
+    option.value = botStrategies[i];
+    option.text = botStrategies[i];
+    strategySelect.appendChild(option);
+  }
+  replacementControls.appendChild(strategySelect);
+
+  var botButton = document.createElement("input");
+  botButton.type = "button";
+  botButton.value = "Bot";
+  botButton.onclick = function () {
+    sendResistanceMessage(Messages.REPLACE_PLAYER, {"username": username, "strategy": strategySelect.value});
+  }
+  replacementControls.appendChild(botButton);
+
+  replacementControls.appendChild(document.createTextNode(" or "));
+  var replacementText = document.createElement("input");
+  replacementText.type = "text";
+  replacementText.placeholder = "username";
+  replacementControls.appendChild(replacementText);
+
+  var playerButton = document.createElement("input");
+  playerButton.type = "button";
+  playerButton.value = "Player";
+  playerButton.onclick = function () {
+    sendResistanceMessage(Messages.REPLACE_PLAYER, {"username": username, "replacement": replacementText.value});
+  }
+  replacementControls.appendChild(playerButton);
+
+  return replacementControls;
+}
+
+// handlePlayerReplaced notes in the chat log who took over whose seat.
+function handlePlayerReplaced(parsedMessage) {
+  var chatLog = document.getElementById("chatLog");
+  var line = document.createElement("div");
+  line.appendChild(document.createTextNode(parsedMessage.replacement + " took over from " + parsedMessage.username + "."));
+  chatLog.appendChild(line);
+  chatLog.scrollTop = chatLog.scrollHeight;
+}
+
+function handleGameAbandoned(parsedMessage) {
+  handleTimer(0);
+  alert("The host abandoned the game. Nobody wins.");
+  window.location.assign("/home.html");
+}
+
 function handleShowText(parsedMessage) {
   clearActionDiv();
   var actionDiv = document.getElementById("action");
   actionDiv.appendChild(document.createTextNode(parsedMessage.text));
 }
 
+// handleGameState draws the players, missions and role from a snapshot of
+// the whole game. What to do next still comes from the usual messages.
+function handleGameState(state) {
+  privateState = state.you;
+  handleInviteCode(state.you && state.you.inviteCode);
+  // the host changed while in the lobby
+  if (!spectating && state.phase == "lobby" && (document.getElementById("startButton") != null) != isHost) {
+    drawLobby(state);
+    sendResistanceMessage(Messages.GET_PLAYERS);
+  }
+  handleSpectators(state.spectators);
+  handleChatLog(state);
+  handleTimer(state.timeLeft);
+  if (state.gracePeriodOver) {
+    var outOfTime = [];
+    for (var i = 0; i < state.players.length; i++) {
+      if (state.players[i].outOfTime) {
+        outOfTime.push(state.players[i].username);
+      }
+    }
+    handleGamePause({});
+    handleGracePeriodOver(outOfTime);
+  }
+
+  var playersTable = document.getElementById("players");
+  playersTable.innerHTML = "";
+  for (var i = 0; i < state.players.length; i++) {
+    var player = state.players[i];
+    var text = player.username;
+    if (player.isLeader) {
+      text += " (leader)";
+    }
+    if (player.isOnTeam) {
+      text += " (on team)";
+    }
+    if (player.isBot) {
+      text += " (bot)";
+    }
+    if (!player.connected) {
+      text += " (disconnected)";
+    }
+    var row = playersTable.insertRow(-1);
+    var cell = row.insertCell(0);
+    cell.appendChild(document.createTextNode(text));
+  }
+
+  handleMissions({"missions": state.missions, "spyOdds": state.spyOdds});
+
+  if (spectating) {
+    drawSpectatorView(state);
+  } else if (state.phase != "lobby") {
+    handleGameStart({});
+  }
+}
+
+// drawSpectatorView shows a spectator what the game is waiting on.
+function drawSpectatorView(state) {
+  clearActionDiv();
+  var actionDiv = document.getElementById("action");
+  var text;
+  switch (state.phase) {
+    case "lobby":
+      text = "Waiting for the host to start the game...";
+      break;
+    case "teamSelection":
+      text = state.leader + " is picking a team of " + state.teamSize + " for mission " + state.missionNum + ".";
+      break;
+    case "voting":
+      text = "Everyone is voting on the team for mission " + state.missionNum + ": " + state.team.join(", ");
+      break;
+    case "mission":
+      text = state.team.join(", ") + " are on mission " + state.missionNum + ".";
+      break;
+    default:
+      text = "The game is over.";
+  }
+  actionDiv.appendChild(document.createTextNode(text));
+  for (var username in state.votes) {
+    handleApproveTeamUpdate({"username": username, "vote": state.votes[username]});
+  }
+}
+
+function handleResumeResult(parsedMessage) {
+  if (parsedMessage.complete) {
+    for (var i = 0; i < parsedMessage.messages.length; i++) {
+      handleMessage(JSON.stringify(parsedMessage.messages[i]));
+    }
+  } else {
+    // missed too much (or the server started numbering over), so start
+    // over from the current state of the game
+    lastSeq = parsedMessage.lastSeq;
+    epoch = parsedMessage.epoch;
+    catchUp();
+  }
+}
+
+function catchUp() {
+  if (spectating) {
+    sendResistanceMessage(Messages.QUERY_GAME_STATE);
+  } else if (gameInProgress) {
+    sendResistanceMessage(Messages.UPDATE_GAME_PROGRESS);
+  }
+}
+
 function addBreak(divElement) {
   var br = document.createElement("br");
   divElement.appendChild(br);
@@ -369,28 +1046,143 @@ function clearActionDiv() {
 function sendResistanceMessage(message, arguments) {
   var packet = {};
   packet["message"] = message;
-  packet["userCookie"] = document.cookie;
   packet["gameId"] = gameId;
   for (var property in arguments) {
     packet[property] = arguments[property];
   }
-  socket.send(JSON.stringify(packet));
+  if (eventStream != null) {
+    postAction(packet);
+  } else {
+    socket.send(JSON.stringify(packet));
+  }
+}
+
+// postAction sends a message to the game when using the event stream
+// instead of the websocket. The reply is handled like any other message.
+function postAction(packet) {
+  var request = new XMLHttpRequest();
+  request.open("POST", "/action");
+  request.setRequestHeader("Content-Type", "application/x-www-form-urlencoded");
+  request.onload = function() {
+    if (request.status == 200 && request.responseText != "") {
+      handleMessage(request.responseText);
+    } else if (request.status != 200) {
+      handleMessage(JSON.stringify({"errorMessage": request.responseText}));
+    }
+  };
+  request.send("csrfToken=" + encodeURIComponent(csrfToken) +
+               "&message=" + encodeURIComponent(JSON.stringify(packet)));
 }
 
 function startGame() {
-  sendResistanceMessage("startGame");
+  sendResistanceMessage(Messages.START_GAME);
 }
 
 function playerConnect() {
-  sendResistanceMessage("playerConnect");
+  if (spectating) {
+    sendResistanceMessage(Messages.SPECTATE, {"protocolVersion": PROTOCOL_VERSION});
+    return;
+  }
+  sendResistanceMessage(Messages.PLAYER_CONNECT, {"protocolVersion": PROTOCOL_VERSION});
 }
 
-var socket = new io.Socket(null, {port: 8081, rememberTransport: false});
+// handleProtocolError stops us from talking to a game server that speaks a
+// different version of the protocol. The error itself is already shown.
+function handleProtocolError(parsedMessage) {
+  protocolMismatch = true;
+  if (eventStream != null) {
+    eventStream.close();
+  }
+  if (socket != null) {
+    socket.close();
+  }
+}
 
-socket.on('message', function(obj) {
-  handleMessage(obj);
-});
+function showDisconnected() {
+  var div = document.getElementById("alerts");
+  div.innerHTML = "<b>Error: Lost connection to the game server. Reload the page to reconnect.</b>";
+}
 
 gameId = parseUrlParams()["gameId"];
-socket.connect();
-playerConnect();
+
+// connectEventStream is the fallback for when the websocket can't get
+// through. Opening the stream connects the player to the game.
+function connectEventStream() {
+  eventStream = new EventSource("/events?gameId=" + encodeURIComponent(gameId) +
+                                "&protocolVersion=" + PROTOCOL_VERSION +
+                                (spectating ? "&spectate=true" : ""));
+
+  eventStream.onmessage = function(event) {
+    handleMessage(event.data);
+  };
+
+  eventStream.onerror = function() {
+    // The browser reconnects by itself unless the server turned us away
+    if (eventStream.readyState == EventSource.CLOSED) {
+      showDisconnected();
+    }
+  };
+}
+
+// connectWebSocket connects to the websocket proxy. If the connection
+// drops, for example because we fell behind on the game's messages, we
+// reconnect and catch up. If it never opens, we fall back to the event
+// stream.
+function connectWebSocket() {
+  var socketOpened = false;
+  var scheme = window.location.protocol == "https:" ? "wss://" : "ws://";
+  socket = new WebSocket(scheme + window.location.hostname + ":8081/ws");
+
+  socket.onopen = function() {
+    socketOpened = true;
+    reconnectAttempts = 0;
+    playerConnect();
+  };
+
+  socket.onmessage = function(event) {
+    handleMessage(event.data);
+  };
+
+  socket.onclose = function() {
+    if (protocolMismatch) {
+      return;
+    } else if (!socketOpened && reconnectAttempts == 0) {
+      connectEventStream();
+    } else if (reconnectAttempts < MAX_RECONNECT_ATTEMPTS) {
+      reconnectAttempts++;
+      setTimeout(connectWebSocket, RECONNECT_DELAY);
+    } else {
+      showDisconnected();
+    }
+  };
+}
+
+var MAX_RECONNECT_ATTEMPTS = 5;
+var RECONNECT_DELAY = 2000;
+
+var eventStream = null;
+var protocolMismatch = false;
+var lastSeq = 0;
+var epoch = null;
+var gameInProgress = false;
+var privateState = null;
+var timerInterval = null;
+var myUserId = 0;
+var isHost = false;
+var botStrategies = [];
+var socket = null;
+var reconnectAttempts = 0;
+
+document.getElementById("newInviteCode").onclick = function () {
+  if (confirm("The old invite link will stop working. Make a new one?")) {
+    sendResistanceMessage(Messages.REGENERATE_INVITE_CODE);
+  }
+};
+document.getElementById("chatSend").onclick = sendChat;
+document.getElementById("chatText").onkeydown = function(event) {
+  if (event.keyCode == 13) {
+    sendChat();
+  }
+};
+
+connectWebSocket();
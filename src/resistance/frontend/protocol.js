@@ -0,0 +1,215 @@
+// Generated by resistancePROTOCOL from src/resistance/protocol. Do not edit.
+
+var PROTOCOL_VERSION = 1;
+
+var Messages = {
+  // First message once connected, joins the game. (toServer)
+  //   protocolVersion: number - Protocol version the page speaks.
+  PLAYER_CONNECT: "playerConnect",
+  // Asks for the players of the game. (toServer)
+  GET_PLAYERS: "getPlayers",
+  // The host starts the game. (toServer)
+  START_GAME: "startGame",
+  // Asks for the player's role. (toServer)
+  QUERY_ROLE: "queryRole",
+  // Asks if the player leads the current mission. (toServer)
+  QUERY_LEADER: "queryLeader",
+  // The leader picks the team. (toServer)
+  //   team: array - User ids, as strings, of the team.
+  START_MISSION: "startMission",
+  // Votes on the proposed team. (toServer)
+  //   vote: boolean - Whether the player approves.
+  APPROVE_TEAM: "approveTeam",
+  // Asks if the player is on the current mission. (toServer)
+  QUERY_IS_ON_MISSION: "queryIsOnMission",
+  // Plays a card on the mission. (toServer)
+  //   outcome: boolean - True for success, false for fail.
+  MISSION_OUTCOME: "missionOutcome",
+  // Asks what the player should be doing after a reconnect. (toServer)
+  UPDATE_GAME_PROGRESS: "updateGameProgress",
+  // Asks for the published messages missed while disconnected. (toServer)
+  //   lastSeq: number - Sequence number of the last message seen.
+  //   epoch: string - Epoch of the last message seen.
+  RESUME: "resume",
+  // Asks for the whole state of the game. (toServer)
+  QUERY_GAME_STATE: "queryGameState",
+  // Answers a ping. (toServer)
+  PONG: "pong",
+  // The host adds a bot to the lobby. (toServer)
+  //   strategy: string - Name of the strategy the bot plays with.
+  ADD_BOT: "addBot",
+  // The host takes a bot out of the lobby. (toServer)
+  //   userId: number - User id of the bot.
+  REMOVE_BOT: "removeBot",
+  // First message once connected, to watch the game instead of joining it. (toServer)
+  //   protocolVersion: number - Protocol version the page speaks.
+  SPECTATE: "spectate",
+  // Says something. (toServer)
+  //   channel: string - all, or spies if the host allowed the spies a channel.
+  //   text: string - What to say.
+  SEND_CHAT: "sendChat",
+  // The host decides, in the lobby, whether the spies get a channel. (toServer)
+  //   spyChat: boolean - Whether they do.
+  SET_SPY_CHAT: "setSpyChat",
+  // The host replaces a player who didn't come back in time. (toServer)
+  //   username: string - Who to replace.
+  //   strategy: string - Strategy of the bot to replace them with, or empty for a person.
+  //   replacement: string - Username of the person to replace them with.
+  REPLACE_PLAYER: "replacePlayer",
+  // The host gives up on a game waiting on players who didn't come back in time. (toServer)
+  ABANDON_GAME: "abandonGame",
+  // The host kicks a player out of the lobby. (toServer)
+  //   userId: number - User id of the player.
+  KICK_PLAYER: "kickPlayer",
+  // The host hands the game over to another player in the lobby. (toServer)
+  //   userId: number - User id of the new host.
+  TRANSFER_HOST: "transferHost",
+  // The host locks the lobby, so nobody new can join, or unlocks it. (toServer)
+  //   locked: boolean - Whether the lobby is locked.
+  LOCK_LOBBY: "lockLobby",
+  // The host changes where everyone sits, which is the order leaders take turns in. (toServer)
+  //   players: array - User ids of the players, in seat order.
+  SET_SEAT_ORDER: "setSeatOrder",
+  // The host of a private game gives it a new invite code, answered with the game state. (toServer)
+  REGENERATE_INVITE_CODE: "regenerateInviteCode",
+  // The host sets, in the lobby, how long players get for each move. (toServer)
+  //   timeLimits: object - Seconds for picking a team, voting and playing a card, under team, vote and mission, and for players who left to come back, under disconnect. 0 for no limit.
+  SET_TIME_LIMITS: "setTimeLimits",
+  // The player has joined, or is watching, the game. (toClient)
+  //   gameId: number - Id of the game.
+  //   acceptUser: boolean - Always true.
+  //   userId: number - Id of the player.
+  //   isHost: boolean - Whether the player is the host.
+  //   updateGameProgress: boolean - Whether the game is already in progress.
+  //   gameState: object - The state of the game, see game.GameState.
+  //   protocolVersion: number - Protocol version the server speaks.
+  //   strategies: array - Strategies bots can play with, for whoever is the host.
+  //   spectate: boolean - Whether this is a spectator rather than a player.
+  PLAYER_CONNECT_SUCCESSFUL: "playerConnectSuccessful",
+  // The players of the game changed. (toClient)
+  //   players: array - Usernames of the connected players, in seat order.
+  //   playerIds: array - User ids of the same players.
+  //   hostId: number - User id of the host.
+  //   locked: boolean - Whether the host locked the lobby.
+  //   bots: array - The bots among them, with UserId and Username.
+  //   spectators: array - Usernames of the people watching.
+  //   gameId: number - Id of the game.
+  PLAYERS: "players",
+  // The host started the game. (toClient)
+  GAME_STARTED: "gameStarted",
+  // The player's role. (toClient)
+  //   role: string - Name of the role.
+  QUERY_ROLE_RESULT: "queryRoleResult",
+  // The player leads the current mission. (toClient)
+  //   isLeader: boolean - Always true.
+  //   players: array - Players to pick the team from, with UserId and Username.
+  //   teamSize: number - How many players to pick.
+  QUERY_LEADER_RESULT: "queryLeaderResult",
+  // A new mission needs a team. (toClient)
+  MISSION_PREPARATION: "missionPreparation",
+  // Everyone votes on the proposed team. (toClient)
+  //   team: array - Usernames of the team.
+  TEAM_APPROVAL: "teamApproval",
+  // Someone voted. (toClient)
+  //   username: string - Who voted.
+  //   vote: boolean - How they voted.
+  APPROVE_TEAM_UPDATE: "approveTeamUpdate",
+  // The team was approved and goes on the mission. (toClient)
+  MISSION_STARTED: "missionStarted",
+  // The player is on the current mission. (toClient)
+  //   isOnMission: boolean - Always true.
+  QUERY_IS_ON_MISSION_RESULT: "queryIsOnMissionResult",
+  // The game is over. (toClient)
+  //   winner: string - Which side won.
+  GAME_OVER: "gameOver",
+  // The mission board changed. (toClient)
+  //   missions: array - Every mission so far.
+  //   spyOdds: object - Chance of each player being a spy, by username. Left out in ranked games.
+  MISSIONS: "missions",
+  // A player is missing, the game waits for them. (toClient)
+  GAME_PAUSE: "gamePause",
+  // Everyone is back. (toClient)
+  GAME_RESUME: "gameResume",
+  // Some text to show the player. (toClient)
+  //   text: string - The text.
+  SHOW_TEXT: "showText",
+  // The published messages the player missed. (toClient)
+  //   messages: array - The messages, oldest first.
+  //   lastSeq: number - Sequence number of the latest message.
+  //   epoch: string - Epoch the sequence numbers are counted in.
+  //   complete: boolean - False if some of the messages are no longer kept, or were numbered in another epoch.
+  RESUME_RESULT: "resumeResult",
+  // The whole state of the game. (toClient)
+  //   gameState: object - The state of the game, see game.GameState.
+  GAME_STATE: "gameState",
+  // The page speaks a protocol version the server doesn't. (toClient)
+  //   errorMessage: string - What to tell the player.
+  //   protocolVersion: number - Protocol version the server speaks.
+  PROTOCOL_ERROR: "protocolError",
+  // Heartbeat from the websocket proxy, answer with a pong. (toClient)
+  PING: "ping",
+  // Someone said something. The spies' channel only goes to the spies, and isn't numbered. (toClient)
+  //   username: string - Who said it.
+  //   channel: string - all or spies.
+  //   text: string - What they said.
+  //   time: string - When they said it.
+  CHAT: "chat",
+  // The clock started, or stopped, on the move the game is waiting on. Whoever runs out of time has the move made for them. (toClient)
+  //   timeLeft: number - Seconds left, 0 if there is no clock.
+  TIMER: "timer",
+  // The players the game is waiting on didn't come back in time, the host can replace them or abandon the game. (toClient)
+  //   players: array - Usernames of the players the game is waiting on.
+  GRACE_PERIOD_OVER: "gracePeriodOver",
+  // Someone took over the seat, and role, of a player who didn't come back. (toClient)
+  //   username: string - Who was replaced.
+  //   replacement: string - Who replaced them.
+  PLAYER_REPLACED: "playerReplaced",
+  // The host gave up on the game, nobody wins. (toClient)
+  GAME_ABANDONED: "gameAbandoned",
+  // The host kicked the player out of the lobby. (toClient)
+  KICKED: "kicked"
+};
+
+var Keys = {
+  MESSAGE: "message",
+  GAME_ID: "gameId",
+  SEQ: "seq",
+  EPOCH: "epoch",
+  ERROR_MESSAGE: "errorMessage",
+  PROTOCOL_VERSION: "protocolVersion",
+  TEAM: "team",
+  VOTE: "vote",
+  OUTCOME: "outcome",
+  LAST_SEQ: "lastSeq",
+  STRATEGY: "strategy",
+  USER_ID: "userId",
+  CHANNEL: "channel",
+  TEXT: "text",
+  SPY_CHAT: "spyChat",
+  USERNAME: "username",
+  REPLACEMENT: "replacement",
+  LOCKED: "locked",
+  PLAYERS: "players",
+  TIME_LIMITS: "timeLimits",
+  ACCEPT_USER: "acceptUser",
+  IS_HOST: "isHost",
+  UPDATE_GAME_PROGRESS: "updateGameProgress",
+  GAME_STATE: "gameState",
+  STRATEGIES: "strategies",
+  SPECTATE: "spectate",
+  PLAYER_IDS: "playerIds",
+  HOST_ID: "hostId",
+  BOTS: "bots",
+  SPECTATORS: "spectators",
+  ROLE: "role",
+  IS_LEADER: "isLeader",
+  TEAM_SIZE: "teamSize",
+  IS_ON_MISSION: "isOnMission",
+  WINNER: "winner",
+  MISSIONS: "missions",
+  SPY_ODDS: "spyOdds",
+  MESSAGES: "messages",
+  COMPLETE: "complete",
+  TIME: "time",
+  TIME_LEFT: "timeLeft"
+};
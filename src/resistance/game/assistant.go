@@ -0,0 +1,159 @@
+package game
+
+import (
+	"math"
+)
+
+// The assistant works out how likely each player is to be a spy from what
+// everyone can see: the teams proposed, the votes on them and how many fails
+// each mission got. Every way of picking the spies that fits the fail counts
+// is weighed by how likely the votes and cards would have been if those were
+// the spies.
+const (
+	// How likely a spy on a mission is to play a fail
+	SPY_FAIL_CHANCE = 0.8
+	// How likely a spy is to approve a team with a spy on it
+	SPY_APPROVE_SPY_TEAM_CHANCE = 0.8
+	// How likely a spy is to approve a team without any spies
+	SPY_APPROVE_CLEAN_TEAM_CHANCE = 0.4
+	// Resistance players can't tell teams apart, so they are taken to
+	// approve any team as often as they reject it
+	RESISTANCE_APPROVE_CHANCE = 0.5
+)
+
+// IsAssistantEnabled returns whether players get to see the spy odds. The
+// odds are only worked out while the game is being played, and never for
+// ranked games.
+func (game *Game) IsAssistantEnabled() bool {
+	return !game.Ranked && game.GameStatus == STATUS_IN_PROGRESS
+}
+
+// GetSpyOdds gives the chance of each player being a spy, by username. It is
+// nil when the assistant is not enabled.
+func (game *Game) GetSpyOdds() map[string]float64 {
+	if !game.IsAssistantEnabled() {
+		return nil
+	}
+
+	players := make([]*Player, 0)
+	for _, player := range game.Players {
+		if player.IsValid() {
+			players = append(players, player)
+		}
+	}
+	numSpies := numPlayersToNumSpies[len(players)]
+	if numSpies == 0 {
+		return nil
+	}
+
+	weights := make([]float64, len(players))
+	var totalWeight float64
+	forEachSpySet(len(players), numSpies, func(spySet []bool) {
+		spies := make(map[int]bool)
+		for index, isSpy := range spySet {
+			if isSpy {
+				spies[players[index].User.UserId] = true
+			}
+		}
+
+		weight := game.getSpySetLikelihood(spies)
+		if weight == 0 {
+			return
+		}
+		totalWeight += weight
+		for index, isSpy := range spySet {
+			if isSpy {
+				weights[index] += weight
+			}
+		}
+	})
+
+	spyOdds := make(map[string]float64)
+	for index, player := range players {
+		if totalWeight > 0 {
+			spyOdds[player.User.Username] = weights[index] / totalWeight
+		}
+	}
+	return spyOdds
+}
+
+// getSpySetLikelihood works out how likely everything seen so far would have
+// been if the given users were the spies. It is 0 if the fail counts rule
+// them out.
+func (game *Game) getSpySetLikelihood(spies map[int]bool) float64 {
+	likelihood := 1.0
+	for _, mission := range game.Missions {
+		// Spies who ran out of time played a success whether they wanted
+		// to or not
+		spiesOnTeam := 0
+		spiesPlaying := 0
+		for userId := range mission.Team {
+			if spies[userId] {
+				spiesOnTeam++
+				if !mission.TimedOutOutcomes[userId] {
+					spiesPlaying++
+				}
+			}
+		}
+
+		for userId, vote := range mission.Votes {
+			// Votes made for players who ran out of time say nothing
+			if mission.TimedOutVotes[userId] {
+				continue
+			}
+			approveChance := RESISTANCE_APPROVE_CHANCE
+			switch {
+			case spies[userId] && spiesOnTeam > 0:
+				approveChance = SPY_APPROVE_SPY_TEAM_CHANCE
+			case spies[userId]:
+				approveChance = SPY_APPROVE_CLEAN_TEAM_CHANCE
+			}
+			if vote == VOTE_ALLOW {
+				likelihood *= approveChance
+			} else {
+				likelihood *= 1 - approveChance
+			}
+		}
+
+		// Cards only become public once the mission is over, and then only
+		// how many fails there were
+		if mission.Winner != WINNER_RESISTANCE && mission.Winner != WINNER_SPY {
+			continue
+		}
+		numFails := mission.getNumFails()
+		if numFails > spiesPlaying {
+			return 0
+		}
+		likelihood *= float64(choose(spiesPlaying, numFails)) *
+			math.Pow(SPY_FAIL_CHANCE, float64(numFails)) * math.Pow(1-SPY_FAIL_CHANCE, float64(spiesPlaying-numFails))
+	}
+	return likelihood
+}
+
+// forEachSpySet calls visit with every way of picking numSpies of numPlayers
+// players, marking the picked ones true.
+func forEachSpySet(numPlayers int, numSpies int, visit func([]bool)) {
+	spySet := make([]bool, numPlayers)
+	var pick func(start int, left int)
+	pick = func(start int, left int) {
+		if left == 0 {
+			visit(spySet)
+			return
+		}
+		for index := start; index <= numPlayers-left; index++ {
+			spySet[index] = true
+			pick(index+1, left-1)
+			spySet[index] = false
+		}
+	}
+	pick(0, numSpies)
+}
+
+// choose gives the number of ways of picking k things out of n.
+func choose(n int, k int) int {
+	result := 1
+	for i := 0; i < k; i++ {
+		result = result * (n - i) / (i + 1)
+	}
+	return result
+}
@@ -0,0 +1,130 @@
+package game
+
+import (
+	"errors"
+	"resistance/users"
+	"resistance/utils"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	CHAT_CHANNEL_ALL        = "A"
+	CHAT_CHANNEL_ALL_NAME   = "all"
+	CHAT_CHANNEL_SPIES      = "S"
+	CHAT_CHANNEL_SPIES_NAME = "spies"
+
+	MAX_CHAT_MESSAGE_LENGTH = 200
+
+	// Same as MySQL, so chat read back from the database looks the same
+	CHAT_TIME_FORMAT = "2006-01-02 15:04:05"
+)
+
+// ChatMessage is something a player said in the game.
+type ChatMessage struct {
+	GameId   int
+	UserId   int
+	Username string
+	Channel  string
+	Text     string
+	Time     string
+}
+
+// GetChatInfo constructs the information about the message to be displayed
+// on the frontend.
+func (message *ChatMessage) GetChatInfo() map[string]interface{} {
+	chatInfo := make(map[string]interface{})
+	chatInfo["username"] = message.Username
+	chatInfo["text"] = message.Text
+	chatInfo["time"] = message.Time
+	switch {
+	case message.Channel == CHAT_CHANNEL_SPIES:
+		chatInfo["channel"] = CHAT_CHANNEL_SPIES_NAME
+	default:
+		chatInfo["channel"] = CHAT_CHANNEL_ALL_NAME
+	}
+	return chatInfo
+}
+
+// IsSpyChatAllowed returns whether the spies can talk among themselves. The
+// host decides in the lobby, and only spies ever see that channel.
+func (game *Game) IsSpyChatAllowed() bool {
+	return game.SpyChat && game.GameStatus == STATUS_IN_PROGRESS
+}
+
+// AddChatMessage adds what the given player said on the given channel, by
+// name, to the game. Returns the message as it should be sent out.
+func (game *Game) AddChatMessage(user *users.User, channelName string, text string) (*ChatMessage, error) {
+	player := game.GetPlayer(user)
+	if player == nil {
+		return nil, errors.New("Only players can chat.")
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, errors.New("Say something.")
+	}
+	if utf8.RuneCountInString(text) > MAX_CHAT_MESSAGE_LENGTH {
+		return nil, errors.New("Messages can be at most " + strconv.Itoa(MAX_CHAT_MESSAGE_LENGTH) + " characters long.")
+	}
+
+	channel := CHAT_CHANNEL_ALL
+	if channelName == CHAT_CHANNEL_SPIES_NAME {
+		if !game.IsSpyChatAllowed() || player.Role != ROLE_SPY {
+			return nil, errors.New("You can't talk on that channel.")
+		}
+		channel = CHAT_CHANNEL_SPIES
+	}
+
+	message := &ChatMessage{
+		GameId:   game.GameId,
+		UserId:   user.UserId,
+		Username: user.Username,
+		Channel:  channel,
+		Text:     text,
+		Time:     time.Now().Format(CHAT_TIME_FORMAT)}
+
+	// Only keep what made it to the database, so chat read back later is
+	// the same as what was sent out
+	err := game.Persister.PersistChatMessage(message)
+	if err != nil {
+		utils.LogMessage(err.Error(), utils.RESISTANCE_LOG_PATH)
+		return nil, errors.New("Your message could not be sent, try again.")
+	}
+	game.Chat = append(game.Chat, message)
+
+	return message, nil
+}
+
+// GetChat gets everything said in the game the given user is allowed to
+// read, oldest first. Everyone can read the spies' channel once the game is
+// over. A nil user is anyone not playing.
+func (game *Game) GetChat(viewer *users.User) []map[string]interface{} {
+	canReadSpies := game.IsFinished()
+	if viewer != nil {
+		player := game.getPlayer(viewer.UserId)
+		canReadSpies = canReadSpies || (player.IsValid() && player.Role == ROLE_SPY)
+	}
+
+	chat := make([]map[string]interface{}, 0)
+	for _, message := range game.Chat {
+		if message.Channel == CHAT_CHANNEL_SPIES && !canReadSpies {
+			continue
+		}
+		chat = append(chat, message.GetChatInfo())
+	}
+	return chat
+}
+
+// GetSpies gets the players who are spies.
+func (game *Game) GetSpies() []*Player {
+	spies := make([]*Player, 0)
+	for _, player := range game.Players {
+		if player.IsValid() && player.Role == ROLE_SPY {
+			spies = append(spies, player)
+		}
+	}
+	return spies
+}
@@ -6,13 +6,21 @@ import (
 	"resistance/users"
 	"resistance/utils"
 	"strconv"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 const (
 	STATUS_LOBBY       = "L"
 	STATUS_IN_PROGRESS = "P"
 	STATUS_DONE        = "D"
+	STATUS_ABANDONED   = "A"
+)
+
+const (
+	MAX_TITLE_LENGTH = 30
 )
 
 type Game struct {
@@ -20,9 +28,28 @@ type Game struct {
 	Title      string
 	Host       *users.User
 	GameStatus string
+	Ranked     bool
+	SpyChat    bool
+	Locked     bool
+	TimeLimits TimeLimits
+	Chat       []*ChatMessage
 	Missions   []*Mission
 	Players    []*Player
+	Replaced   []*Player
+	Kicked     []*Player
 	Persister  GamePersistor
+	spectators map[int]*Spectator
+
+	// Private games are only for those with the invite code or password,
+	// see private.go
+	Private      bool
+	InviteCode   string
+	PasswordHash string
+	admitted     map[int]bool
+
+	// When whoever the game is waiting on runs out of time, see timers.go
+	deadlinePhaseKey string
+	deadline         time.Time
 }
 
 // numPlayersToNumSpies gives you how many spies there should be in a game
@@ -45,23 +72,49 @@ var numPlayersOnTeam = map[int]map[int]int{
 	9:  {1: 3, 2: 4, 3: 4, 4: 5, 5: 5},
 	10: {1: 3, 2: 4, 3: 4, 4: 5, 5: 5}}
 
-func NewGame(gameTitle string, hostId string, persister GamePersistor) *Game {
+// NewGame creates a new game in the lobby with the given title, hosted by
+// the given user. Ranked games are played without the assistant.
+func NewGame(gameTitle string, host *users.User, ranked bool, persister GamePersistor) *Game {
+	newGame := newLobbyGame(gameTitle, host, ranked, persister)
+
+	err := persister.PersistGame(newGame)
+	if err != nil {
+		utils.LogMessage(err.Error(), utils.RESISTANCE_LOG_PATH)
+	}
+
+	return newGame
+}
+
+// newLobbyGame sets up a game waiting in the lobby, without persisting it.
+func newLobbyGame(gameTitle string, host *users.User, ranked bool, persister GamePersistor) *Game {
 	newGame := new(Game)
 	newGame.GameId = -1
 	newGame.Title = gameTitle
-	userId, err := strconv.Atoi(hostId)
-	if err == nil {
-		newGame.Host = users.LookupUserById(userId)
-	}
+	newGame.Host = host
 	newGame.GameStatus = STATUS_LOBBY
+	newGame.Ranked = ranked
+	newGame.TimeLimits = DefaultTimeLimits()
 	newGame.Persister = persister
+	return newGame
+}
 
-	err = persister.PersistGame(newGame)
-	if err != nil {
-		utils.LogMessage(err.Error(), utils.RESISTANCE_LOG_PATH)
+// ValidateTitle validates the title someone wants to give a game.
+func ValidateTitle(gameTitle string) error {
+	if strings.TrimSpace(gameTitle) == "" {
+		return errors.New("Game name can not be empty.")
 	}
 
-	return newGame
+	if utf8.RuneCountInString(gameTitle) > MAX_TITLE_LENGTH {
+		return errors.New("Game name must be at most " + strconv.Itoa(MAX_TITLE_LENGTH) + " characters long.")
+	}
+
+	for _, character := range gameTitle {
+		if unicode.IsControl(character) {
+			return errors.New("Game name can not contain control characters.")
+		}
+	}
+
+	return nil
 }
 
 func (game *Game) GetUsers() []*users.User {
@@ -96,6 +149,43 @@ func (game *Game) AddPlayer(user *users.User) {
 	}
 }
 
+// AddBot adds the given bot user as a player to the game, played by the
+// given strategy.
+func (game *Game) AddBot(user *users.User, strategy Strategy) {
+	player := game.getPlayer(user.UserId)
+
+	if player.IsValid() {
+		// A bot taken out of the lobby and put back in
+		player.Strategy = strategy
+		if player.GetConnections() <= 0 {
+			player.AddConnection()
+		}
+	} else {
+		game.Players = append(game.Players, NewBot(game, user, strategy))
+	}
+}
+
+// GetBots gets the players of the game that are bots.
+func (game *Game) GetBots() []*Player {
+	bots := make([]*Player, 0)
+	for _, player := range game.Players {
+		if player.IsValid() && player.IsBot() && player.GetConnections() > 0 {
+			bots = append(bots, player)
+		}
+	}
+	return bots
+}
+
+// GetPlayer gets the player of the game that is the given user. Returns
+// nil if the user isn't playing.
+func (game *Game) GetPlayer(user *users.User) *Player {
+	player := game.getPlayer(user.UserId)
+	if !player.IsValid() {
+		return nil
+	}
+	return player
+}
+
 // PlayerDisconnect handles when a player disconnects.
 // The number of connections on a player indicate
 // how many players of that user is connected. When one
@@ -0,0 +1,113 @@
+package game
+
+// GameHistory is the record of a game anyone can look at. Who played which
+// card on a mission stays secret, as do the roles and what the spies said
+// among themselves until the game is over.
+type GameHistory struct {
+	GameId   int                      `json:"gameId"`
+	Title    string                   `json:"title"`
+	Status   string                   `json:"status"`
+	Winner   string                   `json:"winner"`
+	Missions []MissionHistory         `json:"missions"`
+	Roles    map[string]string        `json:"roles"`
+	Chat     []map[string]interface{} `json:"chat"`
+}
+
+// MissionHistory is one team proposal, and the mission that followed if
+// the team was approved. A mission number shows up once for every team
+// proposed for it. TimedOut is everyone who ran out of time on it and had
+// their move made for them.
+type MissionHistory struct {
+	MissionNum int             `json:"missionNum"`
+	Leader     string          `json:"leader"`
+	Team       []string        `json:"team"`
+	Votes      map[string]bool `json:"votes"`
+	Approved   bool            `json:"approved"`
+	Result     string          `json:"result"`
+	NumFails   int             `json:"numFails"`
+	TimedOut   []string        `json:"timedOut"`
+}
+
+// GetHistory builds up the public record of the game.
+func (game *Game) GetHistory() *GameHistory {
+	history := new(GameHistory)
+	history.GameId = game.GameId
+	history.Title = game.Title
+	history.Status = game.GameStatus
+	history.Missions = make([]MissionHistory, 0)
+	history.Roles = make(map[string]string)
+	history.Chat = game.GetChat(nil)
+
+	for _, mission := range game.Missions {
+		history.Missions = append(history.Missions, game.getMissionHistory(mission))
+	}
+
+	if game.GameStatus == STATUS_DONE {
+		_, history.Winner = game.IsGameOver()
+		for _, player := range game.Players {
+			switch {
+			case player.Role == ROLE_RESISTANCE:
+				history.Roles[player.User.Username] = ROLE_RESISTANCE_NAME
+			case player.Role == ROLE_SPY:
+				history.Roles[player.User.Username] = ROLE_SPY_NAME
+			}
+		}
+	}
+
+	return history
+}
+
+// getMissionHistory builds up the public record of one mission.
+func (game *Game) getMissionHistory(mission *Mission) MissionHistory {
+	missionHistory := MissionHistory{
+		MissionNum: mission.MissionNum,
+		Team:       make([]string, 0),
+		Votes:      make(map[string]bool),
+		TimedOut:   make([]string, 0)}
+
+	if mission.Leader != nil {
+		missionHistory.Leader = mission.Leader.Username
+	}
+
+	timedOut := make(map[int]bool)
+	if mission.TeamTimedOut && mission.Leader != nil {
+		timedOut[mission.Leader.UserId] = true
+	}
+	for userId := range mission.TimedOutVotes {
+		timedOut[userId] = true
+	}
+	for userId := range mission.TimedOutOutcomes {
+		timedOut[userId] = true
+	}
+	for _, player := range append(game.Players, game.Replaced...) {
+		if timedOut[player.User.UserId] {
+			missionHistory.TimedOut = append(missionHistory.TimedOut, player.User.Username)
+		}
+	}
+
+	for userId := range mission.Team {
+		player := game.lookupPlayer(userId)
+		if player.IsValid() {
+			missionHistory.Team = append(missionHistory.Team, player.User.Username)
+		}
+	}
+
+	for userId, vote := range mission.Votes {
+		player := game.lookupPlayer(userId)
+		if player.IsValid() {
+			missionHistory.Votes[player.User.Username] = vote == VOTE_ALLOW
+		}
+	}
+
+	missionHistory.Approved = len(mission.Votes) == len(game.Players) && mission.IsTeamApproved()
+	switch {
+	case mission.Winner == WINNER_RESISTANCE:
+		missionHistory.Result = WINNER_RESISTANCE_NAME
+		missionHistory.NumFails = mission.getNumFails()
+	case mission.Winner == WINNER_SPY:
+		missionHistory.Result = WINNER_SPY_NAME
+		missionHistory.NumFails = mission.getNumFails()
+	}
+
+	return missionHistory
+}
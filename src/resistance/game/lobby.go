@@ -0,0 +1,83 @@
+package game
+
+import (
+	"errors"
+	"resistance/users"
+	"resistance/utils"
+)
+
+// CanJoinLobby checks that the given user can take a seat in the lobby.
+// Players already in the lobby can always come back, unless the host
+// kicked them out.
+func (game *Game) CanJoinLobby(user *users.User) error {
+	switch {
+	case game.IsKicked(user):
+		return errors.New("The host removed you from this game.")
+	case game.Locked && !game.IsPlayer(user):
+		return errors.New("The host has locked this game.")
+	}
+	return nil
+}
+
+// KickPlayer takes the given player out of the lobby, and keeps them from
+// coming back. The player is kept in Kicked.
+func (game *Game) KickPlayer(player *Player) {
+	for index, seatedPlayer := range game.Players {
+		if seatedPlayer == player {
+			game.Players = append(game.Players[:index], game.Players[index+1:]...)
+			break
+		}
+	}
+	game.Kicked = append(game.Kicked, player)
+
+	err := game.Persister.PersistKick(player)
+	if err != nil {
+		utils.LogMessage(err.Error(), utils.RESISTANCE_LOG_PATH)
+	}
+}
+
+// IsKicked returns whether the host kicked the given user out of the lobby.
+func (game *Game) IsKicked(user *users.User) bool {
+	for _, player := range game.Kicked {
+		if player.User.UserId == user.UserId {
+			return true
+		}
+	}
+	return false
+}
+
+// SetSeatOrder seats the players with the given user ids in the given
+// order. Leaders take turns in seat order. Anyone left out keeps their
+// place relative to each other, after everyone who was given a seat.
+func (game *Game) SetSeatOrder(userIds []int) {
+	seated := make(map[int]bool)
+	seatedPlayers := make([]*Player, 0, len(game.Players))
+	for _, userId := range userIds {
+		player := game.getPlayer(userId)
+		if player.IsValid() && !seated[userId] {
+			seated[userId] = true
+			seatedPlayers = append(seatedPlayers, player)
+		}
+	}
+	for _, player := range game.Players {
+		if !seated[player.User.UserId] {
+			seatedPlayers = append(seatedPlayers, player)
+		}
+	}
+	game.Players = seatedPlayers
+
+	err := game.Persister.PersistGame(game)
+	if err != nil {
+		utils.LogMessage(err.Error(), utils.RESISTANCE_LOG_PATH)
+	}
+}
+
+// GetSeat gets the seat of the given player, or -1 if they have none.
+func (game *Game) GetSeat(player *Player) int {
+	for seat, seatedPlayer := range game.Players {
+		if seatedPlayer == player {
+			return seat
+		}
+	}
+	return -1
+}
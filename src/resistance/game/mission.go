@@ -33,6 +33,11 @@ type Mission struct {
 	Winner     string
 	Team       map[int]string
 	Votes      map[int]string
+
+	// Which moves were made for players who ran out of time
+	TeamTimedOut     bool
+	TimedOutVotes    map[int]bool
+	TimedOutOutcomes map[int]bool
 }
 
 func (mission *Mission) GetGame() *Game {
@@ -66,6 +71,8 @@ func NewMission(currentGame *Game) *Mission {
 	newMission.Winner = WINNER_NONE
 	newMission.Team = make(map[int]string)
 	newMission.Votes = make(map[int]string)
+	newMission.TimedOutVotes = make(map[int]bool)
+	newMission.TimedOutOutcomes = make(map[int]bool)
 
 	currentGame.Missions = append(currentGame.Missions, newMission)
 
@@ -77,6 +84,12 @@ func NewMission(currentGame *Game) *Mission {
 	return newMission
 }
 
+// LoadMission adds a mission read back from the DB to the given game.
+func LoadMission(currentGame *Game, mission *Mission) {
+	mission.setGame(currentGame)
+	currentGame.Missions = append(currentGame.Missions, mission)
+}
+
 // CreateTeam creates the team for this mission with the
 // given list of users
 func (mission *Mission) CreateTeam(team []*users.User) {
@@ -100,6 +113,30 @@ func (mission *Mission) AddVote(user *users.User, vote bool) {
 	}
 }
 
+// replaceUser hands everything the given user has done on this mission,
+// and has yet to do, to the user replacing them.
+func (mission *Mission) replaceUser(user *users.User, replacement *users.User) {
+	if mission.Leader != nil && mission.Leader.UserId == user.UserId {
+		mission.Leader = replacement
+	}
+	if outcome, ok := mission.Team[user.UserId]; ok {
+		delete(mission.Team, user.UserId)
+		mission.Team[replacement.UserId] = outcome
+	}
+	if vote, ok := mission.Votes[user.UserId]; ok {
+		delete(mission.Votes, user.UserId)
+		mission.Votes[replacement.UserId] = vote
+	}
+	if mission.TimedOutVotes[user.UserId] {
+		delete(mission.TimedOutVotes, user.UserId)
+		mission.TimedOutVotes[replacement.UserId] = true
+	}
+	if mission.TimedOutOutcomes[user.UserId] {
+		delete(mission.TimedOutOutcomes, user.UserId)
+		mission.TimedOutOutcomes[replacement.UserId] = true
+	}
+}
+
 // IsAllVotesCollected returns whether the voting is complete
 // and all the votes for this mission are in. We need to make
 // sure we have as many votes as there are players in the game.
@@ -203,9 +240,9 @@ func (mission *Mission) GetMissionInfo() map[string]interface{} {
 
 	teamUsernames := make([]string, 0)
 	for userId, _ := range mission.Team {
-		user := users.LookupUserById(userId)
-		if user.IsValidUser() {
-			teamUsernames = append(teamUsernames, user.Username)
+		player := mission.GetGame().lookupPlayer(userId)
+		if player.IsValid() {
+			teamUsernames = append(teamUsernames, player.User.Username)
 		}
 	}
 	missionInfo["team"] = teamUsernames
@@ -3,4 +3,7 @@ package game
 type GamePersistor interface {
 	PersistGame(*Game) error
 	PersistMission(*Mission) error
+	PersistChatMessage(*ChatMessage) error
+	PersistReplacement(replaced *Player, replacement *Player) error
+	PersistKick(*Player) error
 }
@@ -2,6 +2,7 @@ package game
 
 import (
 	"resistance/users"
+	"time"
 )
 
 const (
@@ -21,7 +22,10 @@ type Player struct {
 	game        *Game
 	User        *users.User
 	Role        string
+	Strategy    Strategy
 	connections int
+	// When the player's last connection went away
+	leftAt time.Time
 }
 
 func (player *Player) GetGame() *Game {
@@ -38,12 +42,20 @@ func (player *Player) AddConnection() {
 
 func (player *Player) RemoveConnection() {
 	player.connections -= 1
+	if player.connections == 0 {
+		player.leftAt = time.Now()
+	}
 }
 
 func (player *Player) GetConnections() int {
 	return player.connections
 }
 
+// IsBot determines whether the player is a bot, played by its strategy.
+func (player *Player) IsBot() bool {
+	return player.Strategy != nil
+}
+
 func (player *Player) IsValid() bool {
 	return player.User != nil && player.GetGame() != nil
 }
@@ -56,3 +68,13 @@ func NewPlayer(currentGame *Game, user *users.User) *Player {
 
 	return newPlayer
 }
+
+// NewBot creates a player played by the given strategy. Bots never go
+// anywhere, so they always have a connection.
+func NewBot(currentGame *Game, user *users.User, strategy Strategy) *Player {
+	newPlayer := NewPlayer(currentGame, user)
+	newPlayer.Strategy = strategy
+	newPlayer.AddConnection()
+
+	return newPlayer
+}
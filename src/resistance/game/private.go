@@ -0,0 +1,148 @@
+package game
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"resistance/users"
+	"resistance/utils"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	INVITE_CODE_BYTES        = 8
+	PASSWORD_SALT_BYTES      = 16
+	MAX_GAME_PASSWORD_LENGTH = 64
+
+	// Password hashes are kept as the salt and the hash, hex encoded
+	PASSWORD_HASH_SEPARATOR = ":"
+)
+
+// ErrNotInvited is the error for someone trying to get into a private game
+// without its invite code or password.
+var ErrNotInvited = errors.New("This game is private. You need an invite link or its password to get in.")
+
+// ValidateGamePassword validates the password someone wants to give a
+// private game. No password is fine, the invite link is enough.
+func ValidateGamePassword(password string) error {
+	if utf8.RuneCountInString(password) > MAX_GAME_PASSWORD_LENGTH {
+		return errors.New("Game password must be at most " + strconv.Itoa(MAX_GAME_PASSWORD_LENGTH) + " characters long.")
+	}
+	return nil
+}
+
+// NewPrivateGame creates a game that is left out of the lobby list, so only
+// people with its invite code, or its password if it is given one, can get
+// in. The game is private from the moment it is first persisted. Returns an
+// error, and persists nothing, if the game can't be set up.
+func NewPrivateGame(gameTitle string, host *users.User, ranked bool, password string, persister GamePersistor) (*Game, error) {
+	newGame := newLobbyGame(gameTitle, host, ranked, persister)
+	err := newGame.makePrivate(password)
+	if err != nil {
+		return nil, err
+	}
+
+	err = persister.PersistGame(newGame)
+	if err != nil {
+		return nil, err
+	}
+	return newGame, nil
+}
+
+// makePrivate gives the game an invite code, and the given password if
+// there is one, without persisting it.
+func (game *Game) makePrivate(password string) error {
+	inviteCode, err := generateRandomHex(INVITE_CODE_BYTES)
+	if err != nil {
+		return err
+	}
+	game.Private = true
+	game.InviteCode = inviteCode
+
+	if password != "" {
+		salt, err := generateRandomHex(PASSWORD_SALT_BYTES)
+		if err != nil {
+			return err
+		}
+		game.PasswordHash = salt + PASSWORD_HASH_SEPARATOR + hashGamePassword(salt, password)
+	}
+	return nil
+}
+
+// RegenerateInviteCode gives the game a new invite code, so the old invite
+// link stops working. Whoever already got in with it can still come back.
+func (game *Game) RegenerateInviteCode() error {
+	inviteCode, err := generateRandomHex(INVITE_CODE_BYTES)
+	if err != nil {
+		return err
+	}
+	game.InviteCode = inviteCode
+
+	err = game.Persister.PersistGame(game)
+	if err != nil {
+		utils.LogMessage(err.Error(), utils.RESISTANCE_LOG_PATH)
+	}
+	return nil
+}
+
+// Admit lets the given user into the game if it is public, if they are
+// already in it, or if they have the right invite code or password. Whoever
+// gets in is remembered until the game server restarts, so the game page
+// can connect without them.
+func (game *Game) Admit(user *users.User, inviteCode string, password string) error {
+	if game.IsAdmitted(user) {
+		return nil
+	}
+
+	validInviteCode := inviteCode != "" && subtle.ConstantTimeCompare([]byte(inviteCode), []byte(game.InviteCode)) == 1
+	if !validInviteCode && !game.isPassword(password) {
+		return ErrNotInvited
+	}
+
+	if game.admitted == nil {
+		game.admitted = make(map[int]bool)
+	}
+	game.admitted[user.UserId] = true
+	return nil
+}
+
+// IsAdmitted returns whether the given user can get into the game, to play
+// or to watch, without an invite code or password.
+func (game *Game) IsAdmitted(user *users.User) bool {
+	return !game.Private ||
+		(game.Host != nil && game.Host.UserId == user.UserId) ||
+		game.IsPlayer(user) ||
+		game.admitted[user.UserId]
+}
+
+// isPassword checks the given password against the game's password, if it
+// has one.
+func (game *Game) isPassword(password string) bool {
+	parts := strings.SplitN(game.PasswordHash, PASSWORD_HASH_SEPARATOR, 2)
+	if password == "" || len(parts) != 2 {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashGamePassword(parts[0], password)), []byte(parts[1])) == 1
+}
+
+// hashGamePassword hashes the given password with the given salt, so the
+// games table doesn't give game passwords away.
+func hashGamePassword(salt string, password string) string {
+	hash := sha256.Sum256([]byte(salt + password))
+	return hex.EncodeToString(hash[:])
+}
+
+// generateRandomHex creates a cryptographically random hex string of the
+// given number of bytes.
+func generateRandomHex(numBytes int) (string, error) {
+	randomBytes := make([]byte, numBytes)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(randomBytes), nil
+}
@@ -0,0 +1,153 @@
+package game
+
+import (
+	"errors"
+	"resistance/users"
+	"resistance/utils"
+	"time"
+)
+
+// IsPaused returns whether the game is waiting on players who left in the
+// middle of it to come back.
+func (game *Game) IsPaused() bool {
+	return game.GameStatus == STATUS_IN_PROGRESS && game.Validate() != nil
+}
+
+// IsFinished returns whether the game is over, played to the end or not.
+func (game *Game) IsFinished() bool {
+	return game.GameStatus == STATUS_DONE || game.GameStatus == STATUS_ABANDONED
+}
+
+// GetMissingPlayers gets the players the game is waiting on.
+func (game *Game) GetMissingPlayers() []*Player {
+	missingPlayers := make([]*Player, 0)
+	for _, player := range game.Players {
+		if player.GetConnections() <= 0 {
+			missingPlayers = append(missingPlayers, player)
+		}
+	}
+	return missingPlayers
+}
+
+// GetGraceDeadline gets when the given player, who left in the middle of
+// the game, is out of time to come back. The grace period runs on the
+// game's clock from when the game was paused, see timers.go, but a player
+// who left after that gets the whole grace period from when they left.
+func (game *Game) GetGraceDeadline(player *Player) time.Time {
+	_, deadline := game.GetDeadline()
+	playerDeadline := player.leftAt.Add(game.GetGracePeriod())
+	if playerDeadline.After(deadline) {
+		return playerDeadline
+	}
+	return deadline
+}
+
+// IsGracePeriodOver returns whether the given player the game is waiting on
+// has had as long as they get to come back. After that the host can replace
+// them, or give up on the game. There is no grace period if the time limit
+// is 0.
+func (game *Game) IsGracePeriodOver(player *Player) bool {
+	phaseKey, _ := game.GetDeadline()
+	return game.IsPaused() && player.GetConnections() <= 0 &&
+		phaseKey == game.GetPhaseKey() && !time.Now().Before(game.GetGraceDeadline(player))
+}
+
+// GetPlayersOutOfTime gets the players the game is waiting on whose grace
+// period is over.
+func (game *Game) GetPlayersOutOfTime() []*Player {
+	outOfTime := make([]*Player, 0)
+	for _, player := range game.GetMissingPlayers() {
+		if game.IsGracePeriodOver(player) {
+			outOfTime = append(outOfTime, player)
+		}
+	}
+	return outOfTime
+}
+
+// HandOverHost makes the first player who is still here the host, if the
+// host is one of the players the game is waiting on and is out of time to
+// come back, so the game isn't stuck waiting on them to replace players or
+// abandon it. Returns whether the host changed.
+func (game *Game) HandOverHost() bool {
+	host := game.GetPlayer(game.Host)
+	if host == nil || !game.IsGracePeriodOver(host) {
+		return false
+	}
+
+	for _, player := range game.Players {
+		if !player.IsBot() && player.GetConnections() > 0 {
+			game.Host = player.User
+			err := game.Persister.PersistGame(game)
+			if err != nil {
+				utils.LogMessage(err.Error(), utils.RESISTANCE_LOG_PATH)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// ReplacePlayer gives the seat of the given player, role and all, to the
+// given user, played by the given strategy if the user is a bot. Whatever
+// the player was doing on the current mission is now the replacement's to
+// do. The player is kept in Replaced, so the missions they played are still
+// on record.
+func (game *Game) ReplacePlayer(player *Player, user *users.User, strategy Strategy) (*Player, error) {
+	if game.IsPlayer(user) {
+		return nil, errors.New(user.Username + " is already playing in this game.")
+	}
+
+	var replacement *Player
+	if strategy != nil {
+		replacement = NewBot(game, user, strategy)
+	} else {
+		replacement = NewPlayer(game, user)
+	}
+	replacement.Role = player.Role
+
+	for index, seat := range game.Players {
+		if seat == player {
+			game.Players[index] = replacement
+		}
+	}
+	game.Replaced = append(game.Replaced, player)
+
+	currentMission := game.GetCurrentMission()
+	if currentMission != nil {
+		currentMission.replaceUser(player.User, user)
+	}
+
+	err := game.Persister.PersistReplacement(player, replacement)
+	if err != nil {
+		utils.LogMessage(err.Error(), utils.RESISTANCE_LOG_PATH)
+	}
+
+	return replacement, nil
+}
+
+// AbandonGame ends the game without a winner, when the players it was
+// waiting on aren't coming back.
+func (game *Game) AbandonGame() {
+	game.GameStatus = STATUS_ABANDONED
+
+	err := game.Persister.PersistGame(game)
+	if err != nil {
+		utils.LogMessage(err.Error(), utils.RESISTANCE_LOG_PATH)
+	}
+}
+
+// lookupPlayer gets the player with the given user id, including players
+// who were replaced, for going over what happened in the game.
+func (game *Game) lookupPlayer(userId int) *Player {
+	player := game.getPlayer(userId)
+	if player.IsValid() {
+		return player
+	}
+
+	for _, replacedPlayer := range game.Replaced {
+		if replacedPlayer.User.UserId == userId {
+			return replacedPlayer
+		}
+	}
+	return DUMMY_PLAYER
+}
@@ -0,0 +1,56 @@
+package game
+
+import (
+	"resistance/users"
+	"sort"
+)
+
+// Spectator is someone watching the game without playing in it. Spectators
+// are only known while they are connected, so they are never persisted.
+type Spectator struct {
+	User        *users.User
+	connections int
+}
+
+// AddSpectator adds the given user as a spectator of the game.
+func (game *Game) AddSpectator(user *users.User) {
+	if game.spectators == nil {
+		game.spectators = make(map[int]*Spectator)
+	}
+
+	spectator := game.spectators[user.UserId]
+	if spectator == nil {
+		spectator = &Spectator{User: user}
+		game.spectators[user.UserId] = spectator
+	}
+	spectator.connections += 1
+}
+
+// SpectatorDisconnect handles when one of a spectator's connections goes
+// away. Once they have none left they are no longer watching.
+func (game *Game) SpectatorDisconnect(user *users.User) {
+	spectator := game.spectators[user.UserId]
+	if spectator == nil {
+		return
+	}
+
+	spectator.connections -= 1
+	if spectator.connections <= 0 {
+		delete(game.spectators, user.UserId)
+	}
+}
+
+// IsSpectator returns whether the given user is watching the game.
+func (game *Game) IsSpectator(user *users.User) bool {
+	return game.spectators[user.UserId] != nil
+}
+
+// GetSpectatorUsernames gets the usernames of everyone watching the game.
+func (game *Game) GetSpectatorUsernames() []string {
+	usernames := make([]string, 0)
+	for _, spectator := range game.spectators {
+		usernames = append(usernames, spectator.User.Username)
+	}
+	sort.Strings(usernames)
+	return usernames
+}
@@ -0,0 +1,222 @@
+package game
+
+import (
+	"resistance/users"
+)
+
+const (
+	PHASE_LOBBY          = "lobby"
+	PHASE_TEAM_SELECTION = "teamSelection"
+	PHASE_VOTING         = "voting"
+	PHASE_MISSION        = "mission"
+	PHASE_OVER           = "over"
+)
+
+// GameState is everything a player needs to know to draw the game from
+// scratch. Anything only some players are allowed to know is in You.
+type GameState struct {
+	GameId          int                      `json:"gameId"`
+	Title           string                   `json:"title"`
+	Ranked          bool                     `json:"ranked"`
+	Phase           string                   `json:"phase"`
+	Host            string                   `json:"host"`
+	Players         []PlayerState            `json:"players"`
+	Spectators      []string                 `json:"spectators"`
+	Leader          string                   `json:"leader"`
+	MissionNum      int                      `json:"missionNum"`
+	TeamSize        int                      `json:"teamSize"`
+	Team            []string                 `json:"team"`
+	Votes           map[string]bool          `json:"votes"`
+	Missions        []map[string]interface{} `json:"missions"`
+	Winner          string                   `json:"winner"`
+	SpyOdds         map[string]float64       `json:"spyOdds"`
+	SpyChat         bool                     `json:"spyChat"`
+	Locked          bool                     `json:"locked"`
+	Private         bool                     `json:"private"`
+	TimeLimits      TimeLimits               `json:"timeLimits"`
+	TimeLeft        int                      `json:"timeLeft"`
+	GracePeriodOver bool                     `json:"gracePeriodOver"`
+	Chat            []map[string]interface{} `json:"chat"`
+	You             PrivateState             `json:"you"`
+}
+
+// GameSummary is what the lobby lists about a game.
+type GameSummary struct {
+	GameId       int
+	Title        string
+	HostUsername string
+	Ranked       bool
+}
+
+// PlayerState is what everyone can see about a player.
+type PlayerState struct {
+	UserId    int    `json:"userId"`
+	Username  string `json:"username"`
+	Connected bool   `json:"connected"`
+	IsBot     bool   `json:"isBot"`
+	IsHost    bool   `json:"isHost"`
+	IsLeader  bool   `json:"isLeader"`
+	IsOnTeam  bool   `json:"isOnTeam"`
+	HasVoted  bool   `json:"hasVoted"`
+	OutOfTime bool   `json:"outOfTime"`
+}
+
+// PrivateState is what only the player the state is for knows.
+type PrivateState struct {
+	UserId              int      `json:"userId"`
+	Role                string   `json:"role"`
+	Spies               []string `json:"spies"`
+	IsLeader            bool     `json:"isLeader"`
+	IsOnTeam            bool     `json:"isOnTeam"`
+	HasVoted            bool     `json:"hasVoted"`
+	HasSubmittedOutcome bool     `json:"hasSubmittedOutcome"`
+	InviteCode          string   `json:"inviteCode,omitempty"`
+}
+
+// GetPhase works out what the game is waiting on.
+func (game *Game) GetPhase() string {
+	switch {
+	case game.GameStatus == STATUS_LOBBY:
+		return PHASE_LOBBY
+	case game.IsFinished():
+		return PHASE_OVER
+	}
+
+	currentMission := game.GetCurrentMission()
+	switch {
+	case currentMission == nil || len(currentMission.Team) == 0:
+		return PHASE_TEAM_SELECTION
+	case !currentMission.IsAllVotesCollected():
+		return PHASE_VOTING
+	default:
+		return PHASE_MISSION
+	}
+}
+
+// GetState builds the state of the game as the given user is allowed to
+// see it. Votes are public as soon as they are cast, but who voted how on a
+// mission and everyone's roles are not, apart from spies knowing each other.
+// The spy odds only use what is public, so they are the same for everyone.
+func (game *Game) GetState(viewer *users.User) *GameState {
+	state := new(GameState)
+	state.GameId = game.GameId
+	state.Title = game.Title
+	state.Ranked = game.Ranked
+	state.Phase = game.GetPhase()
+	if game.Host != nil {
+		state.Host = game.Host.Username
+	}
+	state.Team = make([]string, 0)
+	state.Votes = make(map[string]bool)
+	state.Missions = game.GetMissionInfo()
+	state.SpyOdds = game.GetSpyOdds()
+	state.SpyChat = game.SpyChat
+	state.Locked = game.Locked
+	state.Private = game.Private
+	state.TimeLimits = game.TimeLimits
+	state.TimeLeft = game.GetTimeLeft()
+	state.GracePeriodOver = len(game.GetPlayersOutOfTime()) > 0
+	state.Chat = game.GetChat(viewer)
+
+	currentMission := game.GetCurrentMission()
+	if game.GameStatus != STATUS_LOBBY && currentMission != nil {
+		state.MissionNum = currentMission.MissionNum
+		state.TeamSize = currentMission.GetCurrentMissionTeamSize()
+		if currentMission.Leader != nil {
+			state.Leader = currentMission.Leader.Username
+		}
+	}
+
+	state.Spectators = game.GetSpectatorUsernames()
+	state.Players = make([]PlayerState, 0)
+	for _, player := range game.Players {
+		if !player.IsValid() {
+			continue
+		}
+		// Players who left the lobby are gone once the game starts
+		if game.GameStatus == STATUS_LOBBY && player.GetConnections() <= 0 {
+			continue
+		}
+		state.Players = append(state.Players, game.getPlayerState(player, currentMission))
+	}
+
+	for _, playerState := range state.Players {
+		if playerState.IsOnTeam {
+			state.Team = append(state.Team, playerState.Username)
+		}
+	}
+
+	if currentMission != nil && game.GameStatus == STATUS_IN_PROGRESS {
+		for userId, vote := range currentMission.Votes {
+			player := game.getPlayer(userId)
+			if player.IsValid() {
+				state.Votes[player.User.Username] = vote == VOTE_ALLOW
+			}
+		}
+	}
+
+	if game.GameStatus == STATUS_DONE {
+		_, state.Winner = game.IsGameOver()
+	}
+
+	if viewer != nil {
+		state.You = game.getPrivateState(viewer, currentMission)
+	}
+
+	return state
+}
+
+// getPlayerState builds up what everyone can see about the given player.
+func (game *Game) getPlayerState(player *Player, currentMission *Mission) PlayerState {
+	playerState := PlayerState{
+		UserId:    player.User.UserId,
+		Username:  player.User.Username,
+		Connected: player.GetConnections() > 0,
+		IsBot:     player.IsBot(),
+		IsHost:    game.Host != nil && game.Host.UserId == player.User.UserId,
+		OutOfTime: game.IsGracePeriodOver(player)}
+
+	if currentMission != nil && game.GameStatus == STATUS_IN_PROGRESS {
+		playerState.IsLeader = currentMission.Leader != nil && currentMission.Leader.UserId == player.User.UserId
+		playerState.IsOnTeam = currentMission.IsUserOnCurrentMission(player.User)
+		_, playerState.HasVoted = currentMission.Votes[player.User.UserId]
+	}
+
+	return playerState
+}
+
+// getPrivateState builds up what only the given user knows.
+func (game *Game) getPrivateState(viewer *users.User, currentMission *Mission) PrivateState {
+	privateState := PrivateState{UserId: viewer.UserId, Role: ROLE_UNINITIALIZED_NAME, Spies: make([]string, 0)}
+
+	// Only the host hands out the invite link
+	if game.Host != nil && game.Host.UserId == viewer.UserId {
+		privateState.InviteCode = game.InviteCode
+	}
+
+	player := game.getPlayer(viewer.UserId)
+	if !player.IsValid() {
+		return privateState
+	}
+
+	switch {
+	case player.Role == ROLE_RESISTANCE:
+		privateState.Role = ROLE_RESISTANCE_NAME
+	case player.Role == ROLE_SPY:
+		privateState.Role = ROLE_SPY_NAME
+		for _, otherPlayer := range game.Players {
+			if otherPlayer.Role == ROLE_SPY && otherPlayer.User.UserId != viewer.UserId {
+				privateState.Spies = append(privateState.Spies, otherPlayer.User.Username)
+			}
+		}
+	}
+
+	if currentMission != nil && game.GameStatus == STATUS_IN_PROGRESS {
+		privateState.IsLeader = currentMission.Leader != nil && currentMission.Leader.UserId == viewer.UserId
+		privateState.IsOnTeam = currentMission.IsUserOnCurrentMission(viewer)
+		_, privateState.HasVoted = currentMission.Votes[viewer.UserId]
+		privateState.HasSubmittedOutcome = privateState.IsOnTeam && currentMission.Team[viewer.UserId] != OUTCOME_NONE
+	}
+
+	return privateState
+}
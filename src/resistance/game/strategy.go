@@ -0,0 +1,80 @@
+package game
+
+import (
+	"resistance/users"
+	"sort"
+)
+
+// Strategy makes the decisions for a bot player. Every decision is made
+// from the state of the game as the bot is allowed to see it, the same
+// state a person playing would get.
+type Strategy interface {
+	// Name is what the strategy is registered under.
+	Name() string
+
+	// ProposeTeam picks the user ids of the team when the bot leads the
+	// mission. The team has to be state.TeamSize players of the game.
+	ProposeTeam(state *GameState) []int
+
+	// Vote approves or rejects the proposed team, state.Team.
+	Vote(state *GameState) bool
+
+	// MissionOutcome plays a card on a mission the bot is on. Only spies
+	// can fail a mission.
+	MissionOutcome(state *GameState) bool
+}
+
+var strategies = make(map[string]func() Strategy)
+
+// RegisterStrategy makes a strategy available to bots under the given name.
+// Meant to be called from the init function of the package of the strategy.
+func RegisterStrategy(name string, newStrategy func() Strategy) {
+	strategies[name] = newStrategy
+}
+
+// NewStrategy creates the strategy registered under the given name.
+// Returns nil if there is none.
+func NewStrategy(name string) Strategy {
+	newStrategy, ok := strategies[name]
+	if !ok {
+		return nil
+	}
+	return newStrategy()
+}
+
+// GetStrategyNames gets the names of all the registered strategies.
+func GetStrategyNames() []string {
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckTeam checks the team a strategy proposed for the current mission.
+// If it isn't the right number of different players of the game, the team
+// is filled up with the first players in order instead. Returns the team
+// and whether it was valid as proposed.
+func (game *Game) CheckTeam(proposedTeam []int) ([]*users.User, bool) {
+	teamSize := game.GetCurrentMission().GetCurrentMissionTeamSize()
+
+	team := make([]*users.User, 0)
+	onTeam := make(map[int]bool)
+	for _, userId := range proposedTeam {
+		player := game.getPlayer(userId)
+		if player.IsValid() && !onTeam[userId] && len(team) < teamSize {
+			team = append(team, player.User)
+			onTeam[userId] = true
+		}
+	}
+
+	valid := len(team) == len(proposedTeam) && len(team) == teamSize
+	for _, player := range game.Players {
+		if len(team) < teamSize && player.IsValid() && !onTeam[player.User.UserId] {
+			team = append(team, player.User)
+			onTeam[player.User.UserId] = true
+		}
+	}
+	return team, valid
+}
@@ -0,0 +1,134 @@
+package game
+
+import (
+	"errors"
+	"math/rand"
+	"resistance/users"
+	"strconv"
+	"time"
+)
+
+const (
+	DEFAULT_TEAM_TIME_LIMIT    = 120
+	DEFAULT_VOTE_TIME_LIMIT    = 60
+	DEFAULT_MISSION_TIME_LIMIT = 60
+
+	// How long players who leave in the middle of a game have to come back
+	DEFAULT_DISCONNECT_TIME_LIMIT = 120
+
+	// Time limits are in seconds, and 0 means no limit
+	MIN_TIME_LIMIT = 10
+	MAX_TIME_LIMIT = 600
+
+	// While the game is paused, its clock counts down the grace period
+	// of the players it is waiting on instead
+	PAUSED_PHASE_KEY_SUFFIX = ":paused"
+)
+
+// TimeLimits are how long, in seconds, players get for each move before
+// it is made for them, and how long players who left get to come back
+// before the host can replace them.
+type TimeLimits struct {
+	Team       int `json:"team"`
+	Vote       int `json:"vote"`
+	Mission    int `json:"mission"`
+	Disconnect int `json:"disconnect"`
+}
+
+// DefaultTimeLimits are the time limits new games start with.
+func DefaultTimeLimits() TimeLimits {
+	return TimeLimits{
+		Team:       DEFAULT_TEAM_TIME_LIMIT,
+		Vote:       DEFAULT_VOTE_TIME_LIMIT,
+		Mission:    DEFAULT_MISSION_TIME_LIMIT,
+		Disconnect: DEFAULT_DISCONNECT_TIME_LIMIT}
+}
+
+// Validate checks that every limit is either off or in range.
+func (limits TimeLimits) Validate() error {
+	for _, limit := range []int{limits.Team, limits.Vote, limits.Mission, limits.Disconnect} {
+		if limit != 0 && (limit < MIN_TIME_LIMIT || limit > MAX_TIME_LIMIT) {
+			return errors.New("Time limits must be between " + strconv.Itoa(MIN_TIME_LIMIT) +
+				" and " + strconv.Itoa(MAX_TIME_LIMIT) + " seconds, or 0 for no limit.")
+		}
+	}
+	return nil
+}
+
+// GetTimeLimit gets how long players get for the given phase, or 0 if
+// they can take as long as they like.
+func (game *Game) GetTimeLimit(phase string) time.Duration {
+	var seconds int
+	switch phase {
+	case PHASE_TEAM_SELECTION:
+		seconds = game.TimeLimits.Team
+	case PHASE_VOTING:
+		seconds = game.TimeLimits.Vote
+	case PHASE_MISSION:
+		seconds = game.TimeLimits.Mission
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// GetGracePeriod gets how long players who left in the middle of the game
+// get to come back, or 0 if the game waits for them for as long as it takes.
+func (game *Game) GetGracePeriod() time.Duration {
+	return time.Duration(game.TimeLimits.Disconnect) * time.Second
+}
+
+// GetPhaseKey identifies the move the game is waiting on. Every team
+// proposal is a mission of its own, so the key changes with every move
+// that moves the game on, and whenever the game is paused.
+func (game *Game) GetPhaseKey() string {
+	phaseKey := strconv.Itoa(len(game.Missions)) + ":" + game.GetPhase()
+	if game.IsPaused() {
+		phaseKey += PAUSED_PHASE_KEY_SUFFIX
+	}
+	return phaseKey
+}
+
+// SetDeadline sets when the move the game is waiting on will be made for
+// whoever hasn't made it. The deadline only lives in memory, on the game
+// server; a restart gives everyone the full time again.
+func (game *Game) SetDeadline(phaseKey string, deadline time.Time) {
+	game.deadlinePhaseKey = phaseKey
+	game.deadline = deadline
+}
+
+// ClearDeadline takes the deadline off the game.
+func (game *Game) ClearDeadline() {
+	game.deadlinePhaseKey = ""
+	game.deadline = time.Time{}
+}
+
+// GetDeadline gets the deadline of the game, and the phase key it was set
+// for. The phase key is "" if there is no deadline.
+func (game *Game) GetDeadline() (string, time.Time) {
+	return game.deadlinePhaseKey, game.deadline
+}
+
+// GetTimeLeft gets how many seconds are left until the deadline, or 0 if
+// there isn't one.
+func (game *Game) GetTimeLeft() int {
+	if game.deadlinePhaseKey == "" {
+		return 0
+	}
+
+	timeLeft := int((game.deadline.Sub(time.Now()) + time.Second - 1) / time.Second)
+	if timeLeft < 0 {
+		return 0
+	}
+	return timeLeft
+}
+
+// GetRandomTeam picks a team of the right size for the current mission at
+// random, for a leader who ran out of time.
+func (game *Game) GetRandomTeam() []*users.User {
+	teamSize := game.GetCurrentMission().GetCurrentMissionTeamSize()
+
+	team := make([]*users.User, 0)
+	for _, index := range rand.Perm(len(game.Players))[:teamSize] {
+		team = append(team, game.Players[index].User)
+	}
+	return team
+}
@@ -0,0 +1,152 @@
+package gameserver
+
+import (
+	"resistance/game"
+	"resistance/protocol"
+	"resistance/pubsub"
+	"resistance/users"
+	"resistance/utils"
+	"strconv"
+)
+
+const (
+	MAX_PLAYERS = 10
+
+	// Bots moving in a loop shouldn't be possible, but don't let a bad
+	// strategy hang the game's worker if it is.
+	MAX_BOT_MOVES = 100
+)
+
+// handleAddBot handles the message from the host adding a bot, played by
+// the given strategy, to the lobby.
+func handleAddBot(message map[string]interface{}, currentGame *game.Game, host *users.User, publisher pubsub.Publisher) map[string]interface{} {
+	if currentGame.Host.UserId != host.UserId {
+		return getShowTextMessage("Only the host can add bots.")
+	}
+	if currentGame.GameStatus != game.STATUS_LOBBY {
+		return getShowTextMessage("Bots can only be added before the game starts.")
+	}
+	if len(currentGame.GetUsers()) >= MAX_PLAYERS {
+		return getShowTextMessage("Game has reached maximum capacity")
+	}
+
+	strategyName, _ := message[protocol.STRATEGY_KEY].(string)
+	strategy := game.NewStrategy(strategyName)
+	if strategy == nil {
+		return getShowTextMessage("There is no such bot.")
+	}
+
+	botUser := getFreeBotUser(currentGame, strategyName)
+	if !botUser.IsValidUser() {
+		return getShowTextMessage("Error adding bot.")
+	}
+
+	utils.LogMessage("Adding bot "+botUser.Username+" to game "+strconv.Itoa(currentGame.GameId), utils.RGAME_LOG_PATH)
+	currentGame.AddBot(botUser, strategy)
+	sendMessageToSubscribers(currentGame.GameId, getPlayersMessage(currentGame), publisher)
+
+	return make(map[string]interface{})
+}
+
+// handleRemoveBot handles the message from the host taking a bot back out
+// of the lobby.
+func handleRemoveBot(message map[string]interface{}, currentGame *game.Game, host *users.User, publisher pubsub.Publisher) map[string]interface{} {
+	if currentGame.Host.UserId != host.UserId {
+		return getShowTextMessage("Only the host can remove bots.")
+	}
+	if currentGame.GameStatus != game.STATUS_LOBBY {
+		return getShowTextMessage("Bots can only be removed before the game starts.")
+	}
+
+	userId, _ := message[protocol.USER_ID_KEY].(float64)
+	for _, bot := range currentGame.GetBots() {
+		if bot.User.UserId == int(userId) {
+			// Like anyone else leaving the lobby, they are gone once the
+			// game starts
+			currentGame.PlayerDisconnect(bot.User)
+			sendMessageToSubscribers(currentGame.GameId, getPlayersMessage(currentGame), publisher)
+			break
+		}
+	}
+
+	return make(map[string]interface{})
+}
+
+// getFreeBotUser gets a bot user for the given strategy that isn't already
+// playing in the game. Bots of a strategy are numbered, and the same few
+// bot users are shared by all the games.
+func getFreeBotUser(currentGame *game.Game, strategyName string) *users.User {
+	for number := 1; number <= MAX_PLAYERS; number++ {
+		botUser := users.GetBotUser(strategyName + " " + strconv.Itoa(number))
+		if !botUser.IsValidUser() {
+			return botUser
+		}
+
+		player := currentGame.GetPlayer(botUser)
+		if player == nil || player.GetConnections() <= 0 {
+			return botUser
+		}
+	}
+	return users.UNKNOWN_USER
+}
+
+// runBots lets the bots of the game make every move they can, until the
+// game is waiting on someone who isn't a bot. Called after every message
+// handled for the game, on the game's worker, so the bots' moves are in
+// order with everyone else's.
+func runBots(currentGame *game.Game, publisher pubsub.Publisher) {
+	if currentGame.GameStatus != game.STATUS_IN_PROGRESS {
+		return
+	}
+
+	for moves := 0; moves < MAX_BOT_MOVES; {
+		// Bots wait along with everyone else while the game is paused
+		if currentGame.GameStatus != game.STATUS_IN_PROGRESS || currentGame.Validate() != nil {
+			return
+		}
+
+		moved := false
+		for _, bot := range currentGame.GetBots() {
+			if moveBot(currentGame, bot, publisher) {
+				moved = true
+				moves++
+			}
+		}
+		if !moved {
+			return
+		}
+	}
+
+	utils.LogMessage("Bots of game "+strconv.Itoa(currentGame.GameId)+" made too many moves in a row", utils.RGAME_LOG_PATH)
+}
+
+// moveBot makes the move the game is waiting on the given bot for, if any,
+// the same way a person's move is made. Returns whether it moved.
+func moveBot(currentGame *game.Game, bot *game.Player, publisher pubsub.Publisher) bool {
+	state := currentGame.GetState(bot.User)
+	message := make(map[string]interface{})
+
+	switch {
+	case state.Phase == game.PHASE_TEAM_SELECTION && state.You.IsLeader:
+		teamUsers, valid := currentGame.CheckTeam(bot.Strategy.ProposeTeam(state))
+		if !valid {
+			utils.LogMessage("Bot "+bot.User.Username+" proposed an invalid team in game "+strconv.Itoa(currentGame.GameId), utils.RGAME_LOG_PATH)
+		}
+		team := make([]interface{}, 0)
+		for _, user := range teamUsers {
+			team = append(team, strconv.Itoa(user.UserId))
+		}
+		message[protocol.TEAMS_KEY] = team
+		handleStartMission(message, currentGame, bot.User, publisher)
+	case state.Phase == game.PHASE_VOTING && !state.You.HasVoted:
+		message[protocol.VOTE_KEY] = bot.Strategy.Vote(state)
+		handleApproveTeam(message, currentGame, bot.User, publisher)
+	case state.Phase == game.PHASE_MISSION && state.You.IsOnTeam && !state.You.HasSubmittedOutcome:
+		// Only spies can fail a mission, whatever the strategy says
+		message[protocol.OUTCOME_KEY] = bot.Strategy.MissionOutcome(state) || bot.Role != game.ROLE_SPY
+		handleMissionOutcome(message, currentGame, bot.User, publisher)
+	default:
+		return false
+	}
+	return true
+}
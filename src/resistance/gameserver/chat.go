@@ -0,0 +1,134 @@
+package gameserver
+
+import (
+	"encoding/json"
+	"resistance/game"
+	"resistance/protocol"
+	"resistance/pubsub"
+	"resistance/users"
+	"resistance/utils"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// Each player can say at most CHAT_RATE_LIMIT things every
+	// CHAT_RATE_WINDOW, across all their games
+	CHAT_RATE_LIMIT  = 5
+	CHAT_RATE_WINDOW = 10 * time.Second
+)
+
+var (
+	chatLock      sync.Mutex
+	recentChats   = make(map[int][]time.Time)
+	lastChatPrune = time.Now()
+)
+
+// isChatAllowed checks that the user hasn't been saying too much lately,
+// and if not, counts what they are about to say.
+func isChatAllowed(user *users.User) bool {
+	chatLock.Lock()
+	defer chatLock.Unlock()
+
+	now := time.Now()
+
+	// Every so often forget everyone who has gone quiet
+	if now.Sub(lastChatPrune) > CHAT_RATE_WINDOW {
+		for userId, times := range recentChats {
+			if now.Sub(times[len(times)-1]) > CHAT_RATE_WINDOW {
+				delete(recentChats, userId)
+			}
+		}
+		lastChatPrune = now
+	}
+
+	recent := make([]time.Time, 0, CHAT_RATE_LIMIT)
+	for _, chatTime := range recentChats[user.UserId] {
+		if now.Sub(chatTime) <= CHAT_RATE_WINDOW {
+			recent = append(recent, chatTime)
+		}
+	}
+	if len(recent) >= CHAT_RATE_LIMIT {
+		recentChats[user.UserId] = recent
+		return false
+	}
+
+	recentChats[user.UserId] = append(recent, now)
+	return true
+}
+
+// handleChat handles a player saying something, on the channel everyone
+// reads or, if the host allowed it, the spies' own.
+func handleChat(message map[string]interface{}, currentGame *game.Game, user *users.User, publisher pubsub.Publisher) map[string]interface{} {
+	if !isChatAllowed(user) {
+		return getErrorMessage("You are chatting too fast, slow down.")
+	}
+
+	channelName, _ := message[protocol.CHANNEL_KEY].(string)
+	text, _ := message[protocol.TEXT_KEY].(string)
+	chatMessage, err := currentGame.AddChatMessage(user, channelName, text)
+	if err != nil {
+		utils.LogMessage("Chat refused in game "+strconv.Itoa(currentGame.GameId)+": "+err.Error(), utils.RGAME_LOG_PATH)
+		return getErrorMessage(err.Error())
+	}
+
+	chatMessageOut := getChatMessage(chatMessage)
+	if chatMessage.Channel == game.CHAT_CHANNEL_SPIES {
+		for _, spy := range currentGame.GetSpies() {
+			sendMessageToPlayer(currentGame.GameId, spy.User.UserId, chatMessageOut, publisher)
+		}
+	} else {
+		sendMessageToSubscribers(currentGame.GameId, chatMessageOut, publisher)
+	}
+
+	return make(map[string]interface{})
+}
+
+// handleSetSpyChat handles the host deciding, in the lobby, whether the
+// spies get a channel of their own.
+func handleSetSpyChat(message map[string]interface{}, currentGame *game.Game, host *users.User) map[string]interface{} {
+	if currentGame.Host.UserId != host.UserId {
+		return getErrorMessage("Only the host can change that.")
+	}
+	if currentGame.GameStatus != game.STATUS_LOBBY {
+		return getErrorMessage("That can only be changed before the game starts.")
+	}
+
+	enabled, ok := message[protocol.SPY_CHAT_KEY].(bool)
+	if ok {
+		currentGame.SpyChat = enabled
+		err := currentGame.Persister.PersistGame(currentGame)
+		if err != nil {
+			utils.LogMessage(err.Error(), utils.RGAME_LOG_PATH)
+		}
+	}
+
+	return make(map[string]interface{})
+}
+
+// getChatMessage builds up the message to send out something said.
+func getChatMessage(chatMessage *game.ChatMessage) map[string]interface{} {
+	chatInfo := chatMessage.GetChatInfo()
+
+	var message = make(map[string]interface{})
+	message[protocol.MESSAGE_KEY] = protocol.CHAT_MESSAGE
+	message[protocol.USERNAME_KEY] = chatInfo["username"]
+	message[protocol.CHANNEL_KEY] = chatInfo["channel"]
+	message[protocol.TEXT_KEY] = chatInfo["text"]
+	message[protocol.TIME_KEY] = chatInfo["time"]
+	return message
+}
+
+// sendMessageToPlayer sends the given message to only the given player of
+// the given game. These messages aren't numbered or kept for players
+// catching up, since they aren't for everyone.
+func sendMessageToPlayer(gameId int, userId int, message map[string]interface{}, publisher pubsub.Publisher) {
+	rawMessage, err := json.Marshal(message)
+	if err == nil {
+		err = publisher.Publish(pubsub.PlayerTopic(strconv.Itoa(gameId), userId), rawMessage)
+	}
+	if err != nil {
+		utils.LogMessage("Error publishing to player "+strconv.Itoa(userId)+" of game "+strconv.Itoa(gameId)+": "+err.Error(), utils.RGAME_LOG_PATH)
+	}
+}
@@ -0,0 +1,1004 @@
+package gameserver
+
+import (
+	"encoding/json"
+	"errors"
+	_ "resistance/bots"
+	"resistance/game"
+	"resistance/persist"
+	"resistance/protocol"
+	"resistance/pubsub"
+	"resistance/rpc"
+	"resistance/users"
+	"resistance/utils"
+	"strconv"
+)
+
+var persister *persist.Persister
+
+func init() {
+	persister = persist.NewPersister()
+}
+
+// handleCreateGame handlers the message that is sent when a
+// request is made from the HTTP module to create a new game.
+// The user making the request becomes the host.
+func handleCreateGame(body json.RawMessage) (interface{}, error) {
+	var request rpc.CreateGameRequest
+	err := rpc.Decode(body, &request)
+	if err != nil {
+		return nil, err
+	}
+
+	connectingPlayer := getUser(request.UserCookie)
+	if connectingPlayer == nil {
+		return nil, errors.New("You need to be logged in to create a game.")
+	}
+
+	err = game.ValidateTitle(request.Title)
+	if err != nil {
+		return nil, err
+	}
+	err = game.ValidateGamePassword(request.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	var newGame *game.Game
+	if request.Private {
+		newGame, err = game.NewPrivateGame(request.Title, connectingPlayer, request.Ranked, request.Password, persister)
+		if err != nil {
+			utils.LogMessage("Error creating private game: "+err.Error(), utils.RGAME_LOG_PATH)
+			return nil, errors.New("Error creating game")
+		}
+	} else {
+		newGame = game.NewGame(request.Title, connectingPlayer, request.Ranked, persister)
+	}
+	if newGame == nil || newGame.GameId <= 0 {
+		return nil, errors.New("Error creating game")
+	}
+	return &rpc.CreateGameReply{GameId: newGame.GameId, InviteCode: newGame.InviteCode}, nil
+}
+
+// handleIsValidGame takes in a game id and validates that it is
+// ok for the given user to join the given game.
+func handleIsValidGame(body json.RawMessage) (interface{}, error) {
+	var request rpc.IsValidGameRequest
+	err := rpc.Decode(body, &request)
+	if err != nil {
+		return nil, err
+	}
+
+	requestUser := getUser(request.UserCookie)
+	if requestUser == nil {
+		return nil, errors.New("You need to be logged in to join a game.")
+	}
+
+	// Error if no game id is not specified
+	if request.GameId == "" {
+		return nil, errors.New("Game not specified.")
+	}
+
+	// Error if game id can't be parsed
+	gameId, err := strconv.Atoi(request.GameId)
+	if err != nil {
+		return nil, errors.New("Game Id is not valid.")
+	}
+
+	requestedGame, err := persister.ReadGame(gameId)
+	if requestedGame != nil && err == nil {
+		// Private games are for those who were invited, to play or watch
+		err = requestedGame.Admit(requestUser, request.InviteCode, request.Password)
+		if err != nil {
+			return nil, err
+		}
+
+		gameStatus := requestedGame.GameStatus
+		switch {
+		default:
+		case requestedGame.IsFinished():
+			return nil, errors.New("Cannot join a game that is already done.")
+		case request.Spectate:
+			// anyone can watch a game that isn't over
+		case gameStatus == game.STATUS_IN_PROGRESS:
+			// make sure that the player is an actual player of the game
+			if !requestedGame.IsPlayer(requestUser) {
+				return nil, errors.New("Cannot join a game that is in progress, but you can watch it")
+			}
+		case gameStatus == game.STATUS_LOBBY:
+			// make sure we're not going over the limit of 10 players
+			if len(requestedGame.GetUsers()) >= 10 {
+				return nil, errors.New("Game has reached maximum capacity")
+			}
+			if err := requestedGame.CanJoinLobby(requestUser); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		return nil, errors.New("Game does not exist.")
+	}
+
+	// If we got here, it means we are good to go.
+	return &rpc.IsValidGameReply{GameTitle: requestedGame.Title, Private: requestedGame.Private}, nil
+}
+
+// handleGetAllGames handles the message that is sent when requesting
+// the lobby page. Private games are left out, they are only joined by
+// invite link or password.
+func handleGetAllGames(body json.RawMessage) (interface{}, error) {
+	reply := new(rpc.GetAllGamesReply)
+	reply.Games = persister.GetPublicGames(game.STATUS_LOBBY)
+	return reply, nil
+}
+
+// handleGetGame handles the request for what anyone can see of a game,
+// sent when the game is looked up through the API.
+func handleGetGame(body json.RawMessage) (interface{}, error) {
+	var request rpc.GetGameRequest
+	err := rpc.Decode(body, &request)
+	if err != nil {
+		return nil, err
+	}
+
+	requestUser := getUser(request.UserCookie)
+	if requestUser == nil {
+		return nil, errors.New("You need to be logged in to look at a game.")
+	}
+
+	gameId, err := strconv.Atoi(request.GameId)
+	if err != nil {
+		return nil, errors.New("Game Id is not valid.")
+	}
+
+	requestedGame, err := persister.ReadGame(gameId)
+	if requestedGame == nil || err != nil {
+		return nil, errors.New("Game does not exist.")
+	}
+	if !requestedGame.IsAdmitted(requestUser) {
+		return nil, game.ErrNotInvited
+	}
+
+	return &rpc.GetGameReply{
+		State:   requestedGame.GetState(nil),
+		History: requestedGame.GetHistory()}, nil
+}
+
+// handleGetUserStats handles the request for a user's record over all the
+// games they played to the end.
+func handleGetUserStats(body json.RawMessage) (interface{}, error) {
+	var request rpc.GetUserStatsRequest
+	err := rpc.Decode(body, &request)
+	if err != nil {
+		return nil, err
+	}
+
+	user := users.LookupUserByUsername(request.Username)
+	if !user.IsValidUser() {
+		return nil, errors.New("User does not exist.")
+	}
+
+	reply := &rpc.GetUserStatsReply{UserId: user.UserId, Username: user.Username}
+	for _, finishedGame := range persister.GetFinishedGames(user.UserId) {
+		_, winner := finishedGame.IsGameOver()
+		for _, player := range finishedGame.Players {
+			if player.User.UserId != user.UserId {
+				continue
+			}
+
+			reply.GamesPlayed++
+			switch {
+			case player.Role == game.ROLE_RESISTANCE:
+				reply.GamesAsResistance++
+				if winner == game.ROLE_RESISTANCE_NAME {
+					reply.Wins++
+					reply.WinsAsResistance++
+				}
+			case player.Role == game.ROLE_SPY:
+				reply.GamesAsSpy++
+				if winner == game.ROLE_SPY_NAME {
+					reply.Wins++
+					reply.WinsAsSpy++
+				}
+			}
+		}
+	}
+	return reply, nil
+}
+
+// handleClientMessage handles a message from a player's browser forwarded
+// by the websocket proxy, by passing it on to the handler for that message.
+func handleClientMessage(body json.RawMessage, publisher pubsub.Publisher) (interface{}, error) {
+	var request rpc.ClientMessageRequest
+	err := rpc.Decode(body, &request)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedMessage := parseMessage(request.Payload)
+	user := getUser(request.UserCookie)
+	gameIdString, _ := parsedMessage[protocol.GAME_ID_KEY].(string)
+
+	var returnMessage = make(map[string]interface{})
+
+	// Rest of game related activity
+	gameId, err := strconv.Atoi(gameIdString)
+
+	// TODO should we send a failure message here?
+	if err == nil {
+
+		currentGame, err := persister.ReadGame(gameId)
+
+		// TODO should we send a failure message here?
+		if err == nil {
+
+			switch {
+			default:
+			case user == nil:
+			case (parsedMessage[protocol.MESSAGE_KEY] == protocol.PLAYER_CONNECT_MESSAGE ||
+				parsedMessage[protocol.MESSAGE_KEY] == protocol.SPECTATE_MESSAGE) && !isSupportedClient(parsedMessage):
+				returnMessage = getProtocolErrorMessage(parsedMessage)
+			case !currentGame.IsAdmitted(user):
+				returnMessage = getShowTextMessage(game.ErrNotInvited.Error())
+			case !isAllowedMessage(parsedMessage, currentGame, user):
+				returnMessage = getShowTextMessage("Only players can do that.")
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.PLAYER_CONNECT_MESSAGE:
+				returnMessage = handlePlayerConnect(currentGame, user, publisher)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.SPECTATE_MESSAGE:
+				returnMessage = handleSpectate(currentGame, user, publisher)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.GET_PLAYERS_MESSAGE:
+				returnMessage = handleGetPlayers(currentGame)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.START_GAME_MESSAGE:
+				returnMessage = handleStartGame(currentGame, user, publisher)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.QUERY_ROLE_MESSAGE:
+				returnMessage = handleQueryRole(currentGame, user)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.QUERY_LEADER_MESSAGE:
+				returnMessage = handleQueryLeader(currentGame, user)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.START_MISSION_MESSAGE:
+				returnMessage = handleStartMission(parsedMessage, currentGame, user, publisher)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.APPROVE_TEAM_MESSAGE:
+				returnMessage = handleApproveTeam(parsedMessage, currentGame, user, publisher)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.QUERY_IS_ON_MISSION_MESSAGE:
+				returnMessage = handleQueryIsOnMission(currentGame, user)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.MISSION_OUTCOME_MESSAGE:
+				returnMessage = handleMissionOutcome(parsedMessage, currentGame, user, publisher)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.UPDATE_GAME_PROGRESS:
+				returnMessage = handleUpdateGameProgress(parsedMessage, currentGame, user, publisher)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.QUERY_GAME_STATE_MESSAGE:
+				returnMessage = handleQueryGameState(currentGame, user)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.RESUME_MESSAGE:
+				returnMessage = handleResume(parsedMessage, currentGame, user)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.ADD_BOT_MESSAGE:
+				returnMessage = handleAddBot(parsedMessage, currentGame, user, publisher)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.REMOVE_BOT_MESSAGE:
+				returnMessage = handleRemoveBot(parsedMessage, currentGame, user, publisher)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.SEND_CHAT_MESSAGE:
+				returnMessage = handleChat(parsedMessage, currentGame, user, publisher)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.SET_SPY_CHAT_MESSAGE:
+				returnMessage = handleSetSpyChat(parsedMessage, currentGame, user)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.SET_TIME_LIMITS_MESSAGE:
+				returnMessage = handleSetTimeLimits(parsedMessage, currentGame, user)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.REPLACE_PLAYER_MESSAGE:
+				returnMessage = handleReplacePlayer(parsedMessage, currentGame, user, publisher)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.ABANDON_GAME_MESSAGE:
+				returnMessage = handleAbandonGame(currentGame, user, publisher)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.KICK_PLAYER_MESSAGE:
+				returnMessage = handleKickPlayer(parsedMessage, currentGame, user, publisher)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.TRANSFER_HOST_MESSAGE:
+				returnMessage = handleTransferHost(parsedMessage, currentGame, user, publisher)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.LOCK_LOBBY_MESSAGE:
+				returnMessage = handleLockLobby(parsedMessage, currentGame, user, publisher)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.SET_SEAT_ORDER_MESSAGE:
+				returnMessage = handleSetSeatOrder(parsedMessage, currentGame, user, publisher)
+			case parsedMessage[protocol.MESSAGE_KEY] == protocol.REGENERATE_INVITE_CODE_MESSAGE:
+				returnMessage = handleRegenerateInviteCode(currentGame, user)
+			}
+
+			// Whatever just happened, it may be a bot's turn now, or
+			// someone else's and their clock is ticking
+			runBots(currentGame, publisher)
+			updateTimer(currentGame, publisher)
+		}
+	}
+
+	reply := new(rpc.ClientMessageReply)
+	reply.Payload, err = json.Marshal(returnMessage)
+	if err != nil {
+		utils.LogMessage("Error marshalling response", utils.RGAME_LOG_PATH)
+		return nil, err
+	}
+
+	// Let the proxy know to subscribe this connection to the game
+	reply.AcceptUser, _ = returnMessage[protocol.ACCEPT_USER_KEY].(bool)
+	reply.GameId, _ = returnMessage[protocol.GAME_ID_KEY].(int)
+	reply.UserId, _ = returnMessage[protocol.USER_ID_KEY].(int)
+	reply.Spectate, _ = returnMessage[protocol.SPECTATE_KEY].(bool)
+	return reply, nil
+}
+
+// handlePlayerDisconnectRequest handles the request the websocket proxy
+// sends when a player's connection goes away.
+func handlePlayerDisconnectRequest(body json.RawMessage, publisher pubsub.Publisher) (interface{}, error) {
+	var request rpc.PlayerDisconnectRequest
+	err := rpc.Decode(body, &request)
+	if err != nil {
+		return nil, err
+	}
+
+	user := getUser(request.UserCookie)
+	if user == nil {
+		return nil, errors.New("Unknown user.")
+	}
+
+	gameId, err := strconv.Atoi(request.GameId)
+	if err != nil {
+		return nil, errors.New("Game Id is not valid.")
+	}
+
+	currentGame, err := persister.ReadGame(gameId)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Spectate {
+		handleSpectatorDisconnect(currentGame, user, publisher)
+	} else {
+		handlePlayerDisconnect(currentGame, user, publisher)
+		updateTimer(currentGame, publisher)
+		startGraceTimer(currentGame, user)
+	}
+	return &rpc.PlayerDisconnectReply{}, nil
+}
+
+// handlePlayerConnect handles the message that is sent when a player
+// first connects by loading the game page.
+func handlePlayerConnect(currentGame *game.Game, connectingPlayer *users.User, publisher pubsub.Publisher) map[string]interface{} {
+	utils.LogMessage("Player "+strconv.Itoa(connectingPlayer.UserId)+" connecting", utils.RGAME_LOG_PATH)
+
+	var returnMessage = make(map[string]interface{})
+	gameId := currentGame.GameId
+
+	if currentGame.GameStatus == game.STATUS_LOBBY {
+		if err := currentGame.CanJoinLobby(connectingPlayer); err != nil {
+			return getShowTextMessage(err.Error())
+		}
+	} else if !currentGame.IsPlayer(connectingPlayer) {
+		// Once the game has started only its players can take a seat
+		return getShowTextMessage("Cannot join a game that is in progress, but you can watch it")
+	}
+
+	err := currentGame.Validate()
+	blockedGame := err != nil
+
+	// Add the user to the players for this game
+	currentGame.AddPlayer(connectingPlayer)
+
+	// Send a message to everyone about the new players
+	playersMessage := getPlayersMessage(currentGame)
+	sendMessageToSubscribers(gameId, playersMessage, publisher)
+
+	// Also send a message back through the proxy to start a subscriber
+	// for this player
+	returnMessage[protocol.MESSAGE_KEY] = protocol.PLAYER_CONNECT_SUCCESSFUL_MESSAGE
+	returnMessage[protocol.GAME_ID_KEY] = gameId
+	returnMessage[protocol.ACCEPT_USER_KEY] = true
+	// Also tells the proxy which player topic to subscribe to
+	returnMessage[protocol.USER_ID_KEY] = connectingPlayer.UserId
+
+	if currentGame.Host.UserId == connectingPlayer.UserId {
+		returnMessage[protocol.IS_HOST_KEY] = true
+	}
+	// Anyone in the lobby may be made the host
+	returnMessage[protocol.STRATEGIES_KEY] = game.GetStrategyNames()
+
+	returnMessage[protocol.PROTOCOL_VERSION_KEY] = protocol.PROTOCOL_VERSION
+
+	// Everything needed to draw the game as it is right now
+	returnMessage[protocol.GAME_STATE_KEY] = currentGame.GetState(connectingPlayer)
+
+	// If this connection was for a game that is already started, and
+	// was blocked, this connection might be the one to unblock it.
+	if currentGame.GameStatus == game.STATUS_IN_PROGRESS {
+		returnMessage[protocol.UPDATE_GAME_PROGRESS_KEY] = true
+		if blockedGame {
+			err := currentGame.Validate()
+			if err == nil {
+				// Everything is good with the game, unblock game.
+				var unblockMessage = make(map[string]interface{})
+				unblockMessage[protocol.MESSAGE_KEY] = protocol.GAME_RESUME_MESSAGE
+				sendMessageToSubscribers(gameId, unblockMessage, publisher)
+			}
+		}
+	}
+
+	return returnMessage
+}
+
+// handlerPlayerDisconnect handles the message that is sent when
+// a player disconnects from the web socket proxy.
+func handlePlayerDisconnect(currentGame *game.Game, connectingPlayer *users.User, publisher pubsub.Publisher) map[string]interface{} {
+	var returnMessage = make(map[string]interface{})
+
+	currentGame.PlayerDisconnect(connectingPlayer)
+	sendMessageToSubscribers(currentGame.GameId, getPlayersMessage(currentGame), publisher)
+
+	// If the game is invalid, the disconnect caused a player to completely
+	// disconnect. Therefore, we should block the game until they reconnect.
+	pauseGameIfNeeded(currentGame, publisher)
+
+	return returnMessage
+}
+
+// handleGetPlayers handles the message that is sent when the
+// frontend needs an update on the players.
+func handleGetPlayers(currentGame *game.Game) map[string]interface{} {
+	return getPlayersMessage(currentGame)
+}
+
+// handleStartGame handles the message that is sent when the host
+// presses the start game button.
+func handleStartGame(currentGame *game.Game, connectingPlayer *users.User, publisher pubsub.Publisher) map[string]interface{} {
+	if currentGame.Host.UserId != connectingPlayer.UserId {
+		return getErrorMessage("Only the host can start the game.")
+	}
+
+	var returnMessage = make(map[string]interface{})
+	gameId := currentGame.GameId
+
+	_ = currentGame.StartGame()
+
+	// Sends the message that the game has officially started
+	var gameStartedMessage = make(map[string]interface{})
+	gameStartedMessage[protocol.MESSAGE_KEY] = protocol.GAME_STARTED_MESSAGE
+	sendMessageToSubscribers(gameId, gameStartedMessage, publisher)
+
+	_ = game.NewMission(currentGame)
+
+	// Send a message to everyone to update their missions view
+	sendMissionsMessage(currentGame, publisher)
+
+	// Sends the message that a mission is going to start
+	var missionPreparationMessage = make(map[string]interface{})
+	missionPreparationMessage[protocol.MESSAGE_KEY] = protocol.MISSION_PREPARATION_MESSAGE
+	sendMessageToSubscribers(gameId, missionPreparationMessage, publisher)
+
+	return returnMessage
+}
+
+// handleQueryRole handles the request from the frontend for which
+// team they are on.
+func handleQueryRole(currentGame *game.Game, player *users.User) map[string]interface{} {
+	var returnMessage = make(map[string]interface{})
+
+	for _, singlePlayer := range currentGame.Players {
+		if singlePlayer.User.UserId == player.UserId {
+			returnMessage[protocol.MESSAGE_KEY] = protocol.QUERY_ROLE_RESULT_MESSAGE
+			switch {
+			case singlePlayer.Role == game.ROLE_RESISTANCE:
+				returnMessage[protocol.ROLE_KEY] = game.ROLE_RESISTANCE_NAME
+			case singlePlayer.Role == game.ROLE_SPY:
+				returnMessage[protocol.ROLE_KEY] = game.ROLE_SPY_NAME
+			}
+			break
+		}
+	}
+
+	return returnMessage
+}
+
+// handleQueryLeader handles the request from the frontend for who
+// the leader of the current mission is.
+func handleQueryLeader(currentGame *game.Game, player *users.User) map[string]interface{} {
+	var returnMessage map[string]interface{}
+
+	isLeader := currentGame.GetCurrentMission().IsUserCurrentMissionLeader(player)
+
+	if isLeader {
+		returnMessage = make(map[string]interface{})
+		returnMessage[protocol.MESSAGE_KEY] = protocol.QUERY_LEADER_RESULT_MESSAGE
+		returnMessage[protocol.IS_LEADER_KEY] = isLeader
+		returnMessage[protocol.PLAYERS_KEY] = currentGame.GetUsers()
+		returnMessage[protocol.TEAM_SIZE_KEY] = currentGame.GetCurrentMission().GetCurrentMissionTeamSize()
+	} else {
+		returnMessage = getShowTextMessage("You are not the leader.")
+	}
+
+	return returnMessage
+}
+
+// handleStartMission handles the message when the leader
+// sends in the team. The team can only be picked once, by the leader,
+// before the vote on it.
+func handleStartMission(message map[string]interface{}, currentGame *game.Game, connectingPlayer *users.User, publisher pubsub.Publisher) map[string]interface{} {
+	currentMission := currentGame.GetCurrentMission()
+	if currentMission == nil || currentGame.GetPhase() != game.PHASE_TEAM_SELECTION {
+		return getErrorMessage("The team can't be picked now.")
+	}
+	if currentMission.Leader == nil || currentMission.Leader.UserId != connectingPlayer.UserId {
+		return getErrorMessage("Only the leader can pick the team.")
+	}
+
+	var returnMessage = make(map[string]interface{})
+	teamIds := make([]string, 0)
+	rawTeamIds, ok := message[protocol.TEAMS_KEY].([]interface{})
+	if ok {
+		for _, rawTeamId := range rawTeamIds {
+			teamId, ok := rawTeamId.(string)
+			if ok {
+				teamIds = append(teamIds, teamId)
+			}
+		}
+	}
+
+	teamUsers := make([]*users.User, len(teamIds))
+	for i, teamId := range teamIds {
+		parsedTeamId, _ := strconv.Atoi(teamId)
+		user := users.LookupUserById(parsedTeamId)
+		if user.IsValidUser() {
+			teamUsers[i] = user
+		} else {
+			utils.LogMessage("User Id for team not found: "+teamId, utils.RGAME_LOG_PATH)
+		}
+	}
+
+	gameId := currentGame.GameId
+	currentMission.CreateTeam(teamUsers)
+
+	var teamApprovalMessage = getTeamApprovalMessage(currentGame)
+	sendMessageToSubscribers(gameId, teamApprovalMessage, publisher)
+
+	sendMissionsMessage(currentGame, publisher)
+
+	return returnMessage
+}
+
+// handleApproveTeam handles the message from the frontend
+// that votes for the whether the team can go on the mission.
+func handleApproveTeam(message map[string]interface{}, currentGame *game.Game, connectingPlayer *users.User, publisher pubsub.Publisher) map[string]interface{} {
+	var returnMessage = make(map[string]interface{})
+
+	gameId := currentGame.GameId
+	vote, ok := message[protocol.VOTE_KEY].(bool)
+	if ok {
+		currentGame.GetCurrentMission().AddVote(connectingPlayer, vote)
+
+		// send vote to everyone to make it public
+		var approveTeamUpdateMessage = make(map[string]interface{})
+		approveTeamUpdateMessage[protocol.MESSAGE_KEY] = protocol.APPROVE_TEAM_UPDATE_MESSAGE
+		approveTeamUpdateMessage[protocol.USERNAME_KEY] = connectingPlayer.Username
+		approveTeamUpdateMessage[protocol.VOTE_KEY] = vote
+		sendMessageToSubscribers(gameId, approveTeamUpdateMessage, publisher)
+
+		allVotesIn := currentGame.GetCurrentMission().IsAllVotesCollected()
+		if allVotesIn {
+			err := currentGame.Persister.PersistMission(currentGame.GetCurrentMission())
+			if err != nil {
+				utils.LogMessage(err.Error(), utils.RGAME_LOG_PATH)
+			}
+
+			missionApproved := currentGame.GetCurrentMission().IsTeamApproved()
+			if missionApproved {
+				var missionApprovedMessage = make(map[string]interface{})
+				missionApprovedMessage[protocol.MESSAGE_KEY] = protocol.MISSION_STARTED_MESSAGE
+				sendMessageToSubscribers(gameId, missionApprovedMessage, publisher)
+			} else {
+				currentGame.GetCurrentMission().EndMission(game.WINNER_NONE)
+
+				_ = game.NewMission(currentGame)
+
+				var missionPreparationMessage = make(map[string]interface{})
+				missionPreparationMessage[protocol.MESSAGE_KEY] = protocol.MISSION_PREPARATION_MESSAGE
+				sendMessageToSubscribers(gameId, missionPreparationMessage, publisher)
+			}
+
+			// once all votes are in, if either the mission was approved or not
+			// there is an update to the list of missions so we should send it out.
+			sendMissionsMessage(currentGame, publisher)
+		}
+	}
+
+	return returnMessage
+}
+
+// handleQueryIsOnMission handles the message from the frontend
+// asking if the requesting user is on the current mission.
+// Assumes that the mission has been approved.
+func handleQueryIsOnMission(currentGame *game.Game, connectingPlayer *users.User) map[string]interface{} {
+	var returnMessage map[string]interface{}
+
+	isOnMission := currentGame.GetCurrentMission().IsUserOnCurrentMission(connectingPlayer)
+
+	if isOnMission {
+		returnMessage = make(map[string]interface{})
+		returnMessage[protocol.MESSAGE_KEY] = protocol.QUERY_IS_ON_MISSION_RESULT_MESSAGE
+		returnMessage[protocol.IS_ON_MISSION_KEY] = isOnMission
+	} else {
+		returnMessage = getShowTextMessage("Waiting for mission to finish...")
+	}
+
+	return returnMessage
+}
+
+// handleMissionOutcome handles the message from the frontend
+// after a player has put in their mission outcome - a "success"
+// or a "fail".
+func handleMissionOutcome(message map[string]interface{}, currentGame *game.Game, connectingPlayer *users.User, publisher pubsub.Publisher) map[string]interface{} {
+	var returnMessage = make(map[string]interface{})
+
+	gameId := currentGame.GameId
+	missionOutcome, ok := message[protocol.OUTCOME_KEY].(bool)
+	if ok {
+		currentGame.GetCurrentMission().AddOutcome(connectingPlayer, missionOutcome)
+
+		// check if the current mission is over
+		isMissionOver, result := currentGame.GetCurrentMission().IsMissionOver()
+		if isMissionOver {
+			// it is, so set the mission result
+			currentGame.GetCurrentMission().EndMission(result)
+
+			// now check if the game is over
+			isGameOver, winner := currentGame.IsGameOver()
+
+			if isGameOver {
+				currentGame.EndGame()
+
+				// send game over message
+				var gameOverMessage = make(map[string]interface{})
+				gameOverMessage[protocol.MESSAGE_KEY] = protocol.GAME_OVER_MESSAGE
+				gameOverMessage[protocol.GAME_WINNER_KEY] = winner
+				sendMessageToSubscribers(gameId, gameOverMessage, publisher)
+
+				// No one can join a game that is done, so there is no one
+				// left to catch up
+				forgetMessages(gameId)
+			} else {
+				_ = game.NewMission(currentGame)
+
+				// send mission preparation message for next mission
+				var missionPreparationMessage = make(map[string]interface{})
+				missionPreparationMessage[protocol.MESSAGE_KEY] = protocol.MISSION_PREPARATION_MESSAGE
+				sendMessageToSubscribers(gameId, missionPreparationMessage, publisher)
+
+				sendMissionsMessage(currentGame, publisher)
+			}
+
+		}
+	}
+	return returnMessage
+}
+
+// handleUpdateGameProgress handles the message when a player
+// leaves the game then comes back and requests the current
+// game state.
+func handleUpdateGameProgress(message map[string]interface{}, currentGame *game.Game, connectingPlayer *users.User, publisher pubsub.Publisher) map[string]interface{} {
+	sendMissionsMessage(currentGame, publisher)
+
+	pauseGameIfNeeded(currentGame, publisher)
+
+	returnMessage := make(map[string]interface{})
+
+	// We assume the that game is IN_PROGRESS status, since we only allow them
+	// to ask for an update game progress when the game is still IN_PROGRESS
+	// during player connect
+	if len(currentGame.GetCurrentMission().Team) == 0 {
+		// Waiting for the leader to pick team. The connecting user
+		// could have been the leader, so respond as if they were
+		// asking if they are the leader
+		returnMessage = handleQueryLeader(currentGame, connectingPlayer)
+	} else if !currentGame.GetCurrentMission().IsAllVotesCollected() {
+		// Waiting for all votes to come in. But has the connecting player
+		// already voted?
+		if currentGame.GetCurrentMission().Votes[connectingPlayer.UserId] == "" {
+			// Connecting player has not yet voted, send a request to
+			// gain approval for the team
+			returnMessage = getTeamApprovalMessage(currentGame)
+		} else {
+			// Connecting player has voted. Show some text.
+			returnMessage = getShowTextMessage("You have already voted. Waiting for all votes to come in.")
+		}
+	} else {
+		// Mission has been approved. People are going on a mission.
+		if currentGame.GetCurrentMission().Team[connectingPlayer.UserId] != game.OUTCOME_NONE {
+			// Player was on a mission AND already submitted mission outcome.
+			// Show some text.
+			returnMessage = getShowTextMessage("You have already submitted the mission outcome. Waiting for all outcomes to come in.")
+		} else {
+			// They haven't voted yet or are not on the mission, so act
+			// as if the mission just started and query is on mission should
+			// handle both cases
+			returnMessage = handleQueryIsOnMission(currentGame, connectingPlayer)
+		}
+	}
+
+	return returnMessage
+}
+
+// handleQueryGameState handles the request from the frontend for the
+// whole state of the game, as the requesting player is allowed to see it.
+func handleQueryGameState(currentGame *game.Game, player *users.User) map[string]interface{} {
+	var returnMessage = make(map[string]interface{})
+	returnMessage[protocol.MESSAGE_KEY] = protocol.GAME_STATE_MESSAGE
+	returnMessage[protocol.GAME_STATE_KEY] = currentGame.GetState(player)
+	return returnMessage
+}
+
+// handleResume handles the message from a player, or spectator, who
+// reconnected and wants the messages published to the game since the last
+// one they saw. If those aren't all kept anymore, or were numbered in
+// another epoch, the frontend falls back to updateGameProgress.
+func handleResume(message map[string]interface{}, currentGame *game.Game, connectingPlayer *users.User) map[string]interface{} {
+	if !currentGame.IsPlayer(connectingPlayer) && !currentGame.IsSpectator(connectingPlayer) {
+		return getShowTextMessage("You are not in this game.")
+	}
+
+	lastSeq, ok := message[protocol.LAST_SEQ_KEY].(float64)
+	if !ok {
+		lastSeq = 0
+	}
+
+	epoch, _ := message[protocol.EPOCH_KEY].(string)
+
+	missedMessages, currentSeq, currentEpoch, complete := getReplayBuffer(currentGame.GameId).since(epoch, int(lastSeq))
+
+	var returnMessage = make(map[string]interface{})
+	returnMessage[protocol.MESSAGE_KEY] = protocol.RESUME_RESULT_MESSAGE
+	returnMessage[protocol.MESSAGES_KEY] = missedMessages
+	returnMessage[protocol.LAST_SEQ_KEY] = currentSeq
+	returnMessage[protocol.EPOCH_KEY] = currentEpoch
+	returnMessage[protocol.COMPLETE_KEY] = complete
+	return returnMessage
+}
+
+// pauseGameIfNeeded checks if the game needs to paused because of an
+// invalid game (usually not all players are present)
+func pauseGameIfNeeded(currentGame *game.Game, publisher pubsub.Publisher) {
+	if currentGame.GameStatus == game.STATUS_IN_PROGRESS {
+		err := currentGame.Validate()
+		if err != nil {
+			var blockMessage = getGamePauseMessage()
+			sendMessageToSubscribers(currentGame.GameId, blockMessage, publisher)
+		}
+	}
+}
+
+// getPlayersMessage builds up the message to update the list of
+// current players.
+func getPlayersMessage(currentGame *game.Game) map[string]interface{} {
+	usernames := getPlayerUsernames(currentGame)
+	userIds := make([]int, 0)
+	for _, user := range currentGame.GetUsers() {
+		userIds = append(userIds, user.UserId)
+	}
+
+	// Build up players message. The players are in seat order.
+	var playersMessage = make(map[string]interface{})
+	playersMessage[protocol.MESSAGE_KEY] = protocol.PLAYERS_MESSAGE
+	playersMessage[protocol.PLAYERS_KEY] = usernames
+	playersMessage[protocol.PLAYER_IDS_KEY] = userIds
+	playersMessage[protocol.HOST_ID_KEY] = currentGame.Host.UserId
+	playersMessage[protocol.LOCKED_KEY] = currentGame.Locked
+
+	bots := make([]*users.User, 0)
+	for _, bot := range currentGame.GetBots() {
+		bots = append(bots, bot.User)
+	}
+	playersMessage[protocol.BOTS_KEY] = bots
+	playersMessage[protocol.SPECTATORS_KEY] = currentGame.GetSpectatorUsernames()
+	playersMessage[protocol.GAME_ID_KEY] = currentGame.GameId
+
+	return playersMessage
+}
+
+// getTeamApprovalMessage builds up the message to ask for approval
+// for the given game's current mission's team.
+func getTeamApprovalMessage(currentGame *game.Game) map[string]interface{} {
+	teamUsernames := make([]string, 0)
+	for userId, _ := range currentGame.GetCurrentMission().Team {
+		user := users.LookupUserById(userId)
+		if user.IsValidUser() {
+			teamUsernames = append(teamUsernames, user.Username)
+		}
+	}
+
+	var teamApprovalMessage = make(map[string]interface{})
+	teamApprovalMessage[protocol.MESSAGE_KEY] = protocol.TEAM_APPROVAL_MESSAGE
+	teamApprovalMessage[protocol.TEAMS_KEY] = teamUsernames
+
+	return teamApprovalMessage
+}
+
+// isSupportedClient checks that the game page connecting speaks a version
+// of the protocol we understand. Pages from before versioning send none.
+func isSupportedClient(message map[string]interface{}) bool {
+	version, ok := message[protocol.PROTOCOL_VERSION_KEY].(float64)
+	return ok && protocol.IsSupportedVersion(int(version))
+}
+
+// getProtocolErrorMessage builds up the message turning away a game page
+// that speaks a protocol version we don't.
+func getProtocolErrorMessage(message map[string]interface{}) map[string]interface{} {
+	version, _ := message[protocol.PROTOCOL_VERSION_KEY].(float64)
+	utils.LogMessage("Turned away a client speaking protocol version "+strconv.Itoa(int(version)), utils.RGAME_LOG_PATH)
+
+	var protocolErrorMessage = make(map[string]interface{})
+	protocolErrorMessage[protocol.MESSAGE_KEY] = protocol.PROTOCOL_ERROR_MESSAGE
+	protocolErrorMessage[protocol.PROTOCOL_VERSION_KEY] = protocol.PROTOCOL_VERSION
+	if int(version) > protocol.PROTOCOL_VERSION {
+		protocolErrorMessage[protocol.ERROR_MESSAGE_KEY] = "The game server is older than this page. Please try again in a few minutes."
+	} else {
+		protocolErrorMessage[protocol.ERROR_MESSAGE_KEY] = "This page is out of date. Please reload it to keep playing."
+	}
+	return protocolErrorMessage
+}
+
+// getShowTextMessage builds up the message to show some text to the user.
+func getShowTextMessage(text string) map[string]interface{} {
+	var showTextMessage = make(map[string]interface{})
+	showTextMessage[protocol.MESSAGE_KEY] = protocol.SHOW_TEXT_MESSAGE
+	showTextMessage[protocol.TEXT_KEY] = text
+	return showTextMessage
+}
+
+// getErrorMessage builds up the message to tell the user something they
+// tried didn't work, without taking over what they were doing.
+func getErrorMessage(text string) map[string]interface{} {
+	var errorMessage = make(map[string]interface{})
+	errorMessage[protocol.ERROR_MESSAGE_KEY] = text
+	return errorMessage
+}
+
+func getGamePauseMessage() map[string]interface{} {
+	var gamePauseMessage = make(map[string]interface{})
+	gamePauseMessage[protocol.MESSAGE_KEY] = protocol.GAME_PAUSE_MESSAGE
+	return gamePauseMessage
+}
+
+// getPlayerUsernames retrieves just the usernames of the players of the
+// current game.
+func getPlayerUsernames(currentGame *game.Game) []string {
+	users := currentGame.GetUsers()
+	var usernames = make([]string, len(users))
+	for index, user := range users {
+		usernames[index] = user.Username
+	}
+	return usernames
+}
+
+// parseMessage parses every message that comes in and puts it into a Go struct.
+func parseMessage(msg []byte) map[string]interface{} {
+	var parsedMessage = make(map[string]interface{})
+
+	utils.LogMessage(string(msg), utils.RGAME_LOG_PATH)
+	err := json.Unmarshal(msg, &parsedMessage)
+	if err != nil {
+		utils.LogMessage("Error parsing message: "+string(msg), utils.RGAME_LOG_PATH)
+	}
+
+	return parsedMessage
+}
+
+// getUser extracts the user from the credential passed along with a
+// message, either a session cookie or an API token.
+func getUser(userCookie string) *users.User {
+	user := users.ValidateCredential(userCookie)
+	if !user.IsValidUser() {
+		utils.LogMessage("Something went wrong when validating the user", utils.RGAME_LOG_PATH)
+		return nil
+	}
+	return user
+}
+
+// sendMissionsMessage sends the update mission info message to all subscribers
+// to the given game id
+func sendMissionsMessage(currentGame *game.Game, publisher pubsub.Publisher) {
+	gameId := currentGame.GameId
+
+	var missionInfoMessage = make(map[string]interface{})
+	missionInfoMessage[protocol.MESSAGE_KEY] = protocol.MISSIONS_MESSAGE
+
+	missionInfo := currentGame.GetMissionInfo()
+	missionInfoMessage[protocol.MISSIONS_KEY] = missionInfo
+
+	// The odds only change when the missions do
+	if currentGame.IsAssistantEnabled() {
+		missionInfoMessage[protocol.SPY_ODDS_KEY] = currentGame.GetSpyOdds()
+	}
+
+	sendMessageToSubscribers(gameId, missionInfoMessage, publisher)
+}
+
+// sendMessageToSubscribers is a helper method to send the given message to
+// everyone subscribed to the given game id. Every message gets the game's
+// next sequence number, so players can tell what they missed. Everything
+// published to a game is public, so spectators get it too.
+func sendMessageToSubscribers(gameId int, message map[string]interface{}, publisher pubsub.Publisher) {
+	err := getReplayBuffer(gameId).record(message, func(pubMessage []byte) error {
+		// Send out updated users to all subscribers to this game
+		err := publisher.Publish(strconv.Itoa(gameId), pubMessage)
+		if err != nil {
+			return err
+		}
+		return publisher.Publish(pubsub.SpectatorTopic(strconv.Itoa(gameId)), pubMessage)
+	})
+	if err != nil {
+		utils.LogMessage("Error publishing to game "+strconv.Itoa(gameId)+": "+err.Error(), utils.RGAME_LOG_PATH)
+		return
+	}
+
+	utils.LogMessage("Sent message to all subscribers to game "+strconv.Itoa(gameId), utils.RGAME_LOG_PATH)
+}
+
+// isValidGameKey gets the game an is valid game request is for.
+func isValidGameKey(body json.RawMessage) string {
+	var request rpc.IsValidGameRequest
+	rpc.Decode(body, &request)
+	return getGameKey(request.GameId)
+}
+
+// getGameRequestKey gets the game a get game request is for.
+func getGameRequestKey(body json.RawMessage) string {
+	var request rpc.GetGameRequest
+	rpc.Decode(body, &request)
+	return getGameKey(request.GameId)
+}
+
+// clientMessageKey gets the game a message from a player's browser is for.
+func clientMessageKey(body json.RawMessage) string {
+	var request rpc.ClientMessageRequest
+	rpc.Decode(body, &request)
+	var parsedMessage map[string]interface{}
+	json.Unmarshal(request.Payload, &parsedMessage)
+	gameIdString, _ := parsedMessage[protocol.GAME_ID_KEY].(string)
+	return getGameKey(gameIdString)
+}
+
+// playerDisconnectKey gets the game a player disconnected from.
+func playerDisconnectKey(body json.RawMessage) string {
+	var request rpc.PlayerDisconnectRequest
+	rpc.Decode(body, &request)
+	return getGameKey(request.GameId)
+}
+
+// timeoutKey gets the game a timer went off for.
+func timeoutKey(body json.RawMessage) string {
+	var request rpc.TimeoutRequest
+	rpc.Decode(body, &request)
+	return getGameKey(request.GameId)
+}
+
+// getGameKey gets the key requests for the given game are queued under.
+// Requests without a valid game id don't touch any game, so they don't
+// need to wait on one.
+func getGameKey(gameIdString string) string {
+	gameId, err := strconv.Atoi(gameIdString)
+	if err != nil {
+		return ""
+	}
+	return strconv.Itoa(gameId)
+}
+
+// NewServer builds the game server, which publishes game updates through
+// the given publisher. The returned server still needs a transport to
+// receive requests on.
+func NewServer(publisher pubsub.Publisher) *rpc.Server {
+	server := rpc.NewServer()
+	server.Handle(rpc.CREATE_GAME_METHOD, handleCreateGame)
+	server.Handle(rpc.GET_ALL_GAMES_METHOD, handleGetAllGames)
+	server.Handle(rpc.GET_USER_STATS_METHOD, handleGetUserStats)
+
+	// Everything that touches a game is handled in order, one at a time
+	// for each game
+	server.HandleOrdered(rpc.IS_VALID_GAME_METHOD, handleIsValidGame, isValidGameKey)
+	server.HandleOrdered(rpc.GET_GAME_METHOD, handleGetGame, getGameRequestKey)
+	server.HandleOrdered(rpc.CLIENT_MESSAGE_METHOD, func(body json.RawMessage) (interface{}, error) {
+		return handleClientMessage(body, publisher)
+	}, clientMessageKey)
+	server.HandleOrdered(rpc.PLAYER_DISCONNECT_METHOD, func(body json.RawMessage) (interface{}, error) {
+		return handlePlayerDisconnectRequest(body, publisher)
+	}, playerDisconnectKey)
+	server.HandleOrdered(rpc.TIMEOUT_METHOD, func(body json.RawMessage) (interface{}, error) {
+		return handleTimeout(body, publisher)
+	}, timeoutKey)
+
+	timerServer = server
+	return server
+}
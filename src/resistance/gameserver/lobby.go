@@ -0,0 +1,152 @@
+package gameserver
+
+import (
+	"resistance/game"
+	"resistance/protocol"
+	"resistance/pubsub"
+	"resistance/users"
+	"resistance/utils"
+	"strconv"
+)
+
+// checkLobbyHost makes sure the given user is the host, and that the game
+// hasn't started yet. Returns the message to refuse with if not, nil if so.
+func checkLobbyHost(currentGame *game.Game, host *users.User) map[string]interface{} {
+	if currentGame.Host.UserId != host.UserId {
+		return getErrorMessage("Only the host can do that.")
+	}
+	if currentGame.GameStatus != game.STATUS_LOBBY {
+		return getErrorMessage("That can only be done before the game starts.")
+	}
+	return nil
+}
+
+// getLobbyPlayer gets the player in the lobby with the user id given in the
+// message, or nil if there is no such player.
+func getLobbyPlayer(message map[string]interface{}, currentGame *game.Game) *game.Player {
+	userId, _ := message[protocol.USER_ID_KEY].(float64)
+	for _, player := range currentGame.Players {
+		if player.User.UserId == int(userId) && player.GetConnections() > 0 {
+			return player
+		}
+	}
+	return nil
+}
+
+// handleKickPlayer handles the host kicking someone out of the lobby. They
+// can't come back to play, but they can still watch.
+func handleKickPlayer(message map[string]interface{}, currentGame *game.Game, host *users.User, publisher pubsub.Publisher) map[string]interface{} {
+	if refusal := checkLobbyHost(currentGame, host); refusal != nil {
+		return refusal
+	}
+
+	player := getLobbyPlayer(message, currentGame)
+	if player == nil {
+		return getErrorMessage("There is no such player.")
+	}
+	if player.User.UserId == host.UserId {
+		return getErrorMessage("You can't kick yourself out.")
+	}
+
+	gameId := currentGame.GameId
+	utils.LogMessage("Kicking "+player.User.Username+" out of game "+strconv.Itoa(gameId), utils.RGAME_LOG_PATH)
+	currentGame.KickPlayer(player)
+
+	var kickedMessage = make(map[string]interface{})
+	kickedMessage[protocol.MESSAGE_KEY] = protocol.KICKED_MESSAGE
+	sendMessageToPlayer(gameId, player.User.UserId, kickedMessage, publisher)
+	sendMessageToSubscribers(gameId, getPlayersMessage(currentGame), publisher)
+
+	return make(map[string]interface{})
+}
+
+// handleTransferHost handles the host handing the game over to another
+// player in the lobby.
+func handleTransferHost(message map[string]interface{}, currentGame *game.Game, host *users.User, publisher pubsub.Publisher) map[string]interface{} {
+	if refusal := checkLobbyHost(currentGame, host); refusal != nil {
+		return refusal
+	}
+
+	player := getLobbyPlayer(message, currentGame)
+	if player == nil {
+		return getErrorMessage("There is no such player.")
+	}
+	if player.IsBot() {
+		return getErrorMessage("A bot can't host the game.")
+	}
+
+	utils.LogMessage("Making "+player.User.Username+" the host of game "+strconv.Itoa(currentGame.GameId), utils.RGAME_LOG_PATH)
+	currentGame.Host = player.User
+	err := currentGame.Persister.PersistGame(currentGame)
+	if err != nil {
+		utils.LogMessage(err.Error(), utils.RGAME_LOG_PATH)
+	}
+
+	sendMessageToSubscribers(currentGame.GameId, getPlayersMessage(currentGame), publisher)
+
+	return make(map[string]interface{})
+}
+
+// handleLockLobby handles the host locking the lobby, so nobody new can
+// join, or unlocking it again.
+func handleLockLobby(message map[string]interface{}, currentGame *game.Game, host *users.User, publisher pubsub.Publisher) map[string]interface{} {
+	if refusal := checkLobbyHost(currentGame, host); refusal != nil {
+		return refusal
+	}
+
+	currentGame.Locked, _ = message[protocol.LOCKED_KEY].(bool)
+	err := currentGame.Persister.PersistGame(currentGame)
+	if err != nil {
+		utils.LogMessage(err.Error(), utils.RGAME_LOG_PATH)
+	}
+
+	sendMessageToSubscribers(currentGame.GameId, getPlayersMessage(currentGame), publisher)
+
+	return make(map[string]interface{})
+}
+
+// handleSetSeatOrder handles the host changing where everyone sits, which
+// is the order leaders take turns in.
+func handleSetSeatOrder(message map[string]interface{}, currentGame *game.Game, host *users.User, publisher pubsub.Publisher) map[string]interface{} {
+	if refusal := checkLobbyHost(currentGame, host); refusal != nil {
+		return refusal
+	}
+
+	rawUserIds, _ := message[protocol.PLAYERS_KEY].([]interface{})
+	userIds := make([]int, 0)
+	for _, rawUserId := range rawUserIds {
+		userId, ok := rawUserId.(float64)
+		if !ok {
+			return getErrorMessage("Seats are given by user id.")
+		}
+		userIds = append(userIds, int(userId))
+	}
+
+	currentGame.SetSeatOrder(userIds)
+	sendMessageToSubscribers(currentGame.GameId, getPlayersMessage(currentGame), publisher)
+
+	return make(map[string]interface{})
+}
+
+// handleRegenerateInviteCode handles the host of a private game giving it a
+// new invite code, when the old invite link got to people it shouldn't
+// have. Only the host sees the code, in their game state.
+func handleRegenerateInviteCode(currentGame *game.Game, host *users.User) map[string]interface{} {
+	if currentGame.Host.UserId != host.UserId {
+		return getErrorMessage("Only the host can do that.")
+	}
+	if !currentGame.Private {
+		return getErrorMessage("Anyone can join this game, it has no invite code.")
+	}
+	if currentGame.IsFinished() {
+		return getErrorMessage("This game is over.")
+	}
+
+	err := currentGame.RegenerateInviteCode()
+	if err != nil {
+		utils.LogMessage("Error regenerating the invite code of game "+strconv.Itoa(currentGame.GameId)+": "+err.Error(), utils.RGAME_LOG_PATH)
+		return getErrorMessage("Error making a new invite code.")
+	}
+
+	return handleQueryGameState(currentGame, host)
+}
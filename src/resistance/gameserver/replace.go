@@ -0,0 +1,124 @@
+package gameserver
+
+import (
+	"resistance/game"
+	"resistance/protocol"
+	"resistance/pubsub"
+	"resistance/users"
+	"resistance/utils"
+	"strconv"
+	"strings"
+)
+
+// handleReplacePlayer handles the host replacing a player who left in the
+// middle of the game and didn't come back in time, with a bot or with
+// someone else. Someone else has to load the game page to take the seat.
+func handleReplacePlayer(message map[string]interface{}, currentGame *game.Game, host *users.User, publisher pubsub.Publisher) map[string]interface{} {
+	if currentGame.Host.UserId != host.UserId {
+		return getErrorMessage("Only the host can replace players.")
+	}
+
+	username, _ := message[protocol.USERNAME_KEY].(string)
+	var missingPlayer *game.Player
+	for _, player := range currentGame.GetMissingPlayers() {
+		if player.User.Username == username {
+			missingPlayer = player
+		}
+	}
+	if missingPlayer == nil {
+		return getErrorMessage(username + " isn't missing.")
+	}
+	if !currentGame.IsGracePeriodOver(missingPlayer) {
+		return getErrorMessage(username + " still has time to come back.")
+	}
+
+	var replacementUser *users.User
+	strategyName, _ := message[protocol.STRATEGY_KEY].(string)
+	strategy := game.NewStrategy(strategyName)
+	if strategyName != "" {
+		if strategy == nil {
+			return getErrorMessage("There is no such bot.")
+		}
+		replacementUser = getFreeBotUser(currentGame, strategyName)
+	} else {
+		replacementName, _ := message[protocol.REPLACEMENT_KEY].(string)
+		// Bot names have a space in them, and bots need a strategy to play
+		if strings.Contains(replacementName, " ") {
+			return getErrorMessage("There is no such player.")
+		}
+		replacementUser = users.LookupUserByUsername(replacementName)
+	}
+	if !replacementUser.IsValidUser() {
+		return getErrorMessage("There is no such player.")
+	}
+
+	_, err := currentGame.ReplacePlayer(missingPlayer, replacementUser, strategy)
+	if err != nil {
+		return getErrorMessage(err.Error())
+	}
+
+	gameId := currentGame.GameId
+	utils.LogMessage("Replaced "+username+" with "+replacementUser.Username+" in game "+strconv.Itoa(gameId), utils.RGAME_LOG_PATH)
+	sendMessageToSubscribers(gameId, getPlayerReplacedMessage(username, replacementUser.Username), publisher)
+	sendMessageToSubscribers(gameId, getPlayersMessage(currentGame), publisher)
+
+	// A bot takes the seat right away, anyone else once they show up
+	if !currentGame.IsPaused() {
+		var unblockMessage = make(map[string]interface{})
+		unblockMessage[protocol.MESSAGE_KEY] = protocol.GAME_RESUME_MESSAGE
+		sendMessageToSubscribers(gameId, unblockMessage, publisher)
+		sendMissionsMessage(currentGame, publisher)
+	}
+
+	return make(map[string]interface{})
+}
+
+// handleAbandonGame handles the host giving up on a game waiting on players
+// who didn't come back in time. Once any of them is out of time, the host
+// doesn't have to wait on the others. Nobody wins, and the game doesn't count
+// towards anyone's record.
+func handleAbandonGame(currentGame *game.Game, host *users.User, publisher pubsub.Publisher) map[string]interface{} {
+	if currentGame.Host.UserId != host.UserId {
+		return getErrorMessage("Only the host can abandon the game.")
+	}
+	if len(currentGame.GetPlayersOutOfTime()) == 0 {
+		return getErrorMessage("Players who left still have time to come back.")
+	}
+
+	gameId := currentGame.GameId
+	utils.LogMessage("Abandoning game "+strconv.Itoa(gameId), utils.RGAME_LOG_PATH)
+	currentGame.AbandonGame()
+
+	var gameAbandonedMessage = make(map[string]interface{})
+	gameAbandonedMessage[protocol.MESSAGE_KEY] = protocol.GAME_ABANDONED_MESSAGE
+	sendMessageToSubscribers(gameId, gameAbandonedMessage, publisher)
+
+	// Like a game that is done, there is no one left to catch up
+	forgetMessages(gameId)
+
+	return make(map[string]interface{})
+}
+
+// getGracePeriodOverMessage builds up the message telling everyone which
+// of the players the game is waiting on are out of time to come back.
+func getGracePeriodOverMessage(currentGame *game.Game) map[string]interface{} {
+	usernames := make([]string, 0)
+	for _, player := range currentGame.GetPlayersOutOfTime() {
+		usernames = append(usernames, player.User.Username)
+	}
+
+	var gracePeriodOverMessage = make(map[string]interface{})
+	gracePeriodOverMessage[protocol.MESSAGE_KEY] = protocol.GRACE_PERIOD_OVER_MESSAGE
+	gracePeriodOverMessage[protocol.PLAYERS_KEY] = usernames
+	return gracePeriodOverMessage
+}
+
+// getPlayerReplacedMessage builds up the message telling everyone who took
+// over whose seat.
+func getPlayerReplacedMessage(username string, replacementUsername string) map[string]interface{} {
+	var playerReplacedMessage = make(map[string]interface{})
+	playerReplacedMessage[protocol.MESSAGE_KEY] = protocol.PLAYER_REPLACED_MESSAGE
+	playerReplacedMessage[protocol.USERNAME_KEY] = username
+	playerReplacedMessage[protocol.REPLACEMENT_KEY] = replacementUsername
+	return playerReplacedMessage
+}
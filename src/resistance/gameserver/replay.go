@@ -0,0 +1,97 @@
+package gameserver
+
+import (
+	"encoding/json"
+	"resistance/protocol"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// How many of the latest messages of a game are kept for players
+	// catching up after a reconnect.
+	REPLAY_BUFFER_SIZE = 100
+)
+
+// replayBuffer numbers the messages published to a game and keeps the
+// latest ones around. Numbering starts over whenever a buffer is created,
+// after a restart or once the game's messages are forgotten, so each buffer
+// has its own epoch telling its sequence numbers apart from earlier ones.
+type replayBuffer struct {
+	lock     sync.Mutex
+	epoch    string
+	lastSeq  int
+	messages [][]byte
+}
+
+var (
+	replayLock    sync.Mutex
+	replayBuffers = make(map[int]*replayBuffer)
+)
+
+// getReplayBuffer gets the replay buffer of the given game, creating it if
+// needed.
+func getReplayBuffer(gameId int) *replayBuffer {
+	replayLock.Lock()
+	defer replayLock.Unlock()
+
+	buffer := replayBuffers[gameId]
+	if buffer == nil {
+		buffer = &replayBuffer{epoch: strconv.FormatInt(time.Now().UnixNano(), 36)}
+		replayBuffers[gameId] = buffer
+	}
+	return buffer
+}
+
+// forgetMessages throws away the messages of a game that is over.
+func forgetMessages(gameId int) {
+	replayLock.Lock()
+	defer replayLock.Unlock()
+
+	delete(replayBuffers, gameId)
+}
+
+// record gives the message the next sequence number and the buffer's epoch,
+// keeps it, and passes
+// it to send. Holding the lock while sending makes sure messages go out in
+// the order of their sequence numbers.
+func (buffer *replayBuffer) record(message map[string]interface{}, send func([]byte) error) error {
+	buffer.lock.Lock()
+	defer buffer.lock.Unlock()
+
+	message[protocol.SEQ_KEY] = buffer.lastSeq + 1
+	message[protocol.EPOCH_KEY] = buffer.epoch
+	rawMessage, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	buffer.lastSeq++
+
+	buffer.messages = append(buffer.messages, rawMessage)
+	if len(buffer.messages) > REPLAY_BUFFER_SIZE {
+		buffer.messages = buffer.messages[len(buffer.messages)-REPLAY_BUFFER_SIZE:]
+	}
+
+	return send(rawMessage)
+}
+
+// since gets the messages after the given sequence number of the given
+// epoch, and the latest sequence number and the buffer's epoch. If some of
+// the messages are no longer kept, or the sequence number is from another
+// epoch, complete is false and the player has to catch up some other way.
+func (buffer *replayBuffer) since(epoch string, lastSeq int) (messages []json.RawMessage, currentSeq int, currentEpoch string, complete bool) {
+	buffer.lock.Lock()
+	defer buffer.lock.Unlock()
+
+	messages = make([]json.RawMessage, 0)
+	firstSeq := buffer.lastSeq - len(buffer.messages) + 1
+	if epoch != buffer.epoch || lastSeq > buffer.lastSeq || lastSeq+1 < firstSeq {
+		return messages, buffer.lastSeq, buffer.epoch, false
+	}
+
+	for _, message := range buffer.messages[lastSeq+1-firstSeq:] {
+		messages = append(messages, json.RawMessage(message))
+	}
+	return messages, buffer.lastSeq, buffer.epoch, true
+}
@@ -0,0 +1,66 @@
+package gameserver
+
+import (
+	"resistance/game"
+	"resistance/protocol"
+	"resistance/pubsub"
+	"resistance/users"
+	"resistance/utils"
+	"strconv"
+)
+
+// spectatorMessages are the messages people who aren't playing can send.
+// Anything else is a game action, and only players get to take those.
+var spectatorMessages = map[string]bool{
+	protocol.PLAYER_CONNECT_MESSAGE:   true,
+	protocol.SPECTATE_MESSAGE:         true,
+	protocol.GET_PLAYERS_MESSAGE:      true,
+	protocol.QUERY_GAME_STATE_MESSAGE: true,
+	protocol.RESUME_MESSAGE:           true,
+	protocol.PONG_MESSAGE:             true}
+
+// isAllowedMessage checks that the user can send the given message to the
+// game, which spectators mostly can't.
+func isAllowedMessage(message map[string]interface{}, currentGame *game.Game, user *users.User) bool {
+	messageName, _ := message[protocol.MESSAGE_KEY].(string)
+	return spectatorMessages[messageName] || currentGame.IsPlayer(user)
+}
+
+// handleSpectate handles the message that is sent when someone loads the
+// game page to watch rather than play. They get the same public messages as
+// the players, through the game's spectator topic.
+func handleSpectate(currentGame *game.Game, spectator *users.User, publisher pubsub.Publisher) map[string]interface{} {
+	if currentGame.IsFinished() {
+		return getShowTextMessage("This game is over.")
+	}
+	// Someone who has left the lobby is free to come back and watch
+	player := currentGame.GetPlayer(spectator)
+	if player != nil && (currentGame.GameStatus != game.STATUS_LOBBY || player.GetConnections() > 0) {
+		return getShowTextMessage("You are playing in this game.")
+	}
+
+	utils.LogMessage("Spectator "+strconv.Itoa(spectator.UserId)+" watching game "+strconv.Itoa(currentGame.GameId), utils.RGAME_LOG_PATH)
+	currentGame.AddSpectator(spectator)
+	sendMessageToSubscribers(currentGame.GameId, getPlayersMessage(currentGame), publisher)
+
+	var returnMessage = make(map[string]interface{})
+	returnMessage[protocol.MESSAGE_KEY] = protocol.PLAYER_CONNECT_SUCCESSFUL_MESSAGE
+	returnMessage[protocol.GAME_ID_KEY] = currentGame.GameId
+	returnMessage[protocol.ACCEPT_USER_KEY] = true
+	returnMessage[protocol.SPECTATE_KEY] = true
+	returnMessage[protocol.USER_ID_KEY] = spectator.UserId
+	returnMessage[protocol.UPDATE_GAME_PROGRESS_KEY] = currentGame.GameStatus == game.STATUS_IN_PROGRESS
+	returnMessage[protocol.PROTOCOL_VERSION_KEY] = protocol.PROTOCOL_VERSION
+	returnMessage[protocol.GAME_STATE_KEY] = currentGame.GetState(nil)
+	return returnMessage
+}
+
+// handleSpectatorDisconnect handles a spectator's connection going away.
+func handleSpectatorDisconnect(currentGame *game.Game, spectator *users.User, publisher pubsub.Publisher) {
+	if !currentGame.IsSpectator(spectator) {
+		return
+	}
+
+	currentGame.SpectatorDisconnect(spectator)
+	sendMessageToSubscribers(currentGame.GameId, getPlayersMessage(currentGame), publisher)
+}
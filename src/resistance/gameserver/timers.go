@@ -0,0 +1,255 @@
+package gameserver
+
+import (
+	"encoding/json"
+	"resistance/game"
+	"resistance/protocol"
+	"resistance/pubsub"
+	"resistance/rpc"
+	"resistance/users"
+	"resistance/utils"
+	"strconv"
+	"time"
+)
+
+// timerServer is the server timers post their timeouts to, so the moves
+// made for players who ran out of time are made on the game's worker, in
+// order with everyone else's. Set up by NewServer.
+var timerServer *rpc.Server
+
+// updateTimer starts the clock on the move the game is waiting on, unless
+// it is already running. Called after everything that can move the game
+// on. While the game is paused the clock counts down the grace period of
+// the players it is waiting on instead, and the move gets the full time
+// again once everyone is back.
+func updateTimer(currentGame *game.Game, publisher pubsub.Publisher) {
+	gameId := currentGame.GameId
+	phaseKey := currentGame.GetPhaseKey()
+	timeLimit := currentGame.GetTimeLimit(currentGame.GetPhase())
+	if currentGame.IsPaused() {
+		timeLimit = currentGame.GetGracePeriod()
+	}
+	inProgress := currentGame.GameStatus == game.STATUS_IN_PROGRESS
+	if !inProgress {
+		timeLimit = 0
+	}
+
+	deadlinePhaseKey, _ := currentGame.GetDeadline()
+	if timeLimit == 0 {
+		if deadlinePhaseKey != "" {
+			currentGame.ClearDeadline()
+			// A game that is over has nothing left to time
+			if inProgress {
+				sendMessageToSubscribers(gameId, getTimerMessage(currentGame), publisher)
+			}
+		}
+		return
+	}
+	if deadlinePhaseKey == phaseKey {
+		return
+	}
+
+	deadline := time.Now().Add(timeLimit)
+	currentGame.SetDeadline(phaseKey, deadline)
+	time.AfterFunc(timeLimit, func() {
+		postTimeout(gameId, deadline)
+	})
+
+	sendMessageToSubscribers(gameId, getTimerMessage(currentGame), publisher)
+}
+
+// postTimeout queues the timeout of the given deadline of the given game.
+func postTimeout(gameId int, deadline time.Time) {
+	request := &rpc.TimeoutRequest{GameId: strconv.Itoa(gameId), Deadline: deadline.UnixNano()}
+	err := timerServer.Post(rpc.TIMEOUT_METHOD, request)
+	if err != nil {
+		utils.LogMessage("Error posting timeout of game "+strconv.Itoa(gameId)+": "+err.Error(), utils.RGAME_LOG_PATH)
+	}
+}
+
+// handleTimeout handles the request a timer posts when it goes off, by
+// making the move the game is still waiting on for whoever hasn't made it,
+// or, if the game is paused, by letting the host replace whoever it is
+// waiting on.
+func handleTimeout(body json.RawMessage, publisher pubsub.Publisher) (interface{}, error) {
+	var request rpc.TimeoutRequest
+	err := rpc.Decode(body, &request)
+	if err != nil {
+		return nil, err
+	}
+
+	gameId, err := strconv.Atoi(request.GameId)
+	if err != nil {
+		return nil, err
+	}
+
+	currentGame, err := persister.ReadGame(gameId)
+	if err != nil {
+		return nil, err
+	}
+
+	// The move was made in time, or the clock was stopped and started over
+	// since this timer was set
+	phaseKey, deadline := currentGame.GetDeadline()
+	if phaseKey != currentGame.GetPhaseKey() {
+		return &rpc.TimeoutReply{}, nil
+	}
+
+	// Everyone the game is waiting on has their own grace period, so the
+	// timer can be for any of them. The deadline stays, so the clock
+	// doesn't start over while the game keeps waiting.
+	if currentGame.IsPaused() {
+		if isGraceDeadline(currentGame, request.Deadline) {
+			utils.LogMessage("Grace period over in game "+request.GameId, utils.RGAME_LOG_PATH)
+			if currentGame.HandOverHost() {
+				utils.LogMessage("Making "+currentGame.Host.Username+" the host of game "+request.GameId, utils.RGAME_LOG_PATH)
+				sendMessageToSubscribers(gameId, getPlayersMessage(currentGame), publisher)
+			}
+			sendMessageToSubscribers(gameId, getGracePeriodOverMessage(currentGame), publisher)
+		}
+		return &rpc.TimeoutReply{}, nil
+	}
+	if deadline.UnixNano() != request.Deadline {
+		return &rpc.TimeoutReply{}, nil
+	}
+
+	utils.LogMessage("Time ran out in game "+request.GameId+" waiting on "+currentGame.GetPhase(), utils.RGAME_LOG_PATH)
+	currentGame.ClearDeadline()
+	makeTimedOutMoves(currentGame, publisher)
+
+	runBots(currentGame, publisher)
+	updateTimer(currentGame, publisher)
+	return &rpc.TimeoutReply{}, nil
+}
+
+// startGraceTimer starts the clock on the grace period of the given user,
+// who just left the paused game, if it runs past the game's deadline. The
+// game's own timer covers everyone who left before it was paused.
+func startGraceTimer(currentGame *game.Game, user *users.User) {
+	player := currentGame.GetPlayer(user)
+	phaseKey, deadline := currentGame.GetDeadline()
+	if player == nil || player.GetConnections() > 0 || phaseKey != currentGame.GetPhaseKey() || !currentGame.IsPaused() {
+		return
+	}
+
+	graceDeadline := currentGame.GetGraceDeadline(player)
+	if !graceDeadline.After(deadline) {
+		return
+	}
+
+	gameId := currentGame.GameId
+	time.AfterFunc(graceDeadline.Sub(time.Now()), func() {
+		postTimeout(gameId, graceDeadline)
+	})
+}
+
+// isGraceDeadline checks whether the given deadline, in unix nanoseconds, is
+// when the grace period of any of the players the game is waiting on is over.
+func isGraceDeadline(currentGame *game.Game, deadline int64) bool {
+	for _, player := range currentGame.GetMissingPlayers() {
+		if currentGame.GetGraceDeadline(player).UnixNano() == deadline {
+			return true
+		}
+	}
+	return false
+}
+
+// makeTimedOutMoves makes the move the game is waiting on for everyone who
+// hasn't made it, the same way a person's move is made: a random team for
+// the leader, an approval for a vote, and a success for a mission card.
+// The moves are marked as timed out on the mission.
+func makeTimedOutMoves(currentGame *game.Game, publisher pubsub.Publisher) {
+	currentMission := currentGame.GetCurrentMission()
+
+	switch currentGame.GetPhase() {
+	case game.PHASE_TEAM_SELECTION:
+		team := make([]interface{}, 0)
+		for _, user := range currentGame.GetRandomTeam() {
+			team = append(team, strconv.Itoa(user.UserId))
+		}
+		currentMission.TeamTimedOut = true
+
+		message := make(map[string]interface{})
+		message[protocol.TEAMS_KEY] = team
+		handleStartMission(message, currentGame, currentMission.Leader, publisher)
+	case game.PHASE_VOTING:
+		for _, user := range getUsersYetToMove(currentGame, currentMission) {
+			currentMission.TimedOutVotes[user.UserId] = true
+
+			message := make(map[string]interface{})
+			message[protocol.VOTE_KEY] = true
+			handleApproveTeam(message, currentGame, user, publisher)
+		}
+	case game.PHASE_MISSION:
+		for _, user := range getUsersYetToMove(currentGame, currentMission) {
+			// Spies who don't play a card don't get to fail the mission
+			currentMission.TimedOutOutcomes[user.UserId] = true
+
+			message := make(map[string]interface{})
+			message[protocol.OUTCOME_KEY] = true
+			handleMissionOutcome(message, currentGame, user, publisher)
+		}
+	}
+}
+
+// getUsersYetToMove gets the players who haven't voted on the team of the
+// given mission yet, or, once everyone has, the players on the team who
+// haven't played a card yet.
+func getUsersYetToMove(currentGame *game.Game, currentMission *game.Mission) []*users.User {
+	usersYetToMove := make([]*users.User, 0)
+	allVotesIn := currentMission.IsAllVotesCollected()
+	for _, player := range currentGame.Players {
+		userId := player.User.UserId
+		if !allVotesIn {
+			if _, voted := currentMission.Votes[userId]; !voted {
+				usersYetToMove = append(usersYetToMove, player.User)
+			}
+		} else if outcome, onTeam := currentMission.Team[userId]; onTeam && outcome == game.OUTCOME_NONE {
+			usersYetToMove = append(usersYetToMove, player.User)
+		}
+	}
+	return usersYetToMove
+}
+
+// getTimerMessage builds up the message telling everyone how long is left
+// for the move the game is waiting on. No time left means no clock.
+func getTimerMessage(currentGame *game.Game) map[string]interface{} {
+	var timerMessage = make(map[string]interface{})
+	timerMessage[protocol.MESSAGE_KEY] = protocol.TIMER_MESSAGE
+	timerMessage[protocol.TIME_LEFT_KEY] = currentGame.GetTimeLeft()
+	return timerMessage
+}
+
+// handleSetTimeLimits handles the host setting, in the lobby, how long
+// players get for each move.
+func handleSetTimeLimits(message map[string]interface{}, currentGame *game.Game, host *users.User) map[string]interface{} {
+	if currentGame.Host.UserId != host.UserId {
+		return getErrorMessage("Only the host can change that.")
+	}
+	if currentGame.GameStatus != game.STATUS_LOBBY {
+		return getErrorMessage("That can only be changed before the game starts.")
+	}
+
+	rawLimits, _ := message[protocol.TIME_LIMITS_KEY].(map[string]interface{})
+	team, teamOk := rawLimits[protocol.TEAM_TIME_LIMIT_KEY].(float64)
+	vote, voteOk := rawLimits[protocol.VOTE_TIME_LIMIT_KEY].(float64)
+	mission, missionOk := rawLimits[protocol.MISSION_TIME_LIMIT_KEY].(float64)
+	disconnect, disconnectOk := rawLimits[protocol.DISCONNECT_TIME_LIMIT_KEY].(float64)
+	if !teamOk || !voteOk || !missionOk || !disconnectOk {
+		return getErrorMessage("Every time limit has to be given.")
+	}
+
+	timeLimits := game.TimeLimits{Team: int(team), Vote: int(vote), Mission: int(mission), Disconnect: int(disconnect)}
+	err := timeLimits.Validate()
+	if err != nil {
+		return getErrorMessage(err.Error())
+	}
+
+	currentGame.TimeLimits = timeLimits
+	err = currentGame.Persister.PersistGame(currentGame)
+	if err != nil {
+		utils.LogMessage(err.Error(), utils.RGAME_LOG_PATH)
+	}
+
+	return make(map[string]interface{})
+}
@@ -0,0 +1,86 @@
+package persist
+
+import (
+	"errors"
+	"resistance/game"
+	"strconv"
+	"sync"
+)
+
+// MemoryPersister keeps games in memory only, for running games without a
+// database, like the simulator does. Nothing survives a restart.
+type MemoryPersister struct {
+	lock          sync.Mutex
+	lastGameId    int
+	lastMissionId int
+	games         map[int]*game.Game
+}
+
+func NewMemoryPersister() *MemoryPersister {
+	return &MemoryPersister{games: make(map[int]*game.Game)}
+}
+
+func (persister *MemoryPersister) PersistGame(currentGame *game.Game) error {
+	if currentGame != nil {
+		persister.lock.Lock()
+		defer persister.lock.Unlock()
+
+		if currentGame.GameId <= 0 {
+			persister.lastGameId++
+			currentGame.GameId = persister.lastGameId
+		}
+		persister.games[currentGame.GameId] = currentGame
+	}
+
+	return nil
+}
+
+func (persister *MemoryPersister) PersistMission(currentMission *game.Mission) error {
+	if currentMission != nil {
+		persister.lock.Lock()
+		defer persister.lock.Unlock()
+
+		if currentMission.MissionId <= 0 {
+			persister.lastMissionId++
+			currentMission.MissionId = persister.lastMissionId
+		}
+	}
+
+	return nil
+}
+
+// PersistChatMessage does nothing, the chat is kept with the game.
+func (persister *MemoryPersister) PersistChatMessage(message *game.ChatMessage) error {
+	return nil
+}
+
+// PersistReplacement does nothing, the players are kept with the game.
+func (persister *MemoryPersister) PersistReplacement(replaced *game.Player, replacement *game.Player) error {
+	return nil
+}
+
+// PersistKick does nothing, the player is kept with the game.
+func (persister *MemoryPersister) PersistKick(kicked *game.Player) error {
+	return nil
+}
+
+// ReadGame returns the game corresponding to the given gameId.
+func (persister *MemoryPersister) ReadGame(gameId int) (*game.Game, error) {
+	persister.lock.Lock()
+	defer persister.lock.Unlock()
+
+	retrievedGame := persister.games[gameId]
+	if retrievedGame == nil {
+		return nil, errors.New("Invalid game id: " + strconv.Itoa(gameId))
+	}
+	return retrievedGame, nil
+}
+
+// ForgetGame throws away the game corresponding to the given gameId, once
+// no one needs it anymore.
+func (persister *MemoryPersister) ForgetGame(gameId int) {
+	persister.lock.Lock()
+	defer persister.lock.Unlock()
+
+	delete(persister.games, gameId)
+}
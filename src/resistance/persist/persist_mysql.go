@@ -7,14 +7,27 @@ import (
 	"resistance/users"
 	"resistance/utils"
 	"strconv"
+	"sync"
 )
 
 const (
-	GAMES_TABLE         = "games"
-	GAMES_ID_COLUMN     = "game_id"
-	GAMES_TITLE_COLUMN  = "title"
-	GAMES_HOST_COLUMN   = "host_id"
-	GAMES_STATUS_COLUMN = "status"
+	GAMES_TABLE           = "games"
+	GAMES_ID_COLUMN       = "game_id"
+	GAMES_TITLE_COLUMN    = "title"
+	GAMES_HOST_COLUMN     = "host_id"
+	GAMES_STATUS_COLUMN   = "status"
+	GAMES_RANKED_COLUMN   = "ranked"
+	GAMES_SPY_CHAT_COLUMN = "spy_chat"
+	GAMES_LOCKED_COLUMN   = "locked"
+
+	GAMES_PRIVATE_COLUMN       = "private"
+	GAMES_INVITE_CODE_COLUMN   = "invite_code"
+	GAMES_PASSWORD_HASH_COLUMN = "password_hash"
+
+	GAMES_TEAM_TIME_LIMIT_COLUMN       = "team_time_limit"
+	GAMES_VOTE_TIME_LIMIT_COLUMN       = "vote_time_limit"
+	GAMES_MISSION_TIME_LIMIT_COLUMN    = "mission_time_limit"
+	GAMES_DISCONNECT_TIME_LIMIT_COLUMN = "disconnect_time_limit"
 )
 
 const (
@@ -24,14 +37,19 @@ const (
 	MISSIONS_MISSION_NUM_COLUMN = "mission_num"
 	MISSIONS_LEADER_ID_COLUMN   = "leader_id"
 	MISSIONS_RESULT_COLUMN      = "winner"
+	MISSIONS_TIMED_OUT_COLUMN   = "team_timed_out"
 )
 
 const (
-	PLAYERS_TABLE            = "players"
-	PLAYERS_GAME_ID_COLUMN   = "game_id"
-	PLAYERS_USER_ID_COLUMN   = "user_id"
-	PLAYERS_ROLE_COLUMN      = "role"
-	PLAYERS_JOIN_DATE_COLUMN = "join_date"
+	PLAYERS_TABLE              = "players"
+	PLAYERS_GAME_ID_COLUMN     = "game_id"
+	PLAYERS_USER_ID_COLUMN     = "user_id"
+	PLAYERS_ROLE_COLUMN        = "role"
+	PLAYERS_JOIN_DATE_COLUMN   = "join_date"
+	PLAYERS_STRATEGY_COLUMN    = "strategy"
+	PLAYERS_REPLACED_BY_COLUMN = "replaced_by"
+	PLAYERS_SEAT_COLUMN        = "seat"
+	PLAYERS_KICKED_COLUMN      = "kicked"
 )
 
 const (
@@ -39,6 +57,17 @@ const (
 	TEAMS_MISSION_ID_COLUMN = "mission_id"
 	TEAMS_USER_ID_COLUMN    = "user_id"
 	TEAMS_OUTCOME_COLUMN    = "outcome"
+	TEAMS_TIMED_OUT_COLUMN  = "timed_out"
+)
+
+const (
+	CHAT_TABLE                = "chat_messages"
+	CHAT_ID_COLUMN            = "message_id"
+	CHAT_GAME_ID_COLUMN       = "game_id"
+	CHAT_USER_ID_COLUMN       = "user_id"
+	CHAT_CHANNEL_COLUMN       = "channel"
+	CHAT_MESSAGE_COLUMN       = "message"
+	CHAT_CREATION_DATE_COLUMN = "creation_date"
 )
 
 const (
@@ -46,27 +75,50 @@ const (
 	VOTES_MISSION_ID_COLUMN = "mission_id"
 	VOTES_USER_ID_COLUMN    = "user_id"
 	VOTES_VOTE_COLUMN       = "vote"
+	VOTES_TIMED_OUT_COLUMN  = "timed_out"
 )
 
 const (
 	GAME_CREATE_QUERY = "INSERT INTO " + GAMES_TABLE +
 		" (" + GAMES_TITLE_COLUMN + "," +
 		GAMES_HOST_COLUMN + "," +
-		GAMES_STATUS_COLUMN + ") " +
-		"VALUES (?, ?, ?)"
+		GAMES_STATUS_COLUMN + "," +
+		GAMES_RANKED_COLUMN + "," +
+		GAMES_SPY_CHAT_COLUMN + "," +
+		GAMES_PRIVATE_COLUMN + "," +
+		GAMES_INVITE_CODE_COLUMN + "," +
+		GAMES_PASSWORD_HASH_COLUMN + "," +
+		GAMES_TEAM_TIME_LIMIT_COLUMN + "," +
+		GAMES_VOTE_TIME_LIMIT_COLUMN + "," +
+		GAMES_MISSION_TIME_LIMIT_COLUMN + "," +
+		GAMES_DISCONNECT_TIME_LIMIT_COLUMN + ") " +
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
 	GAME_PERSIST_QUERY = "UPDATE " + GAMES_TABLE +
 		" SET " +
 		GAMES_TITLE_COLUMN + " = ?, " +
 		GAMES_HOST_COLUMN + " = ?, " +
-		GAMES_STATUS_COLUMN + " = ? " +
+		GAMES_STATUS_COLUMN + " = ?, " +
+		GAMES_SPY_CHAT_COLUMN + " = ?, " +
+		GAMES_LOCKED_COLUMN + " = ?, " +
+		GAMES_PRIVATE_COLUMN + " = ?, " +
+		GAMES_INVITE_CODE_COLUMN + " = ?, " +
+		GAMES_PASSWORD_HASH_COLUMN + " = ?, " +
+		GAMES_TEAM_TIME_LIMIT_COLUMN + " = ?, " +
+		GAMES_VOTE_TIME_LIMIT_COLUMN + " = ?, " +
+		GAMES_MISSION_TIME_LIMIT_COLUMN + " = ?, " +
+		GAMES_DISCONNECT_TIME_LIMIT_COLUMN + " = ? " +
 		" WHERE " + GAMES_ID_COLUMN + " = ?"
 	PLAYER_PERSIST_QUERY = "INSERT INTO " + PLAYERS_TABLE +
 		" (" + PLAYERS_GAME_ID_COLUMN + "," +
 		PLAYERS_USER_ID_COLUMN + "," +
-		PLAYERS_ROLE_COLUMN + ") " +
-		" VALUES (?, ?, ?) " +
+		PLAYERS_ROLE_COLUMN + "," +
+		PLAYERS_STRATEGY_COLUMN + "," +
+		PLAYERS_SEAT_COLUMN + ") " +
+		" VALUES (?, ?, ?, ?, ?) " +
 		" ON DUPLICATE KEY UPDATE " +
-		PLAYERS_ROLE_COLUMN + " = VALUES(" + PLAYERS_ROLE_COLUMN + ")"
+		PLAYERS_ROLE_COLUMN + " = VALUES(" + PLAYERS_ROLE_COLUMN + "), " +
+		PLAYERS_STRATEGY_COLUMN + " = VALUES(" + PLAYERS_STRATEGY_COLUMN + "), " +
+		PLAYERS_SEAT_COLUMN + " = VALUES(" + PLAYERS_SEAT_COLUMN + ")"
 	MISSION_CREATE_QUERY = "INSERT INTO " + MISSIONS_TABLE +
 		" (" + MISSIONS_GAME_ID_COLUMN + "," +
 		MISSIONS_MISSION_NUM_COLUMN + "," +
@@ -78,24 +130,52 @@ const (
 		MISSIONS_GAME_ID_COLUMN + "," +
 		MISSIONS_MISSION_NUM_COLUMN + "," +
 		MISSIONS_LEADER_ID_COLUMN + "," +
-		MISSIONS_RESULT_COLUMN + ") " +
-		" VALUES (?, ?, ?, ?, ?) " +
+		MISSIONS_RESULT_COLUMN + "," +
+		MISSIONS_TIMED_OUT_COLUMN + ") " +
+		" VALUES (?, ?, ?, ?, ?, ?) " +
 		" ON DUPLICATE KEY UPDATE " +
-		MISSIONS_RESULT_COLUMN + " = VALUES(" + MISSIONS_RESULT_COLUMN + ")"
+		MISSIONS_RESULT_COLUMN + " = VALUES(" + MISSIONS_RESULT_COLUMN + "), " +
+		MISSIONS_TIMED_OUT_COLUMN + " = VALUES(" + MISSIONS_TIMED_OUT_COLUMN + ")"
 	TEAM_PERSIST_QUERY = "INSERT INTO " + TEAMS_TABLE +
 		" (" + TEAMS_MISSION_ID_COLUMN + "," +
 		TEAMS_USER_ID_COLUMN + "," +
-		TEAMS_OUTCOME_COLUMN + ") " +
-		" VALUES (?, ?, ?) " +
+		TEAMS_OUTCOME_COLUMN + "," +
+		TEAMS_TIMED_OUT_COLUMN + ") " +
+		" VALUES (?, ?, ?, ?) " +
 		" ON DUPLICATE KEY UPDATE " +
-		TEAMS_OUTCOME_COLUMN + " = VALUES(" + TEAMS_OUTCOME_COLUMN + ")"
+		TEAMS_OUTCOME_COLUMN + " = VALUES(" + TEAMS_OUTCOME_COLUMN + "), " +
+		TEAMS_TIMED_OUT_COLUMN + " = VALUES(" + TEAMS_TIMED_OUT_COLUMN + ")"
 	VOTE_PERSIST_QUERY = "INSERT INTO " + VOTES_TABLE +
 		" (" + VOTES_MISSION_ID_COLUMN + "," +
 		VOTES_USER_ID_COLUMN + "," +
-		VOTES_VOTE_COLUMN + ") " +
-		" VALUES (?, ?, ?) " +
+		VOTES_VOTE_COLUMN + "," +
+		VOTES_TIMED_OUT_COLUMN + ") " +
+		" VALUES (?, ?, ?, ?) " +
 		" ON DUPLICATE KEY UPDATE " +
-		VOTES_VOTE_COLUMN + " = VALUES(" + VOTES_VOTE_COLUMN + ")"
+		VOTES_VOTE_COLUMN + " = VALUES(" + VOTES_VOTE_COLUMN + "), " +
+		VOTES_TIMED_OUT_COLUMN + " = VALUES(" + VOTES_TIMED_OUT_COLUMN + ")"
+	PLAYER_REPLACED_QUERY = "UPDATE " + PLAYERS_TABLE +
+		" SET " + PLAYERS_REPLACED_BY_COLUMN + " = ? " +
+		" WHERE " + PLAYERS_GAME_ID_COLUMN + " = ? AND " + PLAYERS_USER_ID_COLUMN + " = ?"
+	PLAYER_KICKED_QUERY = "UPDATE " + PLAYERS_TABLE +
+		" SET " + PLAYERS_KICKED_COLUMN + " = 1 " +
+		" WHERE " + PLAYERS_GAME_ID_COLUMN + " = ? AND " + PLAYERS_USER_ID_COLUMN + " = ?"
+	MISSION_REPLACE_LEADER_QUERY = "UPDATE " + MISSIONS_TABLE +
+		" SET " + MISSIONS_LEADER_ID_COLUMN + " = ? " +
+		" WHERE " + MISSIONS_ID_COLUMN + " = ? AND " + MISSIONS_LEADER_ID_COLUMN + " = ?"
+	TEAM_REPLACE_USER_QUERY = "UPDATE " + TEAMS_TABLE +
+		" SET " + TEAMS_USER_ID_COLUMN + " = ? " +
+		" WHERE " + TEAMS_MISSION_ID_COLUMN + " = ? AND " + TEAMS_USER_ID_COLUMN + " = ?"
+	VOTE_REPLACE_USER_QUERY = "UPDATE " + VOTES_TABLE +
+		" SET " + VOTES_USER_ID_COLUMN + " = ? " +
+		" WHERE " + VOTES_MISSION_ID_COLUMN + " = ? AND " + VOTES_USER_ID_COLUMN + " = ?"
+	CHAT_PERSIST_QUERY = "INSERT INTO " + CHAT_TABLE +
+		" (" + CHAT_GAME_ID_COLUMN + "," +
+		CHAT_USER_ID_COLUMN + "," +
+		CHAT_CHANNEL_COLUMN + "," +
+		CHAT_MESSAGE_COLUMN + "," +
+		CHAT_CREATION_DATE_COLUMN + ") " +
+		" VALUES (?, ?, ?, ?, ?)"
 )
 
 const (
@@ -103,43 +183,85 @@ const (
 		GAMES_TABLE + "." + GAMES_TITLE_COLUMN + "," +
 		users.USERS_TABLE + "." + users.USERS_ID_COLUMN + "," +
 		users.USERS_TABLE + "." + users.USERS_USERNAME_COLUMN + "," +
-		GAMES_TABLE + "." + GAMES_STATUS_COLUMN +
+		GAMES_TABLE + "." + GAMES_STATUS_COLUMN + "," +
+		GAMES_TABLE + "." + GAMES_RANKED_COLUMN + "," +
+		GAMES_TABLE + "." + GAMES_SPY_CHAT_COLUMN + "," +
+		GAMES_TABLE + "." + GAMES_LOCKED_COLUMN + "," +
+		GAMES_TABLE + "." + GAMES_PRIVATE_COLUMN + "," +
+		GAMES_TABLE + "." + GAMES_INVITE_CODE_COLUMN + "," +
+		GAMES_TABLE + "." + GAMES_PASSWORD_HASH_COLUMN + "," +
+		GAMES_TABLE + "." + GAMES_TEAM_TIME_LIMIT_COLUMN + "," +
+		GAMES_TABLE + "." + GAMES_VOTE_TIME_LIMIT_COLUMN + "," +
+		GAMES_TABLE + "." + GAMES_MISSION_TIME_LIMIT_COLUMN + "," +
+		GAMES_TABLE + "." + GAMES_DISCONNECT_TIME_LIMIT_COLUMN +
 		" FROM " + GAMES_TABLE + " LEFT JOIN " + users.USERS_TABLE + " ON " +
 		users.USERS_TABLE + "." + users.USERS_ID_COLUMN + " = " + GAMES_TABLE + "." + GAMES_HOST_COLUMN +
 		" WHERE " + GAMES_ID_COLUMN + " = ?"
 	PLAYERS_READ_QUERY = "SELECT " +
 		PLAYERS_TABLE + "." + PLAYERS_ROLE_COLUMN + "," +
+		PLAYERS_TABLE + "." + PLAYERS_STRATEGY_COLUMN + "," +
+		PLAYERS_TABLE + "." + PLAYERS_REPLACED_BY_COLUMN + "," +
+		PLAYERS_TABLE + "." + PLAYERS_KICKED_COLUMN + "," +
 		users.USERS_TABLE + "." + users.USERS_ID_COLUMN + "," +
 		users.USERS_TABLE + "." + users.USERS_USERNAME_COLUMN +
 		" FROM " + PLAYERS_TABLE + " LEFT JOIN " + users.USERS_TABLE + " ON " +
 		users.USERS_TABLE + "." + users.USERS_ID_COLUMN + " = " + PLAYERS_TABLE + "." + PLAYERS_USER_ID_COLUMN +
-		" WHERE " + PLAYERS_GAME_ID_COLUMN + " = ?"
+		" WHERE " + PLAYERS_GAME_ID_COLUMN + " = ?" +
+		" ORDER BY " + PLAYERS_SEAT_COLUMN + ", " + PLAYERS_JOIN_DATE_COLUMN
 	MISSION_READ_QUERY = "SELECT " +
 		MISSIONS_TABLE + "." + MISSIONS_ID_COLUMN + "," +
 		MISSIONS_TABLE + "." + MISSIONS_MISSION_NUM_COLUMN + "," +
 		users.USERS_TABLE + "." + users.USERS_ID_COLUMN + "," +
 		users.USERS_TABLE + "." + users.USERS_USERNAME_COLUMN + "," +
-		MISSIONS_TABLE + "." + MISSIONS_RESULT_COLUMN +
+		MISSIONS_TABLE + "." + MISSIONS_RESULT_COLUMN + "," +
+		MISSIONS_TABLE + "." + MISSIONS_TIMED_OUT_COLUMN +
 		" FROM " + MISSIONS_TABLE + " LEFT JOIN " + users.USERS_TABLE + " ON " +
 		users.USERS_TABLE + "." + users.USERS_ID_COLUMN + " = " + MISSIONS_TABLE + "." + MISSIONS_LEADER_ID_COLUMN +
 		" WHERE " + MISSIONS_GAME_ID_COLUMN + " = ?"
 	VOTE_READ_QUERY = "SELECT " +
 		VOTES_TABLE + "." + VOTES_USER_ID_COLUMN + "," +
-		VOTES_TABLE + "." + VOTES_VOTE_COLUMN +
+		VOTES_TABLE + "." + VOTES_VOTE_COLUMN + "," +
+		VOTES_TABLE + "." + VOTES_TIMED_OUT_COLUMN +
 		" FROM " + VOTES_TABLE +
 		" WHERE " + VOTES_MISSION_ID_COLUMN + " = ?"
+	CHAT_READ_QUERY = "SELECT " +
+		CHAT_TABLE + "." + CHAT_USER_ID_COLUMN + "," +
+		users.USERS_TABLE + "." + users.USERS_USERNAME_COLUMN + "," +
+		CHAT_TABLE + "." + CHAT_CHANNEL_COLUMN + "," +
+		CHAT_TABLE + "." + CHAT_MESSAGE_COLUMN + "," +
+		CHAT_TABLE + "." + CHAT_CREATION_DATE_COLUMN +
+		" FROM " + CHAT_TABLE + " LEFT JOIN " + users.USERS_TABLE + " ON " +
+		users.USERS_TABLE + "." + users.USERS_ID_COLUMN + " = " + CHAT_TABLE + "." + CHAT_USER_ID_COLUMN +
+		" WHERE " + CHAT_GAME_ID_COLUMN + " = ?" +
+		" ORDER BY " + CHAT_TABLE + "." + CHAT_ID_COLUMN
 	TEAM_READ_QUERY = "SELECT " +
 		TEAMS_TABLE + "." + TEAMS_USER_ID_COLUMN + "," +
-		TEAMS_TABLE + "." + TEAMS_OUTCOME_COLUMN +
+		TEAMS_TABLE + "." + TEAMS_OUTCOME_COLUMN + "," +
+		TEAMS_TABLE + "." + TEAMS_TIMED_OUT_COLUMN +
 		" FROM " + TEAMS_TABLE +
 		" WHERE " + TEAMS_MISSION_ID_COLUMN + " = ?"
-	GAME_STATUS_FILTER = "SELECT " +
-		GAMES_ID_COLUMN +
-		" FROM " + GAMES_TABLE +
-		" WHERE " + GAMES_STATUS_COLUMN + " = ?"
+	PUBLIC_GAMES_FILTER = "SELECT " +
+		GAMES_TABLE + "." + GAMES_ID_COLUMN + "," +
+		GAMES_TABLE + "." + GAMES_TITLE_COLUMN + "," +
+		"COALESCE(" + users.USERS_TABLE + "." + users.USERS_USERNAME_COLUMN + ", '')," +
+		GAMES_TABLE + "." + GAMES_RANKED_COLUMN +
+		" FROM " + GAMES_TABLE + " LEFT JOIN " + users.USERS_TABLE + " ON " +
+		users.USERS_TABLE + "." + users.USERS_ID_COLUMN + " = " + GAMES_TABLE + "." + GAMES_HOST_COLUMN +
+		" WHERE " + GAMES_TABLE + "." + GAMES_STATUS_COLUMN + " = ?" +
+		" AND " + GAMES_TABLE + "." + GAMES_PRIVATE_COLUMN + " = 0" +
+		" ORDER BY " + GAMES_TABLE + "." + GAMES_ID_COLUMN
+	USER_GAMES_FILTER = "SELECT " +
+		GAMES_TABLE + "." + GAMES_ID_COLUMN +
+		" FROM " + GAMES_TABLE + " JOIN " + PLAYERS_TABLE + " ON " +
+		PLAYERS_TABLE + "." + PLAYERS_GAME_ID_COLUMN + " = " + GAMES_TABLE + "." + GAMES_ID_COLUMN +
+		" WHERE " + PLAYERS_TABLE + "." + PLAYERS_USER_ID_COLUMN + " = ?" +
+		" AND " + GAMES_TABLE + "." + GAMES_STATUS_COLUMN + " = ?"
 )
 
+// Persister reads and writes games. Games are handled on different go
+// routines, so the cache is behind a lock.
 type Persister struct {
+	lock       sync.Mutex
 	gamesCache map[int]*game.Game
 	db         *sql.DB
 }
@@ -151,16 +273,22 @@ func NewPersister() *Persister {
 	// Initialize database. Will panic if this fails.
 	db := utils.ConnectToDB()
 
-	return &Persister{gamesCache, db}
+	return &Persister{gamesCache: gamesCache, db: db}
 }
 
 func (persister *Persister) persistPlayer(currentPlayer *game.Player) error {
 	if currentPlayer != nil {
 		utils.LogMessage("Persisting a player...", utils.RESISTANCE_LOG_PATH)
+		strategyName := ""
+		if currentPlayer.IsBot() {
+			strategyName = currentPlayer.Strategy.Name()
+		}
 		_, err := persister.db.Exec(PLAYER_PERSIST_QUERY,
 			currentPlayer.GetGame().GameId,
 			currentPlayer.User.UserId,
-			currentPlayer.Role)
+			currentPlayer.Role,
+			strategyName,
+			currentPlayer.GetGame().GetSeat(currentPlayer))
 		if err != nil {
 			return err
 		}
@@ -169,6 +297,59 @@ func (persister *Persister) persistPlayer(currentPlayer *game.Player) error {
 	return nil
 }
 
+// PersistChatMessage stores something said in a game.
+func (persister *Persister) PersistChatMessage(message *game.ChatMessage) error {
+	_, err := persister.db.Exec(CHAT_PERSIST_QUERY,
+		message.GameId,
+		message.UserId,
+		message.Channel,
+		message.Text,
+		message.Time)
+	return err
+}
+
+// PersistReplacement stores the given replacement taking over the seat of
+// the given player, along with whatever the player was doing on the
+// current mission.
+func (persister *Persister) PersistReplacement(replaced *game.Player, replacement *game.Player) error {
+	currentGame := replaced.GetGame()
+	err := persister.persistPlayer(replacement)
+	if err != nil {
+		return err
+	}
+
+	_, err = persister.db.Exec(PLAYER_REPLACED_QUERY,
+		replacement.User.UserId,
+		currentGame.GameId,
+		replaced.User.UserId)
+	if err != nil {
+		return err
+	}
+
+	currentMission := currentGame.GetCurrentMission()
+	if currentMission == nil || currentMission.MissionId <= 0 {
+		return nil
+	}
+	for _, query := range []string{MISSION_REPLACE_LEADER_QUERY, TEAM_REPLACE_USER_QUERY, VOTE_REPLACE_USER_QUERY} {
+		_, err = persister.db.Exec(query,
+			replacement.User.UserId,
+			currentMission.MissionId,
+			replaced.User.UserId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PersistKick stores the given player being kicked out of the lobby.
+func (persister *Persister) PersistKick(kicked *game.Player) error {
+	_, err := persister.db.Exec(PLAYER_KICKED_QUERY,
+		kicked.GetGame().GameId,
+		kicked.User.UserId)
+	return err
+}
+
 func (persister *Persister) PersistMission(currentMission *game.Mission) error {
 	if currentMission != nil {
 		utils.LogMessage("Persisting a mission...", utils.RESISTANCE_LOG_PATH)
@@ -191,7 +372,8 @@ func (persister *Persister) PersistMission(currentMission *game.Mission) error {
 				currentMission.GetGame().GameId,
 				currentMission.MissionNum,
 				currentMission.Leader.UserId,
-				currentMission.Winner)
+				currentMission.Winner,
+				currentMission.TeamTimedOut)
 			if err != nil {
 				return err
 			}
@@ -218,7 +400,8 @@ func (persister *Persister) persistTeam(currentMission *game.Mission) error {
 		_, err := persister.db.Exec(TEAM_PERSIST_QUERY,
 			currentMission.MissionId,
 			teamMemberId,
-			outcome)
+			outcome,
+			currentMission.TimedOutOutcomes[teamMemberId])
 		if err != nil {
 			return err
 		}
@@ -231,7 +414,8 @@ func (persister *Persister) persistVotes(currentMission *game.Mission) error {
 		_, err := persister.db.Exec(VOTE_PERSIST_QUERY,
 			currentMission.MissionId,
 			userId,
-			vote)
+			vote,
+			currentMission.TimedOutVotes[userId])
 		if err != nil {
 			return err
 		}
@@ -245,12 +429,23 @@ func (persister *Persister) PersistGame(currentGame *game.Game) error {
 		// Persist the game itself
 		var err error
 		if currentGame.GameId <= 0 {
-			result, err := persister.db.Exec(GAME_CREATE_QUERY,
+			var result sql.Result
+			result, err = persister.db.Exec(GAME_CREATE_QUERY,
 				currentGame.Title,
 				currentGame.Host.UserId,
-				currentGame.GameStatus)
+				currentGame.GameStatus,
+				currentGame.Ranked,
+				currentGame.SpyChat,
+				currentGame.Private,
+				currentGame.InviteCode,
+				currentGame.PasswordHash,
+				currentGame.TimeLimits.Team,
+				currentGame.TimeLimits.Vote,
+				currentGame.TimeLimits.Mission,
+				currentGame.TimeLimits.Disconnect)
 			if err == nil {
-				newGameId, err := result.LastInsertId()
+				var newGameId int64
+				newGameId, err = result.LastInsertId()
 				if err == nil {
 					currentGame.GameId = int(newGameId)
 				}
@@ -260,6 +455,15 @@ func (persister *Persister) PersistGame(currentGame *game.Game) error {
 				currentGame.Title,
 				currentGame.Host.UserId,
 				currentGame.GameStatus,
+				currentGame.SpyChat,
+				currentGame.Locked,
+				currentGame.Private,
+				currentGame.InviteCode,
+				currentGame.PasswordHash,
+				currentGame.TimeLimits.Team,
+				currentGame.TimeLimits.Vote,
+				currentGame.TimeLimits.Mission,
+				currentGame.TimeLimits.Disconnect,
 				currentGame.GameId)
 		}
 		if err != nil {
@@ -286,7 +490,9 @@ func (persister *Persister) PersistGame(currentGame *game.Game) error {
 		}
 
 		// Finished persisting, make sure that this game is in the cache
+		persister.lock.Lock()
 		persister.gamesCache[currentGame.GameId] = currentGame
+		persister.lock.Unlock()
 	}
 
 	return nil
@@ -304,22 +510,32 @@ func (persister *Persister) ReadGame(gameId int) (game *game.Game, err error) {
 	}()
 
 	utils.LogMessage("Reading game id "+strconv.Itoa(gameId), utils.RESISTANCE_LOG_PATH)
-	utils.LogMessage("Size of gamesCache:"+strconv.Itoa(len(persister.gamesCache)), utils.RESISTANCE_LOG_PATH)
 
 	// Don't even try if not a valid game id
 	if gameId < 0 {
 		return nil, errors.New("Invalid game id: " + strconv.Itoa(gameId))
 	}
 
+	persister.lock.Lock()
 	retrievedGame := persister.gamesCache[gameId]
+	persister.lock.Unlock()
 
 	if retrievedGame == nil {
+		// Don't hold the lock while hitting the database, other games
+		// shouldn't have to wait for it
 		retrievedGame = persister.retrieveGame(gameId)
 
-		// Update the cache
+		// Update the cache, unless someone else got there first. Everyone
+		// has to end up with the same game.
 		if retrievedGame != nil {
-			utils.LogMessage("Updated the cache", utils.RESISTANCE_LOG_PATH)
-			persister.gamesCache[gameId] = retrievedGame
+			persister.lock.Lock()
+			if cachedGame := persister.gamesCache[gameId]; cachedGame != nil {
+				retrievedGame = cachedGame
+			} else {
+				utils.LogMessage("Updated the cache", utils.RESISTANCE_LOG_PATH)
+				persister.gamesCache[gameId] = retrievedGame
+			}
+			persister.lock.Unlock()
 		}
 	}
 
@@ -336,9 +552,17 @@ func (persister *Persister) retrieveGame(gameId int) *game.Game {
 	var hostId int
 	var hostUsername string
 	var gameStatus string
+	var ranked bool
+	var spyChat bool
+	var locked bool
+	var private bool
+	var inviteCode string
+	var passwordHash string
+	var timeLimits game.TimeLimits
 
 	// Query for the game
-	err := persister.db.QueryRow(GAME_READ_QUERY, gameId).Scan(&gameTitle, &hostId, &hostUsername, &gameStatus)
+	err := persister.db.QueryRow(GAME_READ_QUERY, gameId).Scan(&gameTitle, &hostId, &hostUsername, &gameStatus, &ranked, &spyChat, &locked,
+		&private, &inviteCode, &passwordHash, &timeLimits.Team, &timeLimits.Vote, &timeLimits.Mission, &timeLimits.Disconnect)
 	if err != nil {
 		utils.LogMessage("Error querying for the game:"+err.Error(), utils.RESISTANCE_LOG_PATH)
 		panic(err)
@@ -365,6 +589,13 @@ func (persister *Persister) retrieveGame(gameId int) *game.Game {
 	retrievedGame.Title = gameTitle
 	retrievedGame.GameId = gameId
 	retrievedGame.GameStatus = gameStatus
+	retrievedGame.Ranked = ranked
+	retrievedGame.SpyChat = spyChat
+	retrievedGame.Locked = locked
+	retrievedGame.Private = private
+	retrievedGame.InviteCode = inviteCode
+	retrievedGame.PasswordHash = passwordHash
+	retrievedGame.TimeLimits = timeLimits
 
 	hostUser := new(users.User)
 	hostUser.UserId = hostId
@@ -377,9 +608,12 @@ func (persister *Persister) retrieveGame(gameId int) *game.Game {
 	// Build up players
 	for playerRows.Next() {
 		var playerRole string
+		var strategyName string
+		var replacedBy int
+		var kicked bool
 		var userId int
 		var username string
-		err := playerRows.Scan(&playerRole, &userId, &username)
+		err := playerRows.Scan(&playerRole, &strategyName, &replacedBy, &kicked, &userId, &username)
 		if err != nil {
 			utils.LogMessage("Error parsing the player resluts:"+err.Error(), utils.RESISTANCE_LOG_PATH)
 			panic(err)
@@ -387,9 +621,20 @@ func (persister *Persister) retrieveGame(gameId int) *game.Game {
 		user := new(users.User)
 		user.UserId = userId
 		user.Username = username
-		newPlayer := game.NewPlayer(retrievedGame, user)
+		var newPlayer *game.Player
+		if strategy := game.NewStrategy(strategyName); strategy != nil {
+			newPlayer = game.NewBot(retrievedGame, user, strategy)
+		} else {
+			newPlayer = game.NewPlayer(retrievedGame, user)
+		}
 		newPlayer.Role = playerRole
-		retrievedGame.Players = append(retrievedGame.Players, newPlayer)
+		if replacedBy > 0 {
+			retrievedGame.Replaced = append(retrievedGame.Replaced, newPlayer)
+		} else if kicked {
+			retrievedGame.Kicked = append(retrievedGame.Kicked, newPlayer)
+		} else {
+			retrievedGame.Players = append(retrievedGame.Players, newPlayer)
+		}
 	}
 
 	// Build up missions
@@ -399,7 +644,8 @@ func (persister *Persister) retrieveGame(gameId int) *game.Game {
 		var leaderId int
 		var leaderUsername string
 		var missionResult string
-		err := missionRows.Scan(&missionId, &missionNum, &leaderId, &leaderUsername, &missionResult)
+		var teamTimedOut bool
+		err := missionRows.Scan(&missionId, &missionNum, &leaderId, &leaderUsername, &missionResult, &teamTimedOut)
 		if err != nil {
 			utils.LogMessage("Error parsing the mission results:"+err.Error(), utils.RESISTANCE_LOG_PATH)
 			panic(err)
@@ -414,6 +660,9 @@ func (persister *Persister) retrieveGame(gameId int) *game.Game {
 		mission.Winner = missionResult
 		mission.Team = make(map[int]string)
 		mission.Votes = make(map[int]string)
+		mission.TeamTimedOut = teamTimedOut
+		mission.TimedOutVotes = make(map[int]bool)
+		mission.TimedOutOutcomes = make(map[int]bool)
 
 		// Query for the votes
 		voteRows, err := persister.db.Query(VOTE_READ_QUERY, missionId)
@@ -427,12 +676,16 @@ func (persister *Persister) retrieveGame(gameId int) *game.Game {
 		for voteRows.Next() {
 			var userId int
 			var vote string
-			err := voteRows.Scan(&userId, &vote)
+			var timedOut bool
+			err := voteRows.Scan(&userId, &vote, &timedOut)
 			if err != nil {
 				utils.LogMessage("Error parsing the vote results:"+err.Error(), utils.RESISTANCE_LOG_PATH)
 				panic(err)
 			}
 			mission.Votes[userId] = vote
+			if timedOut {
+				mission.TimedOutVotes[userId] = true
+			}
 		}
 
 		// Query for the team
@@ -447,43 +700,119 @@ func (persister *Persister) retrieveGame(gameId int) *game.Game {
 		for teamRows.Next() {
 			var userId int
 			var outcome string
-			err := teamRows.Scan(&userId, &outcome)
+			var timedOut bool
+			err := teamRows.Scan(&userId, &outcome, &timedOut)
 			if err != nil {
 				utils.LogMessage("Error parsing the team results:"+err.Error(), utils.RESISTANCE_LOG_PATH)
 				panic(err)
 			}
 			mission.Team[userId] = outcome
+			if timedOut {
+				mission.TimedOutOutcomes[userId] = true
+			}
 		}
 
-		retrievedGame.Missions = append(retrievedGame.Missions, mission)
+		game.LoadMission(retrievedGame, mission)
+	}
+
+	// Query for the chat
+	chatRows, err := persister.db.Query(CHAT_READ_QUERY, gameId)
+	if err != nil {
+		utils.LogMessage("Error querying for the chat:"+err.Error(), utils.RESISTANCE_LOG_PATH)
+		panic(err)
+	}
+	defer chatRows.Close()
+
+	// Build up the chat
+	for chatRows.Next() {
+		message := new(game.ChatMessage)
+		message.GameId = gameId
+		err := chatRows.Scan(&message.UserId, &message.Username, &message.Channel, &message.Text, &message.Time)
+		if err != nil {
+			utils.LogMessage("Error parsing the chat results:"+err.Error(), utils.RESISTANCE_LOG_PATH)
+			panic(err)
+		}
+		retrievedGame.Chat = append(retrievedGame.Chat, message)
 	}
 
 	return retrievedGame
 }
 
-// GetAllGames retrieves all games of the given game status
-func (persister *Persister) GetAllGames(gameStatus string) []*game.Game {
-	utils.LogMessage("getting all games from persister", utils.RESISTANCE_LOG_PATH)
-	allGames := make([]*game.Game, 0)
+// GetPublicGames retrieves a summary of every game of the given game status
+// that isn't private. The summaries come straight from the DB rather than
+// the cache, since the cached games are being played on other go routines.
+func (persister *Persister) GetPublicGames(gameStatus string) []game.GameSummary {
+	utils.LogMessage("getting all public games from persister", utils.RESISTANCE_LOG_PATH)
+	publicGames := make([]game.GameSummary, 0)
 	if gameStatus != game.STATUS_LOBBY &&
 		gameStatus != game.STATUS_IN_PROGRESS &&
-		gameStatus != game.STATUS_DONE {
-		return allGames
+		gameStatus != game.STATUS_DONE &&
+		gameStatus != game.STATUS_ABANDONED {
+		return publicGames
+	}
+
+	result, err := persister.db.Query(PUBLIC_GAMES_FILTER, gameStatus)
+	if err != nil {
+		utils.LogMessage("Error querying for the public games: "+err.Error(), utils.RESISTANCE_LOG_PATH)
+		return publicGames
+	}
+	defer result.Close()
+
+	for result.Next() {
+		var summary game.GameSummary
+		err = result.Scan(&summary.GameId, &summary.Title, &summary.HostUsername, &summary.Ranked)
+		if err == nil {
+			publicGames = append(publicGames, summary)
+		}
+	}
+
+	return publicGames
+}
+
+// GetFinishedGames retrieves all the games the given user played to the
+// end. Finished games nobody is looking at aren't put in the cache, there
+// are too many of them and they never change.
+func (persister *Persister) GetFinishedGames(userId int) []*game.Game {
+	finishedGames := make([]*game.Game, 0)
+
+	result, err := persister.db.Query(USER_GAMES_FILTER, userId, game.STATUS_DONE)
+	if err != nil {
+		utils.LogMessage("Error querying for the games of user "+strconv.Itoa(userId)+": "+err.Error(), utils.RESISTANCE_LOG_PATH)
+		return finishedGames
 	}
+	defer result.Close()
 
-	result, err := persister.db.Query(GAME_STATUS_FILTER, gameStatus)
-	if err == nil {
-		for result.Next() {
-			var gameId int
-			err = result.Scan(&gameId)
+	for result.Next() {
+		var gameId int
+		err = result.Scan(&gameId)
+		if err == nil {
+			finishedGame, err := persister.readGameWithoutCaching(gameId)
 			if err == nil {
-				game, err := persister.ReadGame(gameId)
-				if err == nil {
-					allGames = append(allGames, game)
-				}
+				finishedGames = append(finishedGames, finishedGame)
 			}
 		}
 	}
 
-	return allGames
+	return finishedGames
+}
+
+// readGameWithoutCaching returns the game corresponding to the given gameId
+// from the cache if it is there, otherwise from the database, without
+// adding it to the cache.
+func (persister *Persister) readGameWithoutCaching(gameId int) (game *game.Game, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			utils.LogMessage("Could not retrieve game:"+r.(error).Error(), utils.RESISTANCE_LOG_PATH)
+			err = errors.New("Could not retrieve game.")
+		}
+	}()
+
+	persister.lock.Lock()
+	retrievedGame := persister.gamesCache[gameId]
+	persister.lock.Unlock()
+
+	if retrievedGame == nil {
+		retrievedGame = persister.retrieveGame(gameId)
+	}
+	return retrievedGame, nil
 }
@@ -0,0 +1,120 @@
+// Package protocol defines the messages passed between the game page and
+// the game server. The frontend's copy in frontend/protocol.js is generated
+// from here by resistancePROTOCOL, so change them here and regenerate.
+package protocol
+
+const (
+	// PROTOCOL_VERSION is bumped whenever a change would break game pages
+	// still open from before the change.
+	PROTOCOL_VERSION     = 1
+	MIN_PROTOCOL_VERSION = 1
+)
+
+const (
+	MESSAGE_KEY               = "message"
+	GAME_ID_KEY               = "gameId"
+	IS_HOST_KEY               = "isHost"
+	PLAYERS_KEY               = "players"
+	ACCEPT_USER_KEY           = "acceptUser"
+	USER_ID_KEY               = "userId"
+	ROLE_KEY                  = "role"
+	IS_LEADER_KEY             = "isLeader"
+	TEAMS_KEY                 = "team"
+	TEAM_SIZE_KEY             = "teamSize"
+	VOTE_KEY                  = "vote"
+	USERNAME_KEY              = "username"
+	IS_ON_MISSION_KEY         = "isOnMission"
+	OUTCOME_KEY               = "outcome"
+	GAME_WINNER_KEY           = "winner"
+	MISSIONS_KEY              = "missions"
+	UPDATE_GAME_PROGRESS_KEY  = "updateGameProgress"
+	TEXT_KEY                  = "text"
+	SEQ_KEY                   = "seq"
+	EPOCH_KEY                 = "epoch"
+	LAST_SEQ_KEY              = "lastSeq"
+	MESSAGES_KEY              = "messages"
+	COMPLETE_KEY              = "complete"
+	GAME_STATE_KEY            = "gameState"
+	PROTOCOL_VERSION_KEY      = "protocolVersion"
+	ERROR_MESSAGE_KEY         = "errorMessage"
+	STRATEGY_KEY              = "strategy"
+	STRATEGIES_KEY            = "strategies"
+	BOTS_KEY                  = "bots"
+	SPY_ODDS_KEY              = "spyOdds"
+	SPECTATE_KEY              = "spectate"
+	SPECTATORS_KEY            = "spectators"
+	CHANNEL_KEY               = "channel"
+	TIME_KEY                  = "time"
+	SPY_CHAT_KEY              = "spyChat"
+	TIME_LEFT_KEY             = "timeLeft"
+	TIME_LIMITS_KEY           = "timeLimits"
+	TEAM_TIME_LIMIT_KEY       = "team"
+	VOTE_TIME_LIMIT_KEY       = "vote"
+	MISSION_TIME_LIMIT_KEY    = "mission"
+	DISCONNECT_TIME_LIMIT_KEY = "disconnect"
+	REPLACEMENT_KEY           = "replacement"
+	PLAYER_IDS_KEY            = "playerIds"
+	HOST_ID_KEY               = "hostId"
+	LOCKED_KEY                = "locked"
+
+	// messages received from the frontend
+	PLAYER_CONNECT_MESSAGE         = "playerConnect"
+	GET_PLAYERS_MESSAGE            = "getPlayers"
+	START_GAME_MESSAGE             = "startGame"
+	QUERY_ROLE_MESSAGE             = "queryRole"
+	QUERY_LEADER_MESSAGE           = "queryLeader"
+	START_MISSION_MESSAGE          = "startMission"
+	APPROVE_TEAM_MESSAGE           = "approveTeam"
+	QUERY_IS_ON_MISSION_MESSAGE    = "queryIsOnMission"
+	MISSION_OUTCOME_MESSAGE        = "missionOutcome"
+	UPDATE_GAME_PROGRESS           = "updateGameProgress"
+	RESUME_MESSAGE                 = "resume"
+	QUERY_GAME_STATE_MESSAGE       = "queryGameState"
+	PONG_MESSAGE                   = "pong"
+	ADD_BOT_MESSAGE                = "addBot"
+	REMOVE_BOT_MESSAGE             = "removeBot"
+	SPECTATE_MESSAGE               = "spectate"
+	SEND_CHAT_MESSAGE              = "sendChat"
+	SET_SPY_CHAT_MESSAGE           = "setSpyChat"
+	SET_TIME_LIMITS_MESSAGE        = "setTimeLimits"
+	REPLACE_PLAYER_MESSAGE         = "replacePlayer"
+	ABANDON_GAME_MESSAGE           = "abandonGame"
+	KICK_PLAYER_MESSAGE            = "kickPlayer"
+	TRANSFER_HOST_MESSAGE          = "transferHost"
+	LOCK_LOBBY_MESSAGE             = "lockLobby"
+	SET_SEAT_ORDER_MESSAGE         = "setSeatOrder"
+	REGENERATE_INVITE_CODE_MESSAGE = "regenerateInviteCode"
+
+	// messages sent to the frontend
+	PLAYER_CONNECT_SUCCESSFUL_MESSAGE  = "playerConnectSuccessful"
+	PLAYERS_MESSAGE                    = "players"
+	GAME_STARTED_MESSAGE               = "gameStarted"
+	QUERY_ROLE_RESULT_MESSAGE          = "queryRoleResult"
+	QUERY_LEADER_RESULT_MESSAGE        = "queryLeaderResult"
+	MISSION_PREPARATION_MESSAGE        = "missionPreparation"
+	TEAM_APPROVAL_MESSAGE              = "teamApproval"
+	APPROVE_TEAM_UPDATE_MESSAGE        = "approveTeamUpdate"
+	MISSION_STARTED_MESSAGE            = "missionStarted"
+	QUERY_IS_ON_MISSION_RESULT_MESSAGE = "queryIsOnMissionResult"
+	GAME_OVER_MESSAGE                  = "gameOver"
+	MISSIONS_MESSAGE                   = "missions"
+	GAME_PAUSE_MESSAGE                 = "gamePause"
+	GAME_RESUME_MESSAGE                = "gameResume"
+	SHOW_TEXT_MESSAGE                  = "showText"
+	RESUME_RESULT_MESSAGE              = "resumeResult"
+	GAME_STATE_MESSAGE                 = "gameState"
+	PROTOCOL_ERROR_MESSAGE             = "protocolError"
+	PING_MESSAGE                       = "ping"
+	CHAT_MESSAGE                       = "chat"
+	TIMER_MESSAGE                      = "timer"
+	GRACE_PERIOD_OVER_MESSAGE          = "gracePeriodOver"
+	PLAYER_REPLACED_MESSAGE            = "playerReplaced"
+	GAME_ABANDONED_MESSAGE             = "gameAbandoned"
+	KICKED_MESSAGE                     = "kicked"
+)
+
+// IsSupportedVersion checks if the game server can talk to a game page
+// using the given protocol version.
+func IsSupportedVersion(version int) bool {
+	return version >= MIN_PROTOCOL_VERSION && version <= PROTOCOL_VERSION
+}
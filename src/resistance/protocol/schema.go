@@ -0,0 +1,152 @@
+package protocol
+
+const (
+	TO_SERVER = "toServer"
+	TO_CLIENT = "toClient"
+)
+
+const (
+	TYPE_STRING  = "string"
+	TYPE_NUMBER  = "number"
+	TYPE_BOOLEAN = "boolean"
+	TYPE_ARRAY   = "array"
+	TYPE_OBJECT  = "object"
+)
+
+// Field is one key of a message.
+type Field struct {
+	Key         string
+	Type        string
+	Description string
+}
+
+// Message describes one kind of message and which way it goes.
+type Message struct {
+	Name        string
+	Direction   string
+	Description string
+	Fields      []Field
+}
+
+// Every message from the frontend also has the message and gameId keys.
+// Every message published to a game also has seq and epoch keys, and any message
+// from the server can have an errorMessage key instead of its usual fields.
+// Spectators can only send spectate, getPlayers, queryGameState and resume, and
+// playerConnect to take a seat while the game is in the lobby.
+var Messages = []Message{
+	{PLAYER_CONNECT_MESSAGE, TO_SERVER, "First message once connected, joins the game.", []Field{
+		{PROTOCOL_VERSION_KEY, TYPE_NUMBER, "Protocol version the page speaks."}}},
+	{GET_PLAYERS_MESSAGE, TO_SERVER, "Asks for the players of the game.", nil},
+	{START_GAME_MESSAGE, TO_SERVER, "The host starts the game.", nil},
+	{QUERY_ROLE_MESSAGE, TO_SERVER, "Asks for the player's role.", nil},
+	{QUERY_LEADER_MESSAGE, TO_SERVER, "Asks if the player leads the current mission.", nil},
+	{START_MISSION_MESSAGE, TO_SERVER, "The leader picks the team.", []Field{
+		{TEAMS_KEY, TYPE_ARRAY, "User ids, as strings, of the team."}}},
+	{APPROVE_TEAM_MESSAGE, TO_SERVER, "Votes on the proposed team.", []Field{
+		{VOTE_KEY, TYPE_BOOLEAN, "Whether the player approves."}}},
+	{QUERY_IS_ON_MISSION_MESSAGE, TO_SERVER, "Asks if the player is on the current mission.", nil},
+	{MISSION_OUTCOME_MESSAGE, TO_SERVER, "Plays a card on the mission.", []Field{
+		{OUTCOME_KEY, TYPE_BOOLEAN, "True for success, false for fail."}}},
+	{UPDATE_GAME_PROGRESS, TO_SERVER, "Asks what the player should be doing after a reconnect.", nil},
+	{RESUME_MESSAGE, TO_SERVER, "Asks for the published messages missed while disconnected.", []Field{
+		{LAST_SEQ_KEY, TYPE_NUMBER, "Sequence number of the last message seen."},
+		{EPOCH_KEY, TYPE_STRING, "Epoch of the last message seen."}}},
+	{QUERY_GAME_STATE_MESSAGE, TO_SERVER, "Asks for the whole state of the game.", nil},
+	{PONG_MESSAGE, TO_SERVER, "Answers a ping.", nil},
+	{ADD_BOT_MESSAGE, TO_SERVER, "The host adds a bot to the lobby.", []Field{
+		{STRATEGY_KEY, TYPE_STRING, "Name of the strategy the bot plays with."}}},
+	{REMOVE_BOT_MESSAGE, TO_SERVER, "The host takes a bot out of the lobby.", []Field{
+		{USER_ID_KEY, TYPE_NUMBER, "User id of the bot."}}},
+	{SPECTATE_MESSAGE, TO_SERVER, "First message once connected, to watch the game instead of joining it.", []Field{
+		{PROTOCOL_VERSION_KEY, TYPE_NUMBER, "Protocol version the page speaks."}}},
+	{SEND_CHAT_MESSAGE, TO_SERVER, "Says something.", []Field{
+		{CHANNEL_KEY, TYPE_STRING, "all, or spies if the host allowed the spies a channel."},
+		{TEXT_KEY, TYPE_STRING, "What to say."}}},
+	{SET_SPY_CHAT_MESSAGE, TO_SERVER, "The host decides, in the lobby, whether the spies get a channel.", []Field{
+		{SPY_CHAT_KEY, TYPE_BOOLEAN, "Whether they do."}}},
+	{REPLACE_PLAYER_MESSAGE, TO_SERVER, "The host replaces a player who didn't come back in time.", []Field{
+		{USERNAME_KEY, TYPE_STRING, "Who to replace."},
+		{STRATEGY_KEY, TYPE_STRING, "Strategy of the bot to replace them with, or empty for a person."},
+		{REPLACEMENT_KEY, TYPE_STRING, "Username of the person to replace them with."}}},
+	{ABANDON_GAME_MESSAGE, TO_SERVER, "The host gives up on a game waiting on players who didn't come back in time.", nil},
+	{KICK_PLAYER_MESSAGE, TO_SERVER, "The host kicks a player out of the lobby.", []Field{
+		{USER_ID_KEY, TYPE_NUMBER, "User id of the player."}}},
+	{TRANSFER_HOST_MESSAGE, TO_SERVER, "The host hands the game over to another player in the lobby.", []Field{
+		{USER_ID_KEY, TYPE_NUMBER, "User id of the new host."}}},
+	{LOCK_LOBBY_MESSAGE, TO_SERVER, "The host locks the lobby, so nobody new can join, or unlocks it.", []Field{
+		{LOCKED_KEY, TYPE_BOOLEAN, "Whether the lobby is locked."}}},
+	{SET_SEAT_ORDER_MESSAGE, TO_SERVER, "The host changes where everyone sits, which is the order leaders take turns in.", []Field{
+		{PLAYERS_KEY, TYPE_ARRAY, "User ids of the players, in seat order."}}},
+	{REGENERATE_INVITE_CODE_MESSAGE, TO_SERVER, "The host of a private game gives it a new invite code, answered with the game state.", nil},
+	{SET_TIME_LIMITS_MESSAGE, TO_SERVER, "The host sets, in the lobby, how long players get for each move.", []Field{
+		{TIME_LIMITS_KEY, TYPE_OBJECT, "Seconds for picking a team, voting and playing a card, under team, vote and mission, and for players who left to come back, under disconnect. 0 for no limit."}}},
+
+	{PLAYER_CONNECT_SUCCESSFUL_MESSAGE, TO_CLIENT, "The player has joined, or is watching, the game.", []Field{
+		{GAME_ID_KEY, TYPE_NUMBER, "Id of the game."},
+		{ACCEPT_USER_KEY, TYPE_BOOLEAN, "Always true."},
+		{USER_ID_KEY, TYPE_NUMBER, "Id of the player."},
+		{IS_HOST_KEY, TYPE_BOOLEAN, "Whether the player is the host."},
+		{UPDATE_GAME_PROGRESS_KEY, TYPE_BOOLEAN, "Whether the game is already in progress."},
+		{GAME_STATE_KEY, TYPE_OBJECT, "The state of the game, see game.GameState."},
+		{PROTOCOL_VERSION_KEY, TYPE_NUMBER, "Protocol version the server speaks."},
+		{STRATEGIES_KEY, TYPE_ARRAY, "Strategies bots can play with, for whoever is the host."},
+		{SPECTATE_KEY, TYPE_BOOLEAN, "Whether this is a spectator rather than a player."}}},
+	{PLAYERS_MESSAGE, TO_CLIENT, "The players of the game changed.", []Field{
+		{PLAYERS_KEY, TYPE_ARRAY, "Usernames of the connected players, in seat order."},
+		{PLAYER_IDS_KEY, TYPE_ARRAY, "User ids of the same players."},
+		{HOST_ID_KEY, TYPE_NUMBER, "User id of the host."},
+		{LOCKED_KEY, TYPE_BOOLEAN, "Whether the host locked the lobby."},
+		{BOTS_KEY, TYPE_ARRAY, "The bots among them, with UserId and Username."},
+		{SPECTATORS_KEY, TYPE_ARRAY, "Usernames of the people watching."},
+		{GAME_ID_KEY, TYPE_NUMBER, "Id of the game."}}},
+	{GAME_STARTED_MESSAGE, TO_CLIENT, "The host started the game.", nil},
+	{QUERY_ROLE_RESULT_MESSAGE, TO_CLIENT, "The player's role.", []Field{
+		{ROLE_KEY, TYPE_STRING, "Name of the role."}}},
+	{QUERY_LEADER_RESULT_MESSAGE, TO_CLIENT, "The player leads the current mission.", []Field{
+		{IS_LEADER_KEY, TYPE_BOOLEAN, "Always true."},
+		{PLAYERS_KEY, TYPE_ARRAY, "Players to pick the team from, with UserId and Username."},
+		{TEAM_SIZE_KEY, TYPE_NUMBER, "How many players to pick."}}},
+	{MISSION_PREPARATION_MESSAGE, TO_CLIENT, "A new mission needs a team.", nil},
+	{TEAM_APPROVAL_MESSAGE, TO_CLIENT, "Everyone votes on the proposed team.", []Field{
+		{TEAMS_KEY, TYPE_ARRAY, "Usernames of the team."}}},
+	{APPROVE_TEAM_UPDATE_MESSAGE, TO_CLIENT, "Someone voted.", []Field{
+		{USERNAME_KEY, TYPE_STRING, "Who voted."},
+		{VOTE_KEY, TYPE_BOOLEAN, "How they voted."}}},
+	{MISSION_STARTED_MESSAGE, TO_CLIENT, "The team was approved and goes on the mission.", nil},
+	{QUERY_IS_ON_MISSION_RESULT_MESSAGE, TO_CLIENT, "The player is on the current mission.", []Field{
+		{IS_ON_MISSION_KEY, TYPE_BOOLEAN, "Always true."}}},
+	{GAME_OVER_MESSAGE, TO_CLIENT, "The game is over.", []Field{
+		{GAME_WINNER_KEY, TYPE_STRING, "Which side won."}}},
+	{MISSIONS_MESSAGE, TO_CLIENT, "The mission board changed.", []Field{
+		{MISSIONS_KEY, TYPE_ARRAY, "Every mission so far."},
+		{SPY_ODDS_KEY, TYPE_OBJECT, "Chance of each player being a spy, by username. Left out in ranked games."}}},
+	{GAME_PAUSE_MESSAGE, TO_CLIENT, "A player is missing, the game waits for them.", nil},
+	{GAME_RESUME_MESSAGE, TO_CLIENT, "Everyone is back.", nil},
+	{SHOW_TEXT_MESSAGE, TO_CLIENT, "Some text to show the player.", []Field{
+		{TEXT_KEY, TYPE_STRING, "The text."}}},
+	{RESUME_RESULT_MESSAGE, TO_CLIENT, "The published messages the player missed.", []Field{
+		{MESSAGES_KEY, TYPE_ARRAY, "The messages, oldest first."},
+		{LAST_SEQ_KEY, TYPE_NUMBER, "Sequence number of the latest message."},
+		{EPOCH_KEY, TYPE_STRING, "Epoch the sequence numbers are counted in."},
+		{COMPLETE_KEY, TYPE_BOOLEAN, "False if some of the messages are no longer kept, or were numbered in another epoch."}}},
+	{GAME_STATE_MESSAGE, TO_CLIENT, "The whole state of the game.", []Field{
+		{GAME_STATE_KEY, TYPE_OBJECT, "The state of the game, see game.GameState."}}},
+	{PROTOCOL_ERROR_MESSAGE, TO_CLIENT, "The page speaks a protocol version the server doesn't.", []Field{
+		{ERROR_MESSAGE_KEY, TYPE_STRING, "What to tell the player."},
+		{PROTOCOL_VERSION_KEY, TYPE_NUMBER, "Protocol version the server speaks."}}},
+	{PING_MESSAGE, TO_CLIENT, "Heartbeat from the websocket proxy, answer with a pong.", nil},
+	{CHAT_MESSAGE, TO_CLIENT, "Someone said something. The spies' channel only goes to the spies, and isn't numbered.", []Field{
+		{USERNAME_KEY, TYPE_STRING, "Who said it."},
+		{CHANNEL_KEY, TYPE_STRING, "all or spies."},
+		{TEXT_KEY, TYPE_STRING, "What they said."},
+		{TIME_KEY, TYPE_STRING, "When they said it."}}},
+	{TIMER_MESSAGE, TO_CLIENT, "The clock started, or stopped, on the move the game is waiting on. Whoever runs out of time has the move made for them.", []Field{
+		{TIME_LEFT_KEY, TYPE_NUMBER, "Seconds left, 0 if there is no clock."}}},
+	{GRACE_PERIOD_OVER_MESSAGE, TO_CLIENT, "The players the game is waiting on didn't come back in time, the host can replace them or abandon the game.", []Field{
+		{PLAYERS_KEY, TYPE_ARRAY, "Usernames of the players the game is waiting on."}}},
+	{PLAYER_REPLACED_MESSAGE, TO_CLIENT, "Someone took over the seat, and role, of a player who didn't come back.", []Field{
+		{USERNAME_KEY, TYPE_STRING, "Who was replaced."},
+		{REPLACEMENT_KEY, TYPE_STRING, "Who replaced them."}}},
+	{GAME_ABANDONED_MESSAGE, TO_CLIENT, "The host gave up on the game, nobody wins.", nil},
+	{KICKED_MESSAGE, TO_CLIENT, "The host kicked the player out of the lobby.", nil},
+}
@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"golang.org/x/net/websocket"
+	"net"
+	"net/http"
+	"resistance/protocol"
+	"resistance/pubsub"
+	"resistance/users"
+	"resistance/utils"
+	"sync"
+	"time"
+)
+
+const (
+	WEBSOCKET_PATH = "/ws"
+
+	// The frontend answers every ping with a pong
+	HEARTBEAT_INTERVAL = 10 * time.Second
+	// A connection that hasn't sent anything, not even a pong, for this
+	// long is considered gone.
+	READ_TIMEOUT     = 3 * HEARTBEAT_INTERVAL
+	WRITE_TIMEOUT    = 10 * time.Second
+	MAX_MESSAGE_SIZE = 64 * 1024
+)
+
+var (
+	errOriginNotAllowed = errors.New("Origin not allowed")
+)
+
+// Connection is a player's websocket connection. Messages to the player can
+// be sent from several go routines at once.
+type Connection struct {
+	lock       sync.Mutex
+	socket     *websocket.Conn
+	UserCookie string
+}
+
+// Send sends the given JSON message to the player as a text frame.
+func (connection *Connection) Send(message []byte) error {
+	connection.lock.Lock()
+	defer connection.lock.Unlock()
+
+	connection.socket.SetWriteDeadline(time.Now().Add(WRITE_TIMEOUT))
+	return websocket.Message.Send(connection.socket, string(message))
+}
+
+// Close closes the connection, which ends up cleaning up after it the same
+// way as if the player had gone away.
+func (connection *Connection) Close() error {
+	return connection.socket.Close()
+}
+
+// serveConnection reads the messages from a player's connection until it
+// goes away, then cleans up after it.
+func serveConnection(socket *websocket.Conn, subscriber pubsub.Subscriber) {
+	socket.MaxPayloadBytes = MAX_MESSAGE_SIZE
+	connection := &Connection{
+		socket:     socket,
+		UserCookie: getUserCookie(socket.Request())}
+
+	done := make(chan bool)
+	go sendHeartbeats(connection, done)
+
+	for {
+		socket.SetReadDeadline(time.Now().Add(READ_TIMEOUT))
+
+		var msg string
+		err := websocket.Message.Receive(socket, &msg)
+		if err != nil {
+			utils.LogMessage("Disconnect received: "+err.Error(), utils.RWSP_LOG_PATH)
+			break
+		}
+
+		if isPong([]byte(msg)) {
+			continue
+		}
+
+		utils.LogMessage("Received message with data:"+msg, utils.RWSP_LOG_PATH)
+		handleMessage([]byte(msg), connection, subscriber)
+	}
+
+	close(done)
+	handleDisconnect(connection)
+}
+
+// sendHeartbeats is meant to run in the background. Pings the player every
+// so often so that dead connections are noticed, until done is closed.
+func sendHeartbeats(connection *Connection, done chan bool) {
+	pingMessage, _ := json.Marshal(map[string]interface{}{protocol.MESSAGE_KEY: protocol.PING_MESSAGE})
+
+	ticker := time.NewTicker(HEARTBEAT_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			err := connection.Send(pingMessage)
+			if err != nil {
+				utils.LogMessage("Error sending ping: "+err.Error(), utils.RWSP_LOG_PATH)
+			}
+		}
+	}
+}
+
+// isPong checks if the given message is the frontend answering a ping.
+func isPong(msg []byte) bool {
+	var parsedMessage map[string]interface{}
+	err := json.Unmarshal(msg, &parsedMessage)
+	return err == nil && parsedMessage[protocol.MESSAGE_KEY] == protocol.PONG_MESSAGE
+}
+
+// checkOrigin only lets pages served by our own HTTP server, or from the
+// allowed origins, open a websocket. Otherwise any site a player visits
+// could act as them in their games.
+func checkOrigin(config *websocket.Config, request *http.Request) error {
+	origin, err := websocket.Origin(config, request)
+	if err != nil {
+		return err
+	}
+	if origin == nil {
+		// Browsers always send an origin. Bots and scripts don't, and can
+		// connect as long as they use an API token, which a page on some
+		// other site can't send for them.
+		if users.GetBearerToken(request) != "" {
+			return nil
+		}
+		return errOriginNotAllowed
+	}
+	config.Origin = origin
+
+	for _, allowedOrigin := range utils.ALLOWED_ORIGINS {
+		if origin.Scheme+"://"+origin.Host == allowedOrigin {
+			return nil
+		}
+	}
+
+	requestHost, _, err := net.SplitHostPort(request.Host)
+	if err != nil {
+		requestHost = request.Host
+	}
+	originHost, originPort, err := net.SplitHostPort(origin.Host)
+	if err != nil {
+		// No port, so the default one for the scheme
+		originHost = origin.Host
+		originPort = ""
+	}
+	if originHost == requestHost && (originPort == "" || originPort == utils.HTTP_PORT) {
+		return nil
+	}
+
+	utils.LogMessage("Rejected websocket from origin "+origin.String(), utils.RWSP_LOG_PATH)
+	return errOriginNotAllowed
+}
+
+// getUserCookie gets the session cookie, or the API token of a bot, sent
+// along with the websocket handshake, in the form the game backend expects it.
+func getUserCookie(request *http.Request) string {
+	return users.GetCredential(request)
+}
@@ -0,0 +1,158 @@
+package proxy
+
+import (
+	"encoding/json"
+	"golang.org/x/net/websocket"
+	"net/http"
+	"resistance/protocol"
+	"resistance/pubsub"
+	"resistance/rpc"
+	"resistance/utils"
+	"strconv"
+)
+
+type UserInformation struct {
+	Subscriptions []pubsub.Subscription
+	Cookie        string
+	GameId        string
+	Spectate      bool
+}
+
+var (
+	registry *Registry = NewRegistry()
+	backend  *rpc.Client
+)
+
+// subscribeConnection is meant to run in the background. Waits for a message
+// published to the player's game and forwards it to the frontend. Stops once
+// the subscription is closed because the player disconnected. If the player
+// couldn't keep up with the game instead, they are disconnected so they can
+// reconnect and catch up.
+func subscribeConnection(connection *Connection, subscription pubsub.Subscription) {
+	for message := range subscription.Messages() {
+		connection.Send(message)
+	}
+
+	err := subscription.Err()
+	if err != nil {
+		utils.LogMessage("Subscription closed: "+err.Error(), utils.RWSP_LOG_PATH)
+		connection.Close()
+		return
+	}
+	utils.LogMessage("Subscription closed.", utils.RWSP_LOG_PATH)
+}
+
+// getErrorMessage builds up the message to tell the frontend something went
+// wrong talking to the game backend.
+func getErrorMessage(err error) []byte {
+	errorMessage := make(map[string]interface{})
+	errorMessage[protocol.ERROR_MESSAGE_KEY] = err.Error()
+	message, _ := json.Marshal(errorMessage)
+	return message
+}
+
+// handleMessage handles a message from the frontend. Basically forwards it
+// to the game backend, waits for a reply, then forwards the
+// reply to the frontend. If this was a player connect message, and the
+// user is accepted, we should start a listener to the SUBSCRIBE socket.
+func handleMessage(msg []byte, connection *Connection, subscriber pubsub.Subscriber) {
+	reply, err := backend.SendClientMessage(&rpc.ClientMessageRequest{
+		UserCookie: connection.UserCookie,
+		Payload:    json.RawMessage(msg)})
+	if err != nil {
+		utils.LogMessage("Error sending message to game backend: "+err.Error(), utils.RWSP_LOG_PATH)
+		connection.Send(getErrorMessage(err))
+		return
+	}
+
+	if reply.AcceptUser {
+		utils.LogMessage("User accepted for game "+strconv.Itoa(reply.GameId), utils.RWSP_LOG_PATH)
+
+		gameId := strconv.Itoa(reply.GameId)
+
+		// Subscribe to the messages for the appropriate game id and the
+		// player's own, or just the public ones for a spectator
+		subscriptions := make([]pubsub.Subscription, 0)
+		for _, topic := range pubsub.GetTopics(gameId, reply.UserId, reply.Spectate) {
+			subscription, err := subscriber.Subscribe(topic)
+			if err != nil {
+				utils.LogMessage("Error subscribing to "+topic+": "+err.Error(), utils.RWSP_LOG_PATH)
+				for _, subscription := range subscriptions {
+					subscription.Close()
+				}
+				connection.Send(getErrorMessage(err))
+				return
+			}
+			subscriptions = append(subscriptions, subscription)
+		}
+
+		// Keep in memory all the necessary information about the connection
+		previous := registry.Add(connection, &UserInformation{
+			Subscriptions: subscriptions,
+			Cookie:        connection.UserCookie,
+			GameId:        gameId,
+			Spectate:      reply.Spectate})
+
+		// A player connecting again on the same connection is done with
+		// whatever they were connected to before
+		if previous != nil {
+			cleanUpUserInformation(previous)
+		}
+
+		for _, subscription := range subscriptions {
+			go subscribeConnection(connection, subscription)
+		}
+	}
+
+	connection.Send([]byte(reply.Payload))
+	utils.LogMessage("Sent to frontend", utils.RWSP_LOG_PATH)
+}
+
+// handleDisconnect handles the case where a connection is disconnecetd.
+// We should clean up our state to reflect that
+func handleDisconnect(connection *Connection) {
+	userInfo := registry.Remove(connection)
+	if userInfo != nil {
+		cleanUpUserInformation(userInfo)
+	}
+
+	utils.LogMessage("Finished deleting connection from WSP", utils.RWSP_LOG_PATH)
+}
+
+// cleanUpUserInformation stops sending the game's messages to a connection
+// and lets the game backend know the player is gone.
+func cleanUpUserInformation(userInfo *UserInformation) {
+	// Closing the subscriptions also stops the subscribeConnection
+	// go routines.
+	for _, subscription := range userInfo.Subscriptions {
+		subscription.Close()
+		utils.LogMessage("Removing subscription from state", utils.RWSP_LOG_PATH)
+	}
+
+	if userInfo.Cookie != "" {
+		_, err := backend.PlayerDisconnect(&rpc.PlayerDisconnectRequest{
+			GameId:     userInfo.GameId,
+			Spectate:   userInfo.Spectate,
+			UserCookie: userInfo.Cookie})
+		if err != nil {
+			utils.LogMessage("Could not send message to game backend:"+err.Error(), utils.RWSP_LOG_PATH)
+		}
+	}
+}
+
+// ListenAndServe serves the websocket proxy on the given address. Messages
+// from the frontend are passed to the game server through the given client,
+// and players are sent the messages published to their game.
+func ListenAndServe(address string, client *rpc.Client, subscriber pubsub.Subscriber) error {
+	backend = client
+
+	mux := http.NewServeMux()
+	mux.Handle(WEBSOCKET_PATH, websocket.Server{
+		Handshake: checkOrigin,
+		Handler: func(socket *websocket.Conn) {
+			serveConnection(socket, subscriber)
+		}})
+
+	// Start server
+	return http.ListenAndServe(address, mux)
+}
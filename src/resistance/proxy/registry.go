@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"sync"
+)
+
+// Registry keeps the information about every connection that has joined a
+// game. Connections come and go on their own go routines, so everything
+// goes through the lock.
+type Registry struct {
+	lock      sync.Mutex
+	userInfos map[*Connection]*UserInformation
+}
+
+func NewRegistry() *Registry {
+	return &Registry{userInfos: make(map[*Connection]*UserInformation)}
+}
+
+// Add stores the information for the connection, returning what was stored
+// for it before, if anything, so the caller can clean that up.
+func (registry *Registry) Add(connection *Connection, userInfo *UserInformation) *UserInformation {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	previous := registry.userInfos[connection]
+	registry.userInfos[connection] = userInfo
+	return previous
+}
+
+// Remove forgets the connection, returning what was stored for it. Only
+// one caller ever gets the information back, so it is only cleaned up once.
+func (registry *Registry) Remove(connection *Connection) *UserInformation {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	userInfo := registry.userInfos[connection]
+	delete(registry.userInfos, connection)
+	return userInfo
+}
@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+const (
+	TEST_CONNECTIONS = 300
+	TEST_RECONNECTS  = 20
+)
+
+// TestRegistryConcurrentConnections has every connection reconnect over and
+// over while disconnecting, the way handleMessage and handleDisconnect race
+// each other, and checks every stored UserInformation is handed back to be
+// cleaned up exactly once.
+func TestRegistryConcurrentConnections(t *testing.T) {
+	registry := NewRegistry()
+	connections := make([]*Connection, TEST_CONNECTIONS)
+	for index := range connections {
+		connections[index] = &Connection{UserCookie: "RC=" + strconv.Itoa(index)}
+	}
+
+	var lock sync.Mutex
+	handedBack := make(map[*UserInformation]int)
+	handBack := func(userInfo *UserInformation) {
+		if userInfo == nil {
+			return
+		}
+		lock.Lock()
+		handedBack[userInfo]++
+		lock.Unlock()
+	}
+
+	var wait sync.WaitGroup
+	for _, connection := range connections {
+		wait.Add(2)
+		go func(connection *Connection) {
+			defer wait.Done()
+			for reconnect := 0; reconnect < TEST_RECONNECTS; reconnect++ {
+				handBack(registry.Add(connection, &UserInformation{
+					Cookie: connection.UserCookie,
+					GameId: strconv.Itoa(reconnect)}))
+			}
+		}(connection)
+		go func(connection *Connection) {
+			defer wait.Done()
+			for remove := 0; remove < TEST_RECONNECTS; remove++ {
+				handBack(registry.Remove(connection))
+			}
+		}(connection)
+	}
+	wait.Wait()
+
+	// Whatever is still connected gets cleaned up on disconnect
+	for _, connection := range connections {
+		handBack(registry.Remove(connection))
+	}
+
+	if len(handedBack) != TEST_CONNECTIONS*TEST_RECONNECTS {
+		t.Errorf("Expected %d connection infos to be handed back, got %d", TEST_CONNECTIONS*TEST_RECONNECTS, len(handedBack))
+	}
+	for userInfo, times := range handedBack {
+		if times != 1 {
+			t.Errorf("Info for game %s of %s was handed back %d times", userInfo.GameId, userInfo.Cookie, times)
+		}
+	}
+	if len(registry.userInfos) != 0 {
+		t.Errorf("Expected the registry to be empty, it has %d connections", len(registry.userInfos))
+	}
+}
+
+// TestRegistryConcurrentDisconnects has the same connection disconnect from
+// many go routines at once, and checks only one of them gets to clean up.
+func TestRegistryConcurrentDisconnects(t *testing.T) {
+	registry := NewRegistry()
+	connection := &Connection{UserCookie: "RC=0"}
+	userInfo := &UserInformation{Cookie: connection.UserCookie, GameId: "1"}
+	if previous := registry.Add(connection, userInfo); previous != nil {
+		t.Fatalf("Expected nothing stored for a new connection, got game %s", previous.GameId)
+	}
+
+	var lock sync.Mutex
+	cleanUps := 0
+	var wait sync.WaitGroup
+	for index := 0; index < TEST_CONNECTIONS; index++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			if registry.Remove(connection) == userInfo {
+				lock.Lock()
+				cleanUps++
+				lock.Unlock()
+			}
+		}()
+	}
+	wait.Wait()
+
+	if cleanUps != 1 {
+		t.Errorf("Expected the connection to be cleaned up once, it was cleaned up %d times", cleanUps)
+	}
+}
+
+// TestRegistryReconnect checks a connection joining again gets back what it
+// was connected to before, so handleMessage can clean that up.
+func TestRegistryReconnect(t *testing.T) {
+	registry := NewRegistry()
+	connection := &Connection{UserCookie: "RC=0"}
+	first := &UserInformation{Cookie: connection.UserCookie, GameId: "1"}
+	second := &UserInformation{Cookie: connection.UserCookie, GameId: "2"}
+
+	registry.Add(connection, first)
+	if previous := registry.Add(connection, second); previous != first {
+		t.Errorf("Expected the first game's info back on reconnect")
+	}
+	if removed := registry.Remove(connection); removed != second {
+		t.Errorf("Expected the second game's info back on disconnect")
+	}
+	if removed := registry.Remove(connection); removed != nil {
+		t.Errorf("Expected nothing back once disconnected, got game %s", removed.GameId)
+	}
+}
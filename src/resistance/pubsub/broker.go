@@ -0,0 +1,102 @@
+package pubsub
+
+import (
+	"resistance/utils"
+	"sync"
+)
+
+const (
+	// How many messages a subscription to the broker can fall behind. Its
+	// subscriber is the proxy's fanout, which hands each message on to the
+	// connections' own queues straight away, and cuts off any connection
+	// that is too slow. A burst of messages at the start of a game or from
+	// the bots must never fill this up, as closing it cuts off everyone
+	// watching the game at once.
+	BROKER_BUFFER_SIZE = 1024
+)
+
+// Broker passes published messages straight to the subscriptions in the
+// same process. Used when everything runs as a single process.
+type Broker struct {
+	lock          sync.Mutex
+	subscriptions map[string]map[*brokerSubscription]bool
+}
+
+type brokerSubscription struct {
+	broker   *Broker
+	topic    string
+	messages chan []byte
+	err      error
+	closed   bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscriptions: make(map[string]map[*brokerSubscription]bool)}
+}
+
+// Publish hands the message to every subscription to the topic. A
+// subscription that is stuck so far behind that its buffer is full is closed
+// with ErrSlowConsumer rather than holding up the game server or silently
+// missing the message.
+func (broker *Broker) Publish(topic string, message []byte) error {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	for subscription := range broker.subscriptions[topic] {
+		select {
+		case subscription.messages <- message:
+		default:
+			utils.LogMessage("Subscription to "+topic+" is too slow, closing it", utils.RESISTANCE_LOG_PATH)
+			subscription.err = ErrSlowConsumer
+			broker.remove(subscription)
+		}
+	}
+	return nil
+}
+
+func (broker *Broker) Subscribe(topic string) (Subscription, error) {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	subscription := &brokerSubscription{
+		broker:   broker,
+		topic:    topic,
+		messages: make(chan []byte, BROKER_BUFFER_SIZE)}
+	if broker.subscriptions[topic] == nil {
+		broker.subscriptions[topic] = make(map[*brokerSubscription]bool)
+	}
+	broker.subscriptions[topic][subscription] = true
+	return subscription, nil
+}
+
+// remove closes the subscription. Must be called with the lock held.
+func (broker *Broker) remove(subscription *brokerSubscription) {
+	if subscription.closed {
+		return
+	}
+	subscription.closed = true
+
+	delete(broker.subscriptions[subscription.topic], subscription)
+	if len(broker.subscriptions[subscription.topic]) == 0 {
+		delete(broker.subscriptions, subscription.topic)
+	}
+	close(subscription.messages)
+}
+
+func (subscription *brokerSubscription) Messages() <-chan []byte {
+	return subscription.messages
+}
+
+func (subscription *brokerSubscription) Close() {
+	subscription.broker.lock.Lock()
+	defer subscription.broker.lock.Unlock()
+
+	subscription.broker.remove(subscription)
+}
+
+func (subscription *brokerSubscription) Err() error {
+	subscription.broker.lock.Lock()
+	defer subscription.broker.lock.Unlock()
+
+	return subscription.err
+}
@@ -0,0 +1,138 @@
+package pubsub
+
+import (
+	"resistance/utils"
+	"sync"
+)
+
+const (
+	// How many messages a subscriber of a fanout can fall behind before it
+	// is cut off.
+	FANOUT_QUEUE_SIZE = 64
+)
+
+// Fanout shares one upstream subscription per topic between everyone
+// subscribed to it in this process. Each subscriber gets its own bounded
+// queue. A subscriber whose queue fills up is closed with ErrSlowConsumer
+// so that it doesn't hold up, or silently miss messages meant for, the
+// rest of the game.
+type Fanout struct {
+	lock     sync.Mutex
+	upstream Subscriber
+	topics   map[string]*fanoutTopic
+}
+
+type fanoutTopic struct {
+	name         string
+	subscription Subscription
+	subscribers  map[*fanoutSubscription]bool
+}
+
+type fanoutSubscription struct {
+	fanout   *Fanout
+	topic    *fanoutTopic
+	messages chan []byte
+	err      error
+	closed   bool
+}
+
+func NewFanout(upstream Subscriber) *Fanout {
+	return &Fanout{upstream: upstream, topics: make(map[string]*fanoutTopic)}
+}
+
+// Subscribe subscribes upstream to the topic if no one in this process is
+// subscribed to it yet.
+func (fanout *Fanout) Subscribe(topic string) (Subscription, error) {
+	fanout.lock.Lock()
+	defer fanout.lock.Unlock()
+
+	shared := fanout.topics[topic]
+	if shared == nil {
+		upstreamSubscription, err := fanout.upstream.Subscribe(topic)
+		if err != nil {
+			return nil, err
+		}
+		shared = &fanoutTopic{
+			name:         topic,
+			subscription: upstreamSubscription,
+			subscribers:  make(map[*fanoutSubscription]bool)}
+		fanout.topics[topic] = shared
+		go fanout.dispatch(shared)
+	}
+
+	subscription := &fanoutSubscription{
+		fanout:   fanout,
+		topic:    shared,
+		messages: make(chan []byte, FANOUT_QUEUE_SIZE)}
+	shared.subscribers[subscription] = true
+	return subscription, nil
+}
+
+// dispatch is meant to run in the background. Hands every message of the
+// upstream subscription to the subscribers of the topic, until the upstream
+// subscription is closed.
+func (fanout *Fanout) dispatch(shared *fanoutTopic) {
+	for message := range shared.subscription.Messages() {
+		fanout.lock.Lock()
+		for subscription := range shared.subscribers {
+			select {
+			case subscription.messages <- message:
+			default:
+				utils.LogMessage("Subscriber to "+shared.name+" is too slow, closing it", utils.RESISTANCE_LOG_PATH)
+				subscription.err = ErrSlowConsumer
+				fanout.remove(subscription)
+			}
+		}
+		fanout.lock.Unlock()
+	}
+
+	// Upstream went away by itself, so there is nothing more coming for
+	// anyone subscribed to the topic
+	fanout.lock.Lock()
+	defer fanout.lock.Unlock()
+	err := shared.subscription.Err()
+	if err == nil {
+		err = ErrUpstreamClosed
+	}
+	for subscription := range shared.subscribers {
+		subscription.err = err
+		fanout.remove(subscription)
+	}
+}
+
+// remove closes the subscription, and the upstream subscription once no
+// one is left. Must be called with the lock held.
+func (fanout *Fanout) remove(subscription *fanoutSubscription) {
+	if subscription.closed {
+		return
+	}
+	subscription.closed = true
+	close(subscription.messages)
+
+	shared := subscription.topic
+	delete(shared.subscribers, subscription)
+	if len(shared.subscribers) == 0 {
+		if fanout.topics[shared.name] == shared {
+			delete(fanout.topics, shared.name)
+		}
+		shared.subscription.Close()
+	}
+}
+
+func (subscription *fanoutSubscription) Messages() <-chan []byte {
+	return subscription.messages
+}
+
+func (subscription *fanoutSubscription) Close() {
+	subscription.fanout.lock.Lock()
+	defer subscription.fanout.lock.Unlock()
+
+	subscription.fanout.remove(subscription)
+}
+
+func (subscription *fanoutSubscription) Err() error {
+	subscription.fanout.lock.Lock()
+	defer subscription.fanout.lock.Unlock()
+
+	return subscription.err
+}
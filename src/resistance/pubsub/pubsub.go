@@ -0,0 +1,65 @@
+package pubsub
+
+import (
+	"errors"
+	"strconv"
+)
+
+const (
+	DEFAULT_BUFFER_SIZE = 5
+
+	// Spectators of a game subscribe to the game id with this on the end
+	SPECTATOR_TOPIC_SUFFIX = ":spectate"
+	// Each player of a game also subscribes to the game id with this and
+	// their user id on the end, for what only they should see
+	PLAYER_TOPIC_INFIX = ":player:"
+)
+
+var (
+	ErrSlowConsumer   = errors.New("Subscription fell too far behind")
+	ErrUpstreamClosed = errors.New("Subscription closed upstream")
+)
+
+// Publisher is used by the game server to send a message to everyone
+// subscribed to a topic. The topic is the game id for the players of a game,
+// its spectator topic for the people watching it, and a player topic for
+// each player.
+type Publisher interface {
+	Publish(topic string, message []byte) error
+}
+
+// Subscriber is used by the websocket proxy to receive the messages
+// published to a topic.
+type Subscriber interface {
+	Subscribe(topic string) (Subscription, error)
+}
+
+// Subscription delivers the messages published to one topic until it is
+// closed. The messages channel is closed once the subscription is closed.
+type Subscription interface {
+	Messages() <-chan []byte
+	Close()
+	// Err is why the subscription was closed, if it wasn't closed by
+	// calling Close.
+	Err() error
+}
+
+// SpectatorTopic gets the topic the spectators of the given game subscribe
+// to. Only what anyone is allowed to see is published there.
+func SpectatorTopic(gameId string) string {
+	return gameId + SPECTATOR_TOPIC_SUFFIX
+}
+
+// PlayerTopic gets the topic only the given player of the given game
+// subscribes to.
+func PlayerTopic(gameId string, userId int) string {
+	return gameId + PLAYER_TOPIC_INFIX + strconv.Itoa(userId)
+}
+
+// GetTopics gets the topics a connection to the given game subscribes to.
+func GetTopics(gameId string, userId int, spectate bool) []string {
+	if spectate {
+		return []string{SpectatorTopic(gameId)}
+	}
+	return []string{gameId, PlayerTopic(gameId, userId)}
+}
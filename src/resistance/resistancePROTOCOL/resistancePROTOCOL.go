@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"resistance/protocol"
+	"strconv"
+	"unicode"
+)
+
+const (
+	OUTPUT_PATH = "src/resistance/frontend/protocol.js"
+)
+
+// Generates frontend/protocol.js from the protocol package, so the game
+// page and the game server agree on the names of the messages and keys.
+// Run from the same directory as the servers.
+func main() {
+	outputPath := OUTPUT_PATH
+	if len(os.Args) > 1 {
+		outputPath = os.Args[1]
+	}
+
+	err := ioutil.WriteFile(outputPath, generate(), 0644)
+	if err != nil {
+		log.Fatal("Error writing "+outputPath+": ", err)
+	}
+}
+
+// generate builds up the javascript version of the protocol.
+func generate() []byte {
+	var output bytes.Buffer
+
+	output.WriteString("// Generated by resistancePROTOCOL from src/resistance/protocol. Do not edit.\n\n")
+	output.WriteString("var PROTOCOL_VERSION = " + strconv.Itoa(protocol.PROTOCOL_VERSION) + ";\n\n")
+
+	keys := []string{protocol.MESSAGE_KEY, protocol.GAME_ID_KEY, protocol.SEQ_KEY, protocol.EPOCH_KEY, protocol.ERROR_MESSAGE_KEY}
+	seenKeys := make(map[string]bool)
+	for _, key := range keys {
+		seenKeys[key] = true
+	}
+
+	output.WriteString("var Messages = {\n")
+	for index, message := range protocol.Messages {
+		fmt.Fprintf(&output, "  // %s (%s)\n", message.Description, message.Direction)
+		for _, field := range message.Fields {
+			fmt.Fprintf(&output, "  //   %s: %s - %s\n", field.Key, field.Type, field.Description)
+			if !seenKeys[field.Key] {
+				seenKeys[field.Key] = true
+				keys = append(keys, field.Key)
+			}
+		}
+		fmt.Fprintf(&output, "  %s: %s", toConstantName(message.Name), strconv.Quote(message.Name))
+		if index < len(protocol.Messages)-1 {
+			output.WriteString(",")
+		}
+		output.WriteString("\n")
+	}
+	output.WriteString("};\n\n")
+
+	output.WriteString("var Keys = {\n")
+	for index, key := range keys {
+		fmt.Fprintf(&output, "  %s: %s", toConstantName(key), strconv.Quote(key))
+		if index < len(keys)-1 {
+			output.WriteString(",")
+		}
+		output.WriteString("\n")
+	}
+	output.WriteString("};\n")
+
+	return output.Bytes()
+}
+
+// toConstantName turns a camel case name like playerConnect into
+// PLAYER_CONNECT.
+func toConstantName(name string) string {
+	var constantName bytes.Buffer
+	for index, character := range name {
+		if unicode.IsUpper(character) && index > 0 {
+			constantName.WriteRune('_')
+		}
+		constantName.WriteRune(unicode.ToUpper(character))
+	}
+	return constantName.String()
+}
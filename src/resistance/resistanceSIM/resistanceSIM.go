@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"resistance/game"
+	"resistance/persist"
+	"resistance/simulator"
+	"strconv"
+	"strings"
+)
+
+// Plays games between bots and reports who wins how often. Seats are given
+// strategies at random from the ones asked for, for example
+//
+//	resistanceSIM -games 5000 -players 5-7 -strategies Heuristic,Random
+func main() {
+	numGames := flag.Int("games", 1000, "Games to play for each number of players")
+	playerCounts := flag.String("players", "5-10", "Numbers of players to play with, like 5,7 or 5-10")
+	strategyList := flag.String("strategies", strings.Join(game.GetStrategyNames(), ","), "Strategies to give the seats, at random")
+	flag.Parse()
+
+	numPlayersList, err := parsePlayerCounts(*playerCounts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	strategyNames := strings.Split(*strategyList, ",")
+	for _, strategyName := range strategyNames {
+		if game.NewStrategy(strategyName) == nil {
+			log.Fatal("Unknown strategy " + strategyName + ", there are: " + strings.Join(game.GetStrategyNames(), ","))
+		}
+	}
+
+	persister := persist.NewMemoryPersister()
+	report := simulator.NewReport()
+	for _, numPlayers := range numPlayersList {
+		for i := 0; i < *numGames; i++ {
+			seats := make([]string, numPlayers)
+			for seat := range seats {
+				seats[seat] = strategyNames[rand.Intn(len(strategyNames))]
+			}
+
+			result, err := simulator.PlayGame(persister, seats)
+			if err != nil {
+				log.Fatal(err)
+			}
+			report.Add(result)
+		}
+	}
+
+	report.Write(os.Stdout)
+}
+
+// parsePlayerCounts parses a list of numbers of players, like 5,7 or 5-10.
+func parsePlayerCounts(list string) ([]int, error) {
+	numPlayersList := make([]int, 0)
+	for _, part := range strings.Split(list, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		low, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("Not a number of players: %s", part)
+		}
+		high := low
+		if len(bounds) == 2 {
+			high, err = strconv.Atoi(bounds[1])
+			if err != nil {
+				return nil, fmt.Errorf("Not a number of players: %s", part)
+			}
+		}
+		if low < 5 || high > 10 || low > high {
+			return nil, fmt.Errorf("Games have 5 to 10 players, not %s", part)
+		}
+		for numPlayers := low; numPlayers <= high; numPlayers++ {
+			numPlayersList = append(numPlayersList, numPlayers)
+		}
+	}
+	return numPlayersList, nil
+}
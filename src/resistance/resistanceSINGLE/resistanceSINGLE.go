@@ -0,0 +1,33 @@
+package main
+
+import (
+	"log"
+	"resistance/gameserver"
+	"resistance/proxy"
+	"resistance/pubsub"
+	"resistance/rpc"
+	"resistance/utils"
+	"resistance/webserver"
+)
+
+// Runs the HTTP server, the websocket proxy and the game server in one
+// process. They talk to each other over channels instead of ZMQ, so
+// there is nothing else to set up apart from the database.
+func main() {
+	broker := pubsub.NewBroker()
+	server := gameserver.NewServer(broker)
+	backend := rpc.NewClient(rpc.NewChannelTransport(server))
+	subscriber := pubsub.NewFanout(broker)
+
+	utils.LogMessage("Starting TheResistance in a single process...", utils.RESISTANCE_LOG_PATH)
+
+	go func() {
+		if err := proxy.ListenAndServe(":"+utils.WSP_PORT, backend, subscriber); err != nil {
+			log.Fatal("ListenAndServe:", err)
+		}
+	}()
+
+	if err := webserver.ListenAndServe(":"+utils.HTTP_PORT, backend, subscriber); err != nil {
+		log.Fatal("ListenAndServe:", err)
+	}
+}
@@ -0,0 +1,31 @@
+package rpc
+
+import (
+	"time"
+)
+
+// ChannelTransport carries requests to a server in the same process. The
+// requests are dispatched the same way as those coming in over ZMQ.
+type ChannelTransport struct {
+	dispatcher *Dispatcher
+}
+
+func NewChannelTransport(server *Server) *ChannelTransport {
+	return &ChannelTransport{dispatcher: NewDispatcher(server)}
+}
+
+func (transport *ChannelTransport) RoundTrip(request []byte, timeout time.Duration) ([]byte, error) {
+	// The reply channel is buffered so a caller that timed out doesn't
+	// block the server.
+	replyChannel := make(chan []byte, 1)
+	transport.dispatcher.Dispatch(request, func(reply []byte) {
+		replyChannel <- reply
+	})
+
+	select {
+	case reply := <-replyChannel:
+		return reply, nil
+	case <-time.After(timeout):
+		return nil, ErrTimeout
+	}
+}
@@ -0,0 +1,104 @@
+package rpc
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	DEFAULT_TIMEOUT = 5 * time.Second
+)
+
+// Transport carries requests to the game server and brings the replies back.
+type Transport interface {
+	// RoundTrip sends the request and waits for the reply, giving up with
+	// ErrTimeout once the timeout has passed.
+	RoundTrip(request []byte, timeout time.Duration) ([]byte, error)
+}
+
+// Client is used by the HTTP server and the websocket proxy to call the
+// game server.
+type Client struct {
+	transport Transport
+	Timeout   time.Duration
+}
+
+func NewClient(transport Transport) *Client {
+	return &Client{transport: transport, Timeout: DEFAULT_TIMEOUT}
+}
+
+// Call calls the given method on the game server with the given request
+// and fills in the given reply.
+func (client *Client) Call(method string, request interface{}, reply interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	rawRequest, err := json.Marshal(&Request{Method: method, Body: body})
+	if err != nil {
+		return err
+	}
+
+	rawReply, err := client.transport.RoundTrip(rawRequest, client.Timeout)
+	if err != nil {
+		return err
+	}
+
+	var replyEnvelope Reply
+	err = json.Unmarshal(rawReply, &replyEnvelope)
+	if err != nil {
+		return ErrBadReply
+	}
+	if replyEnvelope.Error != "" {
+		return &Error{Message: replyEnvelope.Error}
+	}
+
+	err = json.Unmarshal(replyEnvelope.Body, reply)
+	if err != nil {
+		return ErrBadReply
+	}
+	return nil
+}
+
+func (client *Client) CreateGame(request *CreateGameRequest) (*CreateGameReply, error) {
+	reply := new(CreateGameReply)
+	err := client.Call(CREATE_GAME_METHOD, request, reply)
+	return reply, err
+}
+
+func (client *Client) IsValidGame(request *IsValidGameRequest) (*IsValidGameReply, error) {
+	reply := new(IsValidGameReply)
+	err := client.Call(IS_VALID_GAME_METHOD, request, reply)
+	return reply, err
+}
+
+func (client *Client) GetAllGames(request *GetAllGamesRequest) (*GetAllGamesReply, error) {
+	reply := new(GetAllGamesReply)
+	err := client.Call(GET_ALL_GAMES_METHOD, request, reply)
+	return reply, err
+}
+
+func (client *Client) SendClientMessage(request *ClientMessageRequest) (*ClientMessageReply, error) {
+	reply := new(ClientMessageReply)
+	err := client.Call(CLIENT_MESSAGE_METHOD, request, reply)
+	return reply, err
+}
+
+func (client *Client) PlayerDisconnect(request *PlayerDisconnectRequest) (*PlayerDisconnectReply, error) {
+	reply := new(PlayerDisconnectReply)
+	err := client.Call(PLAYER_DISCONNECT_METHOD, request, reply)
+	return reply, err
+}
+
+func (client *Client) GetGame(request *GetGameRequest) (*GetGameReply, error) {
+	reply := new(GetGameReply)
+	err := client.Call(GET_GAME_METHOD, request, reply)
+	return reply, err
+}
+
+func (client *Client) GetUserStats(request *GetUserStatsRequest) (*GetUserStatsReply, error) {
+	reply := new(GetUserStatsReply)
+	err := client.Call(GET_USER_STATS_METHOD, request, reply)
+	return reply, err
+}
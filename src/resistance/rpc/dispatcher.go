@@ -0,0 +1,76 @@
+package rpc
+
+import (
+	"sync"
+)
+
+// Dispatcher serves the requests to a server concurrently, except that
+// requests with the same key (the same game) are served one at a time, in
+// the order they came in. A slow request for one game doesn't hold up any
+// other game.
+type Dispatcher struct {
+	server  *Server
+	lock    sync.Mutex
+	workers map[string]*worker
+}
+
+// worker serves the queued requests for one key. It only lives for as long
+// as there is something in its queue.
+type worker struct {
+	queue []*job
+}
+
+type job struct {
+	request []byte
+	reply   func([]byte)
+}
+
+// NewDispatcher builds the dispatcher serving the given server. It is also
+// where the server posts its own requests to, so a server should only be
+// served by one dispatcher.
+func NewDispatcher(server *Server) *Dispatcher {
+	dispatcher := &Dispatcher{server: server, workers: make(map[string]*worker)}
+	server.dispatcher = dispatcher
+	return dispatcher
+}
+
+// Dispatch serves the request in the background, calling reply with the
+// raw reply once it has been served. Never waits on the server.
+func (dispatcher *Dispatcher) Dispatch(request []byte, reply func([]byte)) {
+	key := dispatcher.server.Key(request)
+	if key == "" {
+		go func() {
+			reply(dispatcher.server.Serve(request))
+		}()
+		return
+	}
+
+	dispatcher.lock.Lock()
+	defer dispatcher.lock.Unlock()
+
+	keyWorker := dispatcher.workers[key]
+	if keyWorker == nil {
+		keyWorker = new(worker)
+		dispatcher.workers[key] = keyWorker
+		go dispatcher.work(key, keyWorker)
+	}
+	keyWorker.queue = append(keyWorker.queue, &job{request: request, reply: reply})
+}
+
+// work is meant to run in the background. Serves the worker's queue until it
+// is empty, then retires the worker.
+func (dispatcher *Dispatcher) work(key string, keyWorker *worker) {
+	for {
+		dispatcher.lock.Lock()
+		if len(keyWorker.queue) == 0 {
+			delete(dispatcher.workers, key)
+			dispatcher.lock.Unlock()
+			return
+		}
+		nextJob := keyWorker.queue[0]
+		keyWorker.queue = keyWorker.queue[1:]
+		dispatcher.lock.Unlock()
+
+		nextJob.reply(dispatcher.server.Serve(nextJob.request))
+	}
+}
@@ -0,0 +1,115 @@
+package rpc
+
+import (
+	"encoding/json"
+	"resistance/game"
+)
+
+// CreateGameRequest asks for a new game hosted by the user the cookie belongs to.
+// Private games are left out of the lobby, and need their invite code or
+// their password, if they have one, to get into.
+type CreateGameRequest struct {
+	Title      string
+	Ranked     bool
+	Private    bool
+	Password   string
+	UserCookie string
+}
+
+type CreateGameReply struct {
+	GameId     int
+	InviteCode string
+}
+
+// IsValidGameRequest asks whether the user the cookie belongs to can load
+// the page of the given game, to play in it or just to watch. Private
+// games also need their invite code or password.
+type IsValidGameRequest struct {
+	GameId     string
+	Spectate   bool
+	InviteCode string
+	Password   string
+	UserCookie string
+}
+
+type IsValidGameReply struct {
+	GameTitle string
+	Private   bool
+}
+
+// GetAllGamesRequest asks for all the games waiting in the lobby.
+type GetAllGamesRequest struct {
+}
+
+type GetAllGamesReply struct {
+	Games []game.GameSummary
+}
+
+// ClientMessageRequest carries a message from a player's browser, forwarded
+// as is by the websocket proxy.
+type ClientMessageRequest struct {
+	UserCookie string
+	Payload    json.RawMessage
+}
+
+// ClientMessageReply carries the message to send back to the player's browser.
+// When the message was a player connecting and the player was accepted, it
+// also tells the websocket proxy which game to subscribe the player to, and
+// whether to subscribe them as a spectator.
+type ClientMessageReply struct {
+	AcceptUser bool
+	GameId     int
+	UserId     int
+	Spectate   bool
+	Payload    json.RawMessage
+}
+
+// PlayerDisconnectRequest tells the game server a player's, or spectator's,
+// connection to the given game went away.
+type PlayerDisconnectRequest struct {
+	GameId     string
+	Spectate   bool
+	UserCookie string
+}
+
+type PlayerDisconnectReply struct {
+}
+
+// TimeoutRequest is sent by the game server to itself when the move the
+// given game was waiting on runs out of time. The deadline, in Unix
+// nanoseconds, tells a timeout apart from one for an older deadline.
+type TimeoutRequest struct {
+	GameId   string
+	Deadline int64
+}
+
+type TimeoutReply struct {
+}
+
+// GetGameRequest asks for what anyone can see of the given game.
+type GetGameRequest struct {
+	GameId     string
+	UserCookie string
+}
+
+type GetGameReply struct {
+	State   *game.GameState
+	History *game.GameHistory
+}
+
+// GetUserStatsRequest asks for the profile and record of the user with the
+// given username.
+type GetUserStatsRequest struct {
+	Username string
+}
+
+type GetUserStatsReply struct {
+	UserId            int
+	Username          string
+	GamesPlayed       int
+	Wins              int
+	GamesAsResistance int
+	WinsAsResistance  int
+	GamesAsSpy        int
+	WinsAsSpy         int
+}
@@ -0,0 +1,48 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+const (
+	CREATE_GAME_METHOD       = "createGame"
+	IS_VALID_GAME_METHOD     = "isValidGame"
+	GET_ALL_GAMES_METHOD     = "getAllGames"
+	CLIENT_MESSAGE_METHOD    = "clientMessage"
+	PLAYER_DISCONNECT_METHOD = "playerDisconnect"
+	GET_GAME_METHOD          = "getGame"
+	GET_USER_STATS_METHOD    = "getUserStats"
+	TIMEOUT_METHOD           = "timeout"
+)
+
+var (
+	ErrTimeout       = errors.New("Timed out waiting for the game server.")
+	ErrUnknownMethod = errors.New("Unknown method.")
+	ErrBadReply      = errors.New("Could not understand the reply from the game server.")
+	ErrNotServing    = errors.New("The server is not being served.")
+)
+
+// Request is the envelope every call to the game server is sent in. The
+// body is one of the request structs in messages.go, depending on the method.
+type Request struct {
+	Method string
+	Body   json.RawMessage
+}
+
+// Reply is the envelope every reply from the game server is sent in. Either
+// the error is set, or the body is the reply struct for the method called.
+type Reply struct {
+	Error string
+	Body  json.RawMessage
+}
+
+// Error is an error the game server replied with, as opposed to an error
+// talking to the game server.
+type Error struct {
+	Message string
+}
+
+func (err *Error) Error() string {
+	return err.Message
+}
@@ -0,0 +1,128 @@
+package rpc
+
+import (
+	"encoding/json"
+	"resistance/utils"
+)
+
+// HandlerFunc handles the body of one request and returns the reply struct
+// to send back, or an error to reply with instead.
+type HandlerFunc func(body json.RawMessage) (interface{}, error)
+
+// KeyFunc works out from the body of a request which queue it belongs to.
+// Requests with the same key are handled one at a time, in order.
+type KeyFunc func(body json.RawMessage) string
+
+// Server dispatches the requests coming in to the game server to the
+// handler registered for their method.
+type Server struct {
+	handlers   map[string]HandlerFunc
+	keys       map[string]KeyFunc
+	dispatcher *Dispatcher
+}
+
+func NewServer() *Server {
+	return &Server{
+		handlers: make(map[string]HandlerFunc),
+		keys:     make(map[string]KeyFunc)}
+}
+
+// Handle registers the handler for the given method. Requests for it can
+// be handled at any time, alongside any other request.
+func (server *Server) Handle(method string, handler HandlerFunc) {
+	server.handlers[method] = handler
+}
+
+// HandleOrdered registers the handler for the given method. Requests for it
+// are handled in order with every other request with the same key.
+func (server *Server) HandleOrdered(method string, handler HandlerFunc, key KeyFunc) {
+	server.handlers[method] = handler
+	server.keys[method] = key
+}
+
+// Post queues a request the server makes of itself, like a timer going
+// off, to be handled in order with the requests coming in. Nobody waits on
+// the reply. Only works once the server is being served.
+func (server *Server) Post(method string, body interface{}) error {
+	if server.dispatcher == nil {
+		return ErrNotServing
+	}
+
+	rawBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	rawRequest, err := json.Marshal(&Request{Method: method, Body: rawBody})
+	if err != nil {
+		return err
+	}
+
+	server.dispatcher.Dispatch(rawRequest, func([]byte) {})
+	return nil
+}
+
+// Key gets the key of a raw request, or "" if it doesn't need to be
+// handled in any order.
+func (server *Server) Key(rawRequest []byte) string {
+	var request Request
+	err := json.Unmarshal(rawRequest, &request)
+	if err != nil {
+		return ""
+	}
+
+	key, ok := server.keys[request.Method]
+	if !ok {
+		return ""
+	}
+	return key(request.Body)
+}
+
+// Serve handles one raw request and returns the raw reply. There is always
+// a reply, so a REQ socket on the other end never gets stuck.
+func (server *Server) Serve(rawRequest []byte) []byte {
+	var reply Reply
+
+	var request Request
+	err := json.Unmarshal(rawRequest, &request)
+	if err != nil {
+		utils.LogMessage("Error parsing request: "+string(rawRequest), utils.RGAME_LOG_PATH)
+		reply.Error = err.Error()
+		return marshalReply(&reply)
+	}
+
+	handler, ok := server.handlers[request.Method]
+	if !ok {
+		utils.LogMessage("Unknown method: "+request.Method, utils.RGAME_LOG_PATH)
+		reply.Error = ErrUnknownMethod.Error()
+		return marshalReply(&reply)
+	}
+
+	replyBody, err := handler(request.Body)
+	if err != nil {
+		reply.Error = err.Error()
+		return marshalReply(&reply)
+	}
+
+	reply.Body, err = json.Marshal(replyBody)
+	if err != nil {
+		utils.LogMessage("Error marshalling reply: "+err.Error(), utils.RGAME_LOG_PATH)
+		reply.Body = nil
+		reply.Error = err.Error()
+	}
+	return marshalReply(&reply)
+}
+
+// Decode decodes the body of a request into the given request struct.
+func Decode(body json.RawMessage, request interface{}) error {
+	return json.Unmarshal(body, request)
+}
+
+func marshalReply(reply *Reply) []byte {
+	rawReply, err := json.Marshal(reply)
+	if err != nil {
+		// Can't happen with a string and a raw message, but don't leave
+		// the caller without a reply.
+		return []byte(`{"Error":"Error marshalling reply"}`)
+	}
+	return rawReply
+}
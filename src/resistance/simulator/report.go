@@ -0,0 +1,173 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"resistance/game"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// tally counts the games of one kind and who won them.
+type tally struct {
+	games          int
+	resistanceWins int
+	spyWins        int
+}
+
+func (count *tally) add(result *Result) {
+	count.games++
+	switch result.Winner {
+	case game.ROLE_RESISTANCE_NAME:
+		count.resistanceWins++
+	case game.ROLE_SPY_NAME:
+		count.spyWins++
+	}
+}
+
+// sideTally counts the seats a strategy played on each side and how many
+// of them won.
+type sideTally struct {
+	resistanceSeats int
+	resistanceWins  int
+	spySeats        int
+	spyWins         int
+}
+
+// Report adds up the results of many games.
+type Report struct {
+	games           int
+	stalled         int
+	byPlayers       map[int]*tally
+	byConfiguration map[string]*tally
+	byStrategy      map[string]*sideTally
+}
+
+func NewReport() *Report {
+	return &Report{
+		byPlayers:       make(map[int]*tally),
+		byConfiguration: make(map[string]*tally),
+		byStrategy:      make(map[string]*sideTally)}
+}
+
+// Add adds the result of one game to the report.
+func (report *Report) Add(result *Result) {
+	report.games++
+	if result.Winner == "" {
+		report.stalled++
+	}
+
+	if report.byPlayers[result.NumPlayers] == nil {
+		report.byPlayers[result.NumPlayers] = new(tally)
+	}
+	report.byPlayers[result.NumPlayers].add(result)
+
+	configuration := strconv.Itoa(result.NumPlayers) + " players: " +
+		describeSide(result.Resistance) + " vs " + describeSide(result.Spies)
+	if report.byConfiguration[configuration] == nil {
+		report.byConfiguration[configuration] = new(tally)
+	}
+	report.byConfiguration[configuration].add(result)
+
+	for _, strategyName := range result.Resistance {
+		count := report.getSideTally(strategyName)
+		count.resistanceSeats++
+		if result.Winner == game.ROLE_RESISTANCE_NAME {
+			count.resistanceWins++
+		}
+	}
+	for _, strategyName := range result.Spies {
+		count := report.getSideTally(strategyName)
+		count.spySeats++
+		if result.Winner == game.ROLE_SPY_NAME {
+			count.spyWins++
+		}
+	}
+}
+
+func (report *Report) getSideTally(strategyName string) *sideTally {
+	if report.byStrategy[strategyName] == nil {
+		report.byStrategy[strategyName] = new(sideTally)
+	}
+	return report.byStrategy[strategyName]
+}
+
+// Write writes out the report as tables.
+func (report *Report) Write(writer io.Writer) {
+	fmt.Fprintf(writer, "Games: %d, given up on: %d\n\n", report.games, report.stalled)
+
+	table := tabwriter.NewWriter(writer, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(table, "Players\tGames\tResistance wins\tSpy wins")
+	playerCounts := make([]int, 0)
+	for numPlayers := range report.byPlayers {
+		playerCounts = append(playerCounts, numPlayers)
+	}
+	sort.Ints(playerCounts)
+	for _, numPlayers := range playerCounts {
+		count := report.byPlayers[numPlayers]
+		fmt.Fprintf(table, "%d\t%d\t%s\t%s\n", numPlayers, count.games,
+			percentage(count.resistanceWins, count.games), percentage(count.spyWins, count.games))
+	}
+	table.Flush()
+	fmt.Fprintln(writer)
+
+	table = tabwriter.NewWriter(writer, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(table, "Resistance vs spies\tGames\tResistance wins\tSpy wins")
+	configurations := make([]string, 0)
+	for configuration := range report.byConfiguration {
+		configurations = append(configurations, configuration)
+	}
+	sort.Strings(configurations)
+	for _, configuration := range configurations {
+		count := report.byConfiguration[configuration]
+		fmt.Fprintf(table, "%s\t%d\t%s\t%s\n", configuration, count.games,
+			percentage(count.resistanceWins, count.games), percentage(count.spyWins, count.games))
+	}
+	table.Flush()
+	fmt.Fprintln(writer)
+
+	table = tabwriter.NewWriter(writer, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(table, "Strategy\tResistance seats\tWon\tSpy seats\tWon")
+	strategyNames := make([]string, 0)
+	for strategyName := range report.byStrategy {
+		strategyNames = append(strategyNames, strategyName)
+	}
+	sort.Strings(strategyNames)
+	for _, strategyName := range strategyNames {
+		count := report.byStrategy[strategyName]
+		fmt.Fprintf(table, "%s\t%d\t%s\t%d\t%s\n", strategyName,
+			count.resistanceSeats, percentage(count.resistanceWins, count.resistanceSeats),
+			count.spySeats, percentage(count.spyWins, count.spySeats))
+	}
+	table.Flush()
+}
+
+// describeSide describes the strategies playing on a side, like
+// "2 Heuristic + 1 Random".
+func describeSide(strategyNames []string) string {
+	counts := make(map[string]int)
+	for _, strategyName := range strategyNames {
+		counts[strategyName]++
+	}
+
+	names := make([]string, 0)
+	for strategyName := range counts {
+		names = append(names, strategyName)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for index, strategyName := range names {
+		parts[index] = strconv.Itoa(counts[strategyName]) + " " + strategyName
+	}
+	return strings.Join(parts, " + ")
+}
+
+func percentage(part int, whole int) string {
+	if whole == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(part)/float64(whole))
+}
@@ -0,0 +1,100 @@
+// Package simulator plays whole games between bots without a database or
+// a browser, to see how the rules and the strategies hold up.
+package simulator
+
+import (
+	"errors"
+	_ "resistance/bots"
+	"resistance/game"
+	"resistance/persist"
+	"resistance/users"
+	"strconv"
+)
+
+const (
+	// The game has no limit on how many teams can be rejected in a row, so
+	// bots that never agree would play forever. Those games are given up on.
+	MAX_TEAM_PROPOSALS = 100
+)
+
+// Result is how one game went.
+type Result struct {
+	NumPlayers int
+	// game.ROLE_RESISTANCE_NAME or game.ROLE_SPY_NAME, empty if the game
+	// was given up on
+	Winner string
+	// Strategies of the players on each side
+	Spies      []string
+	Resistance []string
+	Proposals  int
+}
+
+// PlayGame plays one game to the end between bots playing the given
+// strategies, one per seat.
+func PlayGame(persister *persist.MemoryPersister, strategyNames []string) (*Result, error) {
+	bots := make([]*users.User, len(strategyNames))
+	for seat, strategyName := range strategyNames {
+		bots[seat] = &users.User{UserId: seat + 1, Username: strategyName + " " + strconv.Itoa(seat+1)}
+	}
+
+	currentGame := game.NewGame("Simulation", bots[0], false, persister)
+	defer persister.ForgetGame(currentGame.GameId)
+
+	for seat, strategyName := range strategyNames {
+		strategy := game.NewStrategy(strategyName)
+		if strategy == nil {
+			return nil, errors.New("Unknown strategy: " + strategyName)
+		}
+		currentGame.AddBot(bots[seat], strategy)
+	}
+
+	err := currentGame.StartGame()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{NumPlayers: len(currentGame.Players)}
+	for _, player := range currentGame.Players {
+		if player.Role == game.ROLE_SPY {
+			result.Spies = append(result.Spies, player.Strategy.Name())
+		} else {
+			result.Resistance = append(result.Resistance, player.Strategy.Name())
+		}
+	}
+
+	for result.Proposals < MAX_TEAM_PROPOSALS {
+		currentMission := game.NewMission(currentGame)
+		result.Proposals++
+
+		leader := currentGame.GetPlayer(currentMission.Leader)
+		team, _ := currentGame.CheckTeam(leader.Strategy.ProposeTeam(currentGame.GetState(leader.User)))
+		currentMission.CreateTeam(team)
+
+		for _, player := range currentGame.Players {
+			currentMission.AddVote(player.User, player.Strategy.Vote(currentGame.GetState(player.User)))
+		}
+		if !currentMission.IsTeamApproved() {
+			currentMission.EndMission(game.WINNER_NONE)
+			continue
+		}
+
+		for _, player := range currentGame.Players {
+			if currentMission.IsUserOnCurrentMission(player.User) {
+				// Only spies can fail a mission, whatever the strategy says
+				outcome := player.Strategy.MissionOutcome(currentGame.GetState(player.User)) || player.Role != game.ROLE_SPY
+				currentMission.AddOutcome(player.User, outcome)
+			}
+		}
+		_, missionResult := currentMission.IsMissionOver()
+		currentMission.EndMission(missionResult)
+
+		isGameOver, winner := currentGame.IsGameOver()
+		if isGameOver {
+			currentGame.EndGame()
+			result.Winner = winner
+			break
+		}
+	}
+
+	return result, nil
+}
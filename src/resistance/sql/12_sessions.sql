@@ -0,0 +1,12 @@
+# Describes the sessions table - one row per logged in device of a user.
+# Only a hash of the session token is stored.
+
+CREATE TABLE IF NOT EXISTS `sessions` (
+  `session_id` BIGINT(20) NOT NULL AUTO_INCREMENT,
+  `user_id` BIGINT(20) NOT NULL,
+  `token_hash` CHAR(64) NOT NULL,
+  `creation_date` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  `expiry_date` TIMESTAMP NULL DEFAULT NULL,
+  PRIMARY KEY (`session_id`),
+  UNIQUE KEY (`token_hash`)
+)
@@ -0,0 +1,4 @@
+# Drops the cookie column in the users table. Sessions are now stored
+# in the sessions table so a user can be logged in on more than one device.
+
+ALTER TABLE `users` DROP COLUMN `cookie`;
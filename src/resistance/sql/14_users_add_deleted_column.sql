@@ -0,0 +1,4 @@
+# Adds the deleted column to the users table. Deleted accounts are kept
+# around anonymized so that the history of the games they played in stays intact.
+
+ALTER TABLE `users` ADD `deleted` TINYINT(1) NOT NULL DEFAULT 0;
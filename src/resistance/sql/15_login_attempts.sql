@@ -0,0 +1,13 @@
+# Describes the login_attempts table - an audit log of every login attempt,
+# used to throttle repeated failed logins by username and by IP address.
+
+CREATE TABLE IF NOT EXISTS `login_attempts` (
+  `attempt_id` BIGINT(20) NOT NULL AUTO_INCREMENT,
+  `username` VARCHAR(30) NOT NULL,
+  `ip_address` VARCHAR(45) NOT NULL,
+  `success` TINYINT(1) NOT NULL,
+  `attempt_date` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`attempt_id`),
+  KEY (`username`, `attempt_date`),
+  KEY (`ip_address`, `attempt_date`)
+)
@@ -0,0 +1,5 @@
+# Adds guest users. Guests sign in with just a name and are cleaned up
+# after they have been inactive for a while.
+
+ALTER TABLE `users` ADD `is_guest` TINYINT(1) NOT NULL DEFAULT 0;
+ALTER TABLE `users` ADD `last_active` TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
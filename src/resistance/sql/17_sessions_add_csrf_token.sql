@@ -0,0 +1,4 @@
+# Adds the csrf_token column to the sessions table. Every form submitted
+# while logged in has to carry the token of its session.
+
+ALTER TABLE `sessions` ADD `csrf_token` CHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1,14 @@
+# Describes the api_tokens table - tokens users create for their bots and
+# scripts to call the API with. Only a hash of each token is stored.
+
+CREATE TABLE IF NOT EXISTS `api_tokens` (
+  `token_id` BIGINT(20) NOT NULL AUTO_INCREMENT,
+  `user_id` BIGINT(20) NOT NULL,
+  `name` VARCHAR(30) NOT NULL DEFAULT '',
+  `token_hash` CHAR(64) NOT NULL,
+  `creation_date` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  `last_used` TIMESTAMP NULL DEFAULT NULL,
+  PRIMARY KEY (`token_id`),
+  UNIQUE KEY (`token_hash`),
+  KEY (`user_id`)
+)
@@ -0,0 +1,3 @@
+# Adds bot users. Bots play in games like everyone else, but never log in.
+
+ALTER TABLE `users` ADD `is_bot` TINYINT(1) NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+# Adds the strategy column to the players table - the strategy driving the
+# player if it is a bot, empty otherwise.
+
+ALTER TABLE `players` ADD `strategy` VARCHAR(20) NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+# Adds the ranked column to the games table. Ranked games are played without
+# the spy odds assistant.
+
+ALTER TABLE `games` ADD `ranked` TINYINT(1) NOT NULL DEFAULT 0;
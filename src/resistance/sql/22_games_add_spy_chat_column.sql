@@ -0,0 +1,4 @@
+# Adds the spy_chat column to the games table - whether the host lets the
+# spies talk among themselves.
+
+ALTER TABLE `games` ADD `spy_chat` TINYINT(1) NOT NULL DEFAULT 0;
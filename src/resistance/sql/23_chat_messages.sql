@@ -0,0 +1,13 @@
+# Describes the chat_messages table - everything said in each game, on the
+# channel everyone reads or the spies' own.
+
+CREATE TABLE IF NOT EXISTS `chat_messages` (
+  `message_id` BIGINT(20) NOT NULL AUTO_INCREMENT,
+  `game_id` BIGINT(20) NOT NULL,
+  `user_id` BIGINT(20) NOT NULL,
+  `channel` CHAR(1) NOT NULL,
+  `message` VARCHAR(200) NOT NULL,
+  `creation_date` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`message_id`),
+  KEY (`game_id`)
+)
@@ -0,0 +1,10 @@
+# Adds turn timers. The games table gets how long players have for each
+# move, in seconds (0 for no limit), and the missions, votes and teams tables
+# record which moves were made for players who ran out of time.
+
+ALTER TABLE `games` ADD `team_time_limit` INT NOT NULL DEFAULT 0;
+ALTER TABLE `games` ADD `vote_time_limit` INT NOT NULL DEFAULT 0;
+ALTER TABLE `games` ADD `mission_time_limit` INT NOT NULL DEFAULT 0;
+ALTER TABLE `missions` ADD `team_timed_out` TINYINT(1) NOT NULL DEFAULT 0;
+ALTER TABLE `votes` ADD `timed_out` TINYINT(1) NOT NULL DEFAULT 0;
+ALTER TABLE `teams` ADD `timed_out` TINYINT(1) NOT NULL DEFAULT 0;
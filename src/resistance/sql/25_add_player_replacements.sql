@@ -0,0 +1,7 @@
+# Adds replacing players who left in the middle of a game. The games table
+# gets how long, in seconds, they have to come back (0 to wait as long as it
+# takes), and the players table records who took over the seat of a player
+# who didn't.
+
+ALTER TABLE `games` ADD `disconnect_time_limit` INT NOT NULL DEFAULT 0;
+ALTER TABLE `players` ADD `replaced_by` BIGINT(20) NOT NULL DEFAULT 0;
@@ -0,0 +1,7 @@
+# Adds the host's lobby controls. The games table gets whether the host
+# locked the lobby, and the players table gets where each player sits, which
+# is the order leaders take turns in, and whether the host kicked them out.
+
+ALTER TABLE `games` ADD `locked` TINYINT(1) NOT NULL DEFAULT 0;
+ALTER TABLE `players` ADD `seat` INT NOT NULL DEFAULT 0;
+ALTER TABLE `players` ADD `kicked` TINYINT(1) NOT NULL DEFAULT 0;
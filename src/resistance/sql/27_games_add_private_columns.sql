@@ -0,0 +1,7 @@
+# Adds private games, which aren't listed in the lobby. The games table gets
+# whether the game is private, its invite code, and the salted hash of its
+# password, if the host gave it one.
+
+ALTER TABLE `games` ADD `private` TINYINT(1) NOT NULL DEFAULT 0;
+ALTER TABLE `games` ADD `invite_code` VARCHAR(32) NOT NULL DEFAULT '';
+ALTER TABLE `games` ADD `password_hash` VARCHAR(128) NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+# Adds the throttled column to the login_attempts table - attempts turned away
+# before the password was checked. They don't count as failed attempts.
+
+ALTER TABLE `login_attempts` ADD `throttled` TINYINT(1) NOT NULL DEFAULT 0;
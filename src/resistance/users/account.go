@@ -0,0 +1,108 @@
+package users
+
+import (
+	"net/http"
+	"resistance/utils"
+	"strconv"
+)
+
+const (
+	OLD_PASSWORD_KEY = "oldPassword"
+	NEW_USERNAME_KEY = "newUsername"
+)
+
+// ChangePassword changes the password of the given user after verifying
+// the old one. All other sessions of the user are ended, so a stolen
+// session doesn't survive a password change. Returns if there is an error
+// and the corresponding error message
+func ChangePassword(user *User, request *http.Request) (bool, string) {
+	if !isCurrentPassword(user, request.FormValue(OLD_PASSWORD_KEY)) {
+		return true, "Old password is not correct."
+	}
+
+	password := request.FormValue(PASSWORD_KEY)
+	repeatPassword := request.FormValue(REPEAT_PASSWORD_KEY)
+	hasError, errorMessage := checkPassword(password, repeatPassword)
+	if hasError {
+		return hasError, errorMessage
+	}
+
+	err := updatePassword(user.UserId, password)
+	if err != nil {
+		utils.LogMessage("Error changing password: "+err.Error(), utils.USER_LOG_PATH)
+		return true, "Error changing password"
+	}
+
+	currentToken := ""
+	cookie := findSessionCookie(request.Cookies())
+	if cookie != nil {
+		currentToken = hashSessionToken(cookie.Value)
+	}
+	err = deleteOtherSessions(user.UserId, currentToken)
+	if err != nil {
+		utils.LogMessage("Error ending other sessions: "+err.Error(), utils.USER_LOG_PATH)
+	}
+
+	return false, ""
+}
+
+// ChangeUsername changes the display name of the given user. The user id
+// stays the same, so all the games the user played in stay theirs.
+// Returns if there is an error and the corresponding error message
+func ChangeUsername(user *User, request *http.Request) (bool, string) {
+	newUsername := request.FormValue(NEW_USERNAME_KEY)
+	hasError, errorMessage := checkUsername(newUsername)
+	if hasError {
+		return hasError, errorMessage
+	}
+
+	err := updateUsername(user.UserId, newUsername)
+	if err != nil {
+		utils.LogMessage("Error changing username: "+err.Error(), utils.USER_LOG_PATH)
+		return true, "Error changing username"
+	}
+
+	user.Username = newUsername
+	return false, ""
+}
+
+// DeleteAccount deletes the given user's account after verifying their
+// password. The user is anonymized rather than removed, so the players,
+// teams and votes of past games still point at a user. Returns if there is
+// an error and the corresponding error message
+func DeleteAccount(user *User, request *http.Request) (bool, string) {
+	if !isCurrentPassword(user, request.FormValue(PASSWORD_KEY)) {
+		return true, "Password is not correct."
+	}
+
+	err := anonymizeUser(user.UserId)
+	if err != nil {
+		utils.LogMessage("Error deleting user: "+err.Error(), utils.USER_LOG_PATH)
+		return true, "Error deleting account"
+	}
+
+	err = deleteAllSessions(user.UserId)
+	if err != nil {
+		utils.LogMessage("Error ending sessions: "+err.Error(), utils.USER_LOG_PATH)
+	}
+
+	err = deleteAllAPITokens(user.UserId)
+	if err != nil {
+		utils.LogMessage("Error revoking API tokens: "+err.Error(), utils.USER_LOG_PATH)
+	}
+
+	utils.LogMessage("Deleted user id "+strconv.Itoa(user.UserId), utils.USER_LOG_PATH)
+	return false, ""
+}
+
+// isCurrentPassword checks the given password against the user's password.
+func isCurrentPassword(user *User, password string) bool {
+	id, validUser := validateUserCredentials(user.Username, password)
+	return validUser && id == user.UserId
+}
+
+// anonymizedUsername is the username a deleted user is left with. It is
+// longer than any username allowed at sign up so it can never be taken.
+func anonymizedUsername(userId int) string {
+	return "[deleted " + strconv.Itoa(userId) + "]"
+}
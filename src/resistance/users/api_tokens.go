@@ -0,0 +1,166 @@
+package users
+
+import (
+	"net/http"
+	"resistance/utils"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	API_TOKEN_NAME_KEY        = "tokenName"
+	API_TOKEN_ID_KEY          = "tokenId"
+	API_TOKEN_CREDENTIAL      = "Bearer"
+	MAX_API_TOKEN_NAME_LENGTH = 30
+	MAX_API_TOKENS            = 10
+)
+
+// APIToken is what the account page shows about a token. The token itself
+// is only ever shown once, right after it is created.
+type APIToken struct {
+	TokenId      int
+	Name         string
+	CreationDate string
+	LastUsed     string
+}
+
+// CreateAPIToken creates a new API token for the given user, named with the
+// name given in the request. Returns the token, whether there is an error
+// and the corresponding error message
+func CreateAPIToken(user *User, request *http.Request) (string, bool, string) {
+	if user.IsGuest {
+		return "", true, "Guests can't create API tokens."
+	}
+
+	name := strings.TrimSpace(request.FormValue(API_TOKEN_NAME_KEY))
+	if name == "" {
+		return "", true, "Token name can not be empty."
+	}
+	if utf8.RuneCountInString(name) > MAX_API_TOKEN_NAME_LENGTH {
+		return "", true, "Token name must be at most " + strconv.Itoa(MAX_API_TOKEN_NAME_LENGTH) + " characters long."
+	}
+	for _, character := range name {
+		if unicode.IsControl(character) {
+			return "", true, "Token name can not contain control characters."
+		}
+	}
+
+	tokens, err := listAPITokens(user.UserId)
+	if err != nil {
+		utils.LogMessage("Error listing API tokens: "+err.Error(), utils.USER_LOG_PATH)
+		return "", true, "Error creating token"
+	}
+	if len(tokens) >= MAX_API_TOKENS {
+		return "", true, "You can have at most " + strconv.Itoa(MAX_API_TOKENS) + " tokens. Revoke one first."
+	}
+
+	token, err := generateSessionToken()
+	if err != nil {
+		utils.LogMessage("Error generating API token: "+err.Error(), utils.USER_LOG_PATH)
+		return "", true, "Error creating token"
+	}
+
+	err = persistAPIToken(user.UserId, name, hashSessionToken(token))
+	if err != nil {
+		utils.LogMessage("Error persisting API token: "+err.Error(), utils.USER_LOG_PATH)
+		return "", true, "Error creating token"
+	}
+
+	utils.LogMessage("Created an API token for user id "+strconv.Itoa(user.UserId), utils.USER_LOG_PATH)
+	return token, false, ""
+}
+
+// RevokeAPIToken revokes the token given in the request, as long as it
+// belongs to the given user. Returns if there is an error and the
+// corresponding error message
+func RevokeAPIToken(user *User, request *http.Request) (bool, string) {
+	tokenId, err := strconv.Atoi(request.FormValue(API_TOKEN_ID_KEY))
+	if err != nil {
+		return true, "Token is not valid."
+	}
+
+	err = deleteAPIToken(user.UserId, tokenId)
+	if err != nil {
+		utils.LogMessage("Error revoking API token: "+err.Error(), utils.USER_LOG_PATH)
+		return true, "Error revoking token"
+	}
+
+	return false, ""
+}
+
+// GetAPITokens lists the API tokens of the given user.
+func GetAPITokens(user *User) []APIToken {
+	tokens, err := listAPITokens(user.UserId)
+	if err != nil {
+		utils.LogMessage("Error listing API tokens: "+err.Error(), utils.USER_LOG_PATH)
+		return make([]APIToken, 0)
+	}
+	return tokens
+}
+
+// GetBearerToken gets the API token from the Authorization header of the
+// request. Returns an empty string if there is none.
+func GetBearerToken(request *http.Request) string {
+	authorization := strings.SplitN(request.Header.Get("Authorization"), " ", 2)
+	if len(authorization) != 2 || !strings.EqualFold(authorization[0], API_TOKEN_CREDENTIAL) {
+		return ""
+	}
+	return strings.TrimSpace(authorization[1])
+}
+
+// ValidateAPIToken validates a user given an API token.
+func ValidateAPIToken(token string) *User {
+	if token == "" {
+		return UNKNOWN_USER
+	}
+
+	user := lookupUserByAPIToken(hashSessionToken(token))
+	if user.IsValidUser() {
+		err := touchAPIToken(hashSessionToken(token))
+		if err != nil {
+			utils.LogMessage("Error updating API token activity: "+err.Error(), utils.USER_LOG_PATH)
+		}
+	}
+	return user
+}
+
+// ValidateRequest validates the user making the request, by their API
+// token if they sent one, otherwise by their session cookie.
+func ValidateRequest(request *http.Request) *User {
+	token := GetBearerToken(request)
+	if token != "" {
+		return ValidateAPIToken(token)
+	}
+	return ValidateUserCookie(request.Cookies())
+}
+
+// GetCredential gets what identifies the user making the request, in the
+// form passed along to the game server: the API token if they sent one,
+// otherwise the session cookie.
+func GetCredential(request *http.Request) string {
+	token := GetBearerToken(request)
+	if token != "" {
+		return API_TOKEN_CREDENTIAL + "=" + token
+	}
+
+	cookie := findSessionCookie(request.Cookies())
+	if cookie == nil {
+		return ""
+	}
+	return cookie.Name + "=" + cookie.Value
+}
+
+// ValidateCredential validates a user given a credential from GetCredential.
+func ValidateCredential(credential string) *User {
+	parsedCredential := strings.SplitN(credential, "=", 2)
+	if len(parsedCredential) != 2 {
+		return UNKNOWN_USER
+	}
+
+	if parsedCredential[0] == API_TOKEN_CREDENTIAL {
+		return ValidateAPIToken(parsedCredential[1])
+	}
+	return ValidateUserCookie([]*http.Cookie{{Name: parsedCredential[0], Value: parsedCredential[1]}})
+}
@@ -0,0 +1,23 @@
+package users
+
+import (
+	"resistance/utils"
+)
+
+// GetBotUser gets the bot user with the given name, creating it the first
+// time it is needed. Bot names have a space in them, so no one can sign up
+// or rename themselves to one.
+func GetBotUser(name string) *User {
+	user := lookupBotByUsername(name)
+	if user.IsValidUser() {
+		return user
+	}
+
+	id, err := persistBot(name)
+	if err != nil {
+		utils.LogMessage("Error persisting bot: "+err.Error(), utils.USER_LOG_PATH)
+		return UNKNOWN_USER
+	}
+
+	return &User{Username: name, UserId: id}
+}
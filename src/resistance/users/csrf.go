@@ -0,0 +1,84 @@
+package users
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"resistance/utils"
+)
+
+const (
+	CSRF_TOKEN_KEY   = "csrfToken"
+	CSRF_COOKIE_NAME = "RCSRF"
+	CSRF_HEADER_NAME = "X-CSRF-Token"
+)
+
+// GetCSRFToken gets the token to put in the forms of the page being
+// rendered. Logged in users get the token of their session. Everyone else
+// (for example on the login page) gets a token kept in its own cookie,
+// which is set on the response if it doesn't exist yet.
+func GetCSRFToken(writer http.ResponseWriter, request *http.Request) string {
+	cookie := findSessionCookie(request.Cookies())
+	if cookie != nil {
+		csrfToken := lookupCSRFToken(hashSessionToken(cookie.Value))
+		if csrfToken != "" {
+			return csrfToken
+		}
+	}
+
+	csrfCookie, err := request.Cookie(CSRF_COOKIE_NAME)
+	if err == nil && csrfCookie.Value != "" {
+		return csrfCookie.Value
+	}
+
+	csrfToken, err := generateSessionToken()
+	if err != nil {
+		utils.LogMessage("Error generating CSRF token: "+err.Error(), utils.USER_LOG_PATH)
+		return ""
+	}
+	http.SetCookie(writer, &http.Cookie{
+		Name:     CSRF_COOKIE_NAME,
+		Value:    csrfToken,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   isSecureRequest(request),
+		SameSite: http.SameSiteStrictMode})
+	return csrfToken
+}
+
+// ValidateCSRFToken validates the token submitted with a form against the
+// token of the session it was submitted from, or against the CSRF cookie
+// if there is no session.
+func ValidateCSRFToken(request *http.Request) bool {
+	return validateCSRFToken(request, request.PostFormValue(CSRF_TOKEN_KEY))
+}
+
+// ValidateCSRFHeader validates the token sent in the X-CSRF-Token header,
+// for requests from scripts on our pages rather than forms.
+func ValidateCSRFHeader(request *http.Request) bool {
+	return validateCSRFToken(request, request.Header.Get(CSRF_HEADER_NAME))
+}
+
+// validateCSRFToken validates the submitted token the same way for forms
+// and headers.
+func validateCSRFToken(request *http.Request, submittedToken string) bool {
+	if submittedToken == "" {
+		return false
+	}
+
+	expectedToken := ""
+	cookie := findSessionCookie(request.Cookies())
+	if cookie != nil {
+		expectedToken = lookupCSRFToken(hashSessionToken(cookie.Value))
+	}
+	if expectedToken == "" {
+		csrfCookie, err := request.Cookie(CSRF_COOKIE_NAME)
+		if err == nil {
+			expectedToken = csrfCookie.Value
+		}
+	}
+
+	if expectedToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(submittedToken), []byte(expectedToken)) == 1
+}
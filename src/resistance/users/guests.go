@@ -0,0 +1,82 @@
+package users
+
+import (
+	"net/http"
+	"resistance/utils"
+	"time"
+)
+
+const (
+	GUEST_INACTIVITY_LIMIT = 24 * time.Hour
+)
+
+// GuestSignIn creates a temporary guest user with the name given in the
+// request and starts a session for it, so people can play without signing
+// up. Returns the session cookie, whether there is an error and the
+// corresponding error message
+func GuestSignIn(request *http.Request) (*http.Cookie, bool, string) {
+	username := request.FormValue(USERNAME_KEY)
+	hasError, errorMessage := checkUsername(username)
+	if hasError {
+		return nil, hasError, errorMessage
+	}
+
+	id, err := persistGuest(username)
+	if err != nil {
+		utils.LogMessage("Error persisting guest: "+err.Error(), utils.USER_LOG_PATH)
+		return nil, true, "Error creating guest"
+	}
+
+	cookie, err := createSession(id, request)
+	if err != nil {
+		utils.LogMessage("Error creating session: "+err.Error(), utils.USER_LOG_PATH)
+		return nil, true, "Error creating guest"
+	}
+
+	return cookie, false, ""
+}
+
+// ConvertGuest turns the given guest into a full account with the username
+// and password given in the request. The user id stays the same so the
+// games they played as a guest stay in their history. Returns if there is
+// an error and the corresponding error message
+func ConvertGuest(user *User, request *http.Request) (bool, string) {
+	if !user.IsGuest {
+		return true, "You already have an account."
+	}
+
+	username := request.FormValue(USERNAME_KEY)
+	if username != user.Username {
+		hasError, errorMessage := checkUsername(username)
+		if hasError {
+			return hasError, errorMessage
+		}
+	}
+
+	password := request.FormValue(PASSWORD_KEY)
+	repeatPassword := request.FormValue(REPEAT_PASSWORD_KEY)
+	hasError, errorMessage := checkPassword(password, repeatPassword)
+	if hasError {
+		return hasError, errorMessage
+	}
+
+	err := convertGuest(user.UserId, username, password)
+	if err != nil {
+		utils.LogMessage("Error converting guest: "+err.Error(), utils.USER_LOG_PATH)
+		return true, "Error creating account"
+	}
+
+	user.Username = username
+	user.IsGuest = false
+	return false, ""
+}
+
+// CollectInactiveGuests cleans up the guests that have not been active
+// for a while. Guests that played in a game are anonymized instead of
+// deleted so that game's history stays intact.
+func CollectInactiveGuests() {
+	err := removeInactiveGuests(GUEST_INACTIVITY_LIMIT)
+	if err != nil {
+		utils.LogMessage("Error collecting inactive guests: "+err.Error(), utils.USER_LOG_PATH)
+	}
+}
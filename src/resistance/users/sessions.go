@@ -0,0 +1,110 @@
+package users
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"resistance/utils"
+	"strconv"
+	"time"
+)
+
+const (
+	SESSION_TOKEN_BYTES = 32
+	SESSION_DURATION    = 30 * 24 * time.Hour
+)
+
+// generateSessionToken creates a new cryptographically random session token.
+func generateSessionToken() (string, error) {
+	tokenBytes := make([]byte, SESSION_TOKEN_BYTES)
+	_, err := rand.Read(tokenBytes)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(tokenBytes), nil
+}
+
+// hashSessionToken hashes the session token before it touches the DB so
+// that the sessions table can't be used to impersonate anyone.
+func hashSessionToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// createSession starts a new session for the given user and returns the
+// cookie to hand back to the browser. Each login gets its own session, so
+// a user can be logged in from more than one device at a time.
+func createSession(userId int, request *http.Request) (*http.Cookie, error) {
+	token, err := generateSessionToken()
+	if err != nil {
+		return nil, err
+	}
+
+	csrfToken, err := generateSessionToken()
+	if err != nil {
+		return nil, err
+	}
+
+	err = persistSession(userId, hashSessionToken(token), csrfToken, SESSION_DURATION)
+	if err != nil {
+		return nil, err
+	}
+
+	cookie := &http.Cookie{
+		Name:     COOKIE_NAME,
+		Value:    token,
+		Path:     "/",
+		Expires:  time.Now().Add(SESSION_DURATION),
+		MaxAge:   int(SESSION_DURATION.Seconds()),
+		HttpOnly: true,
+		Secure:   isSecureRequest(request),
+		SameSite: http.SameSiteLaxMode}
+	utils.LogMessage("Created a new session for user id "+strconv.Itoa(userId), utils.USER_LOG_PATH)
+	return cookie, nil
+}
+
+// findSessionCookie finds the session cookie by name among the given cookies.
+// Returns nil if there is no session cookie.
+func findSessionCookie(requestCookies []*http.Cookie) *http.Cookie {
+	for _, cookie := range requestCookies {
+		if cookie.Name == COOKIE_NAME && cookie.Value != "" {
+			return cookie
+		}
+	}
+	return nil
+}
+
+// InvalidateSession ends the session given in the cookies on the server
+// side, so the token can't be used again even if the browser keeps it.
+func InvalidateSession(requestCookies []*http.Cookie) {
+	cookie := findSessionCookie(requestCookies)
+	if cookie == nil {
+		return
+	}
+
+	err := deleteSession(hashSessionToken(cookie.Value))
+	if err != nil {
+		utils.LogMessage("Error deleting session: "+err.Error(), utils.USER_LOG_PATH)
+	}
+}
+
+// ExpiredSessionCookie returns a cookie that tells the browser to forget
+// its session cookie.
+func ExpiredSessionCookie(request *http.Request) *http.Cookie {
+	return &http.Cookie{
+		Name:     COOKIE_NAME,
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isSecureRequest(request),
+		SameSite: http.SameSiteLaxMode}
+}
+
+// isSecureRequest determines whether the request came in over HTTPS, either
+// directly or through a proxy in front of us.
+func isSecureRequest(request *http.Request) bool {
+	return request.TLS != nil || request.Header.Get("X-Forwarded-Proto") == "https"
+}
@@ -0,0 +1,124 @@
+package users
+
+import (
+	"net"
+	"net/http"
+	"resistance/utils"
+	"strconv"
+	"time"
+)
+
+const (
+	// Failed attempts in a row allowed for a username before having to wait
+	USERNAME_FREE_ATTEMPTS = 3
+	// Failed attempts in a row after which a username is locked out
+	USERNAME_LOCKOUT_ATTEMPTS = 10
+	// IP addresses can be shared, so they get more room
+	IP_FREE_ATTEMPTS    = 10
+	IP_LOCKOUT_ATTEMPTS = 50
+
+	LOGIN_BACKOFF_BASE = 2 * time.Second
+	LOGIN_BACKOFF_MAX  = 5 * time.Minute
+	LOCKOUT_DURATION   = 15 * time.Minute
+	// Failed attempts older than this are forgotten
+	FAILED_ATTEMPTS_WINDOW = time.Hour
+
+	// The size of the username column of the login_attempts table
+	MAX_ATTEMPT_USERNAME_LENGTH = 30
+)
+
+// loginRetryWait determines how long the given username and IP address have
+// to wait before they are allowed to try to log in again. Zero means they
+// can try now.
+func loginRetryWait(username string, ipAddress string) time.Duration {
+	username = getAttemptUsername(username)
+	failures, lastFailure, now, err := countFailedLoginsByUsername(username, FAILED_ATTEMPTS_WINDOW)
+	if err != nil {
+		utils.LogMessage("Error counting failed logins: "+err.Error(), utils.USER_LOG_PATH)
+	}
+	usernameWait := retryWait(failures, lastFailure, now, USERNAME_FREE_ATTEMPTS, USERNAME_LOCKOUT_ATTEMPTS)
+
+	failures, lastFailure, now, err = countFailedLoginsByIp(ipAddress, FAILED_ATTEMPTS_WINDOW)
+	if err != nil {
+		utils.LogMessage("Error counting failed logins: "+err.Error(), utils.USER_LOG_PATH)
+	}
+	ipWait := retryWait(failures, lastFailure, now, IP_FREE_ATTEMPTS, IP_LOCKOUT_ATTEMPTS)
+
+	if ipWait > usernameWait {
+		return ipWait
+	}
+	return usernameWait
+}
+
+// retryWait works out the remaining wait given the number of failed attempts
+// and when the last one happened (both unix times from the DB). The wait
+// doubles with every failure past the free attempts until it turns into
+// a lockout.
+func retryWait(failures int, lastFailure int64, now int64, freeAttempts int, lockoutAttempts int) time.Duration {
+	if failures < freeAttempts {
+		return 0
+	}
+
+	var delay time.Duration
+	if failures >= lockoutAttempts {
+		delay = LOCKOUT_DURATION
+	} else {
+		delay = LOGIN_BACKOFF_BASE << uint(failures-freeAttempts)
+		if delay > LOGIN_BACKOFF_MAX {
+			delay = LOGIN_BACKOFF_MAX
+		}
+	}
+
+	wait := time.Duration(lastFailure-now)*time.Second + delay
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// recordLoginAttempt adds the login attempt to the audit table. Throttled
+// attempts are turned away before the password is checked, so they are
+// neither a success nor a failure.
+func recordLoginAttempt(username string, ipAddress string, success bool, throttled bool) {
+	err := persistLoginAttempt(getAttemptUsername(username), ipAddress, success, throttled)
+	if err != nil {
+		utils.LogMessage("Error recording login attempt: "+err.Error(), utils.USER_LOG_PATH)
+	}
+}
+
+// getAttemptUsername cuts the username someone tried to log in with down to
+// what the login_attempts table can hold. Real usernames are much shorter,
+// so this only changes names that can't log in anyway.
+func getAttemptUsername(username string) string {
+	runes := []rune(username)
+	if len(runes) > MAX_ATTEMPT_USERNAME_LENGTH {
+		return string(runes[:MAX_ATTEMPT_USERNAME_LENGTH])
+	}
+	return username
+}
+
+// getClientIp gets the IP address the request came from.
+func getClientIp(request *http.Request) string {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+	return host
+}
+
+// getRetryMessage builds up the message telling the user when to try again.
+func getRetryMessage(wait time.Duration) string {
+	if wait < time.Minute {
+		seconds := int((wait + time.Second - 1) / time.Second)
+		if seconds == 1 {
+			return "Too many failed login attempts. Please try again in 1 second."
+		}
+		return "Too many failed login attempts. Please try again in " + strconv.Itoa(seconds) + " seconds."
+	}
+
+	minutes := int((wait + time.Minute - 1) / time.Minute)
+	if minutes == 1 {
+		return "Too many failed login attempts. Please try again in 1 minute."
+	}
+	return "Too many failed login attempts. Please try again in " + strconv.Itoa(minutes) + " minutes."
+}
@@ -21,6 +21,7 @@ const (
 type User struct {
 	Username string
 	UserId   int
+	IsGuest  bool
 }
 
 var UNKNOWN_USER = &User{Username: "", UserId: -1}
@@ -36,12 +37,30 @@ func (user *User) IsValidUser() bool {
 // TODO: method should just use form values instead of being passed entire http request.
 func UserSignUp(request *http.Request) (bool, string) {
 	username := request.FormValue(USERNAME_KEY)
-	user := lookupUserByUsername(username)
-	if user.IsValidUser() {
-		// If this is a valid user (not the UNKNOWN user), then the user already exists
-		return true, "Username " + username + " already exists!"
+	hasError, errorMessage := checkUsername(username)
+	if hasError {
+		return hasError, errorMessage
+	}
+
+	password := request.FormValue(PASSWORD_KEY)
+	repeatPassword := request.FormValue(REPEAT_PASSWORD_KEY)
+	hasError, errorMessage = checkPassword(password, repeatPassword)
+	if hasError {
+		return hasError, errorMessage
 	}
 
+	err := persistUser(username, password)
+	if err != nil {
+		utils.LogMessage("Error persisting user: "+err.Error(), utils.USER_LOG_PATH)
+		return true, "Error creating user"
+	}
+
+	return false, ""
+}
+
+// checkUsername performs basic validation on a username that someone wants
+// to take. Returns if there is an error and the corresponding error message
+func checkUsername(username string) (bool, string) {
 	if len(username) < 3 {
 		return true, "Username must be at least 3 characters long."
 	}
@@ -50,8 +69,32 @@ func UserSignUp(request *http.Request) (bool, string) {
 		return true, "Username must be at most 10 characters long."
 	}
 
-	password := request.FormValue(PASSWORD_KEY)
-	repeatPassword := request.FormValue(REPEAT_PASSWORD_KEY)
+	for _, character := range username {
+		if !isUsernameCharacter(character) {
+			return true, "Username can only contain letters, numbers, - and _."
+		}
+	}
+
+	user := LookupUserByUsername(username)
+	if user.IsValidUser() {
+		// If this is a valid user (not the UNKNOWN user), then the user already exists
+		return true, "Username " + username + " already exists!"
+	}
+
+	return false, ""
+}
+
+// isUsernameCharacter determines whether the character is allowed in a username.
+func isUsernameCharacter(character rune) bool {
+	return (character >= 'a' && character <= 'z') ||
+		(character >= 'A' && character <= 'Z') ||
+		(character >= '0' && character <= '9') ||
+		character == '-' || character == '_'
+}
+
+// checkPassword performs basic validation on a new password. Returns if
+// there is an error and the corresponding error message
+func checkPassword(password string, repeatPassword string) (bool, string) {
 	if password != repeatPassword {
 		return true, "Passwords do not match"
 	}
@@ -64,24 +107,26 @@ func UserSignUp(request *http.Request) (bool, string) {
 		return true, "Password must be at most 30 characters long."
 	}
 
-	err := persistUser(username, password)
-	if err != nil {
-		utils.LogMessage("Error persisting user: "+err.Error(), utils.USER_LOG_PATH)
-		return true, "Error creating user"
-	}
-
 	return false, ""
 }
 
-// ValidateUserCookie validates a user given the cookies from a request.
+// ValidateUserCookie validates a user given the cookies from a request by
+// looking up the session the session cookie points to.
 func ValidateUserCookie(requestCookies []*http.Cookie) *User {
-	if len(requestCookies) == 0 {
+	cookie := findSessionCookie(requestCookies)
+	if cookie == nil {
 		return UNKNOWN_USER
 	}
 
-	cookie := requestCookies[0]
-	user := lookupUserByCookie(cookie)
+	user := lookupUserBySessionToken(hashSessionToken(cookie.Value))
 	if user.IsValidUser() {
+		if user.IsGuest {
+			// Keep track of guest activity so inactive guests can be cleaned up
+			err := touchUser(user.UserId)
+			if err != nil {
+				utils.LogMessage("Error updating guest activity: "+err.Error(), utils.USER_LOG_PATH)
+			}
+		}
 		return user
 	}
 
@@ -90,30 +135,34 @@ func ValidateUserCookie(requestCookies []*http.Cookie) *User {
 
 // ValidateUser is the entry point for the login handler. It validates the
 // user credentials (it assumes cookie validation failed already) and if
-// they provide valid credentials, then creates and cookie and returns it.
-func ValidateUser(request *http.Request) (*http.Cookie, bool) {
+// they provide valid credentials, then starts a new session and returns
+// its cookie. Usernames and IP addresses with too many failed attempts
+// have to wait before trying again. Returns the cookie, whether the user
+// was valid and an error message to show if not.
+func ValidateUser(request *http.Request) (*http.Cookie, bool, string) {
 	if len(request.Form) > 0 {
 		username := request.FormValue(USERNAME_KEY)
 		password := request.FormValue(PASSWORD_KEY)
-		utils.LogMessage("will validate user:"+username+" with password:"+password, utils.USER_LOG_PATH)
+		ipAddress := getClientIp(request)
+
+		wait := loginRetryWait(username, ipAddress)
+		if wait > 0 {
+			utils.LogMessage("Throttled login for user:"+username+" from "+ipAddress, utils.USER_LOG_PATH)
+			recordLoginAttempt(username, ipAddress, false, true)
+			return nil, false, getRetryMessage(wait)
+		}
+
+		utils.LogMessage("will validate user:"+username, utils.USER_LOG_PATH)
 		id, validUser := validateUserCredentials(username, password)
+		recordLoginAttempt(username, ipAddress, validUser, false)
 		if validUser {
-			cookie := generateNewCookie(username)
-			utils.LogMessage("cookie created: "+cookie.String(), utils.USER_LOG_PATH)
-			err := storeCookie(id, cookie)
+			cookie, err := createSession(id, request)
 			if err != nil {
-				utils.LogMessage("Error storing cookie"+err.Error(), utils.USER_LOG_PATH)
+				utils.LogMessage("Error creating session: "+err.Error(), utils.USER_LOG_PATH)
+				return nil, false, "Error logging in."
 			}
-			return cookie, true
+			return cookie, true, ""
 		}
 	}
-	return nil, false
-}
-
-// generatenewCookie creates new cookies for users without one.
-// TODO: have a better cookie generation strategy than using the username -_-
-func generateNewCookie(username string) *http.Cookie {
-	cookie := &http.Cookie{Name: COOKIE_NAME, Value: username}
-	utils.LogMessage("Creating a new cookie "+cookie.String(), utils.USER_LOG_PATH)
-	return cookie
+	return nil, false, "Username and password did not match."
 }
@@ -2,31 +2,91 @@ package users
 
 import (
 	"database/sql"
-	"net/http"
 	"resistance/utils"
 	"strconv"
+	"sync"
+	"time"
 )
 
 const (
 	PERSIST_USER_QUERY       = "insert into users (`username`, `password`) values (?, ?)"
-	PERSIST_COOKIE_QUERY     = "update users set cookie = ? where user_id = ?"
-	CREDENTIALS_QUERY        = "select user_id from users where username = ? and password = ?"
+	PERSIST_SESSION_QUERY    = "insert into sessions (`user_id`, `token_hash`, `csrf_token`, `expiry_date`) values (?, ?, ?, DATE_ADD(NOW(), INTERVAL ? SECOND))"
+	LOOKUP_CSRF_TOKEN_QUERY  = "select csrf_token from sessions where token_hash = ? and expiry_date > NOW()"
+	DELETE_SESSION_QUERY     = "delete from sessions where token_hash = ?"
+	DELETE_EXPIRED_QUERY     = "delete from sessions where user_id = ? and expiry_date <= NOW()"
+	DELETE_OTHER_SESSIONS    = "delete from sessions where user_id = ? and token_hash <> ?"
+	DELETE_ALL_SESSIONS      = "delete from sessions where user_id = ?"
+	CREDENTIALS_QUERY        = "select user_id from users where username = ? and password = ? and deleted = 0 and is_guest = 0 and is_bot = 0"
+	UPDATE_PASSWORD_QUERY    = "update users set password = ? where user_id = ?"
+	UPDATE_USERNAME_QUERY    = "update users set username = ? where user_id = ?"
+	ANONYMIZE_USER_QUERY     = "update users set username = ?, password = '', deleted = 1 where user_id = ?"
 	LOOKUP_BY_USERNAME_QUERY = "select user_id from users where username = ?"
 	LOOKUP_BY_USERID_QUERY   = "select username from users where user_id = ?"
-	LOOKUP_BY_COOKIE_QUERY   = "select user_id, username from users where cookie = ?"
+	LOOKUP_BY_SESSION_QUERY  = "select users.user_id, users.username, users.is_guest from sessions join users on users.user_id = sessions.user_id where sessions.token_hash = ? and sessions.expiry_date > NOW()"
 )
 
-var db *sql.DB
+const (
+	PERSIST_GUEST_QUERY = "insert into users (`username`, `password`, `is_guest`) values (?, '', 1)"
+	CONVERT_GUEST_QUERY = "update users set username = ?, password = ?, is_guest = 0 where user_id = ? and is_guest = 1"
+	TOUCH_USER_QUERY    = "update users set last_active = NOW() where user_id = ?"
+	// Inactive guests that never played a game can go away completely
+	DELETE_INACTIVE_GUESTS_QUERY = "delete from users where is_guest = 1 and deleted = 0 and " +
+		"last_active < date_sub(now(), interval ? second) and " +
+		"user_id not in (select user_id from players)"
+	// The rest are anonymized the same way as anonymizeUser so their games stay intact
+	ANONYMIZE_INACTIVE_GUESTS_QUERY = "update users set username = concat('[deleted ', user_id, ']'), password = '', deleted = 1 " +
+		"where is_guest = 1 and deleted = 0 and last_active < date_sub(now(), interval ? second)"
+	DELETE_ORPHANED_SESSIONS_QUERY = "delete from sessions where user_id not in (select user_id from users where deleted = 0)"
+)
+
+const (
+	PERSIST_LOGIN_ATTEMPT_QUERY = "insert into login_attempts (`username`, `ip_address`, `success`, `throttled`) values (?, ?, ?, ?)"
+	// Failed attempts for a username only count since its last successful login.
+	// Without one the fallback has to be a DATETIME too, or greatest won't
+	// compare them as dates.
+	FAILED_LOGINS_BY_USERNAME_QUERY = "select count(*), coalesce(unix_timestamp(max(attempt_date)), 0), unix_timestamp(now()) " +
+		"from login_attempts where username = ? and success = 0 and throttled = 0 and attempt_date > greatest(" +
+		"date_sub(now(), interval ? second), " +
+		"coalesce((select max(attempt_date) from login_attempts where username = ? and success = 1), cast('1970-01-01' as datetime)))"
+	// Failed attempts for an IP address don't reset on success, otherwise logging
+	// into your own account would reset the count.
+	FAILED_LOGINS_BY_IP_QUERY = "select count(*), coalesce(unix_timestamp(max(attempt_date)), 0), unix_timestamp(now()) " +
+		"from login_attempts where ip_address = ? and success = 0 and throttled = 0 and attempt_date > date_sub(now(), interval ? second)"
+)
+
+const (
+	PERSIST_API_TOKEN_QUERY   = "insert into api_tokens (`user_id`, `name`, `token_hash`) values (?, ?, ?)"
+	LIST_API_TOKENS_QUERY     = "select token_id, name, creation_date, last_used from api_tokens where user_id = ? order by token_id"
+	DELETE_API_TOKEN_QUERY    = "delete from api_tokens where user_id = ? and token_id = ?"
+	DELETE_ALL_API_TOKENS     = "delete from api_tokens where user_id = ?"
+	TOUCH_API_TOKEN_QUERY     = "update api_tokens set last_used = NOW() where token_hash = ?"
+	LOOKUP_BY_API_TOKEN_QUERY = "select users.user_id, users.username, users.is_guest from api_tokens join users on users.user_id = api_tokens.user_id where api_tokens.token_hash = ? and users.deleted = 0"
+)
+
+const (
+	PERSIST_BOT_QUERY      = "insert into users (`username`, `password`, `is_bot`) values (?, '', 1)"
+	LOOKUP_BOT_BY_USERNAME = "select user_id from users where username = ? and is_bot = 1"
+)
+
+var (
+	db     *sql.DB
+	dbOnce sync.Once
+)
 
-func init() {
-	db = utils.ConnectToDB()
+// getDB connects to the DB the first time it is needed, so programs that
+// only ever have users in memory, like the simulator, don't need one.
+func getDB() *sql.DB {
+	dbOnce.Do(func() {
+		db = utils.ConnectToDB()
+	})
+	return db
 }
 
 // lookupUserById looks up the user in the DB based on the given id.
 func LookupUserById(id int) *User {
 	user := UNKNOWN_USER
 	var username string
-	err := db.QueryRow(LOOKUP_BY_USERID_QUERY, id).Scan(&username)
+	err := getDB().QueryRow(LOOKUP_BY_USERID_QUERY, id).Scan(&username)
 	switch {
 	case err == sql.ErrNoRows:
 		utils.LogMessage("Warning: No user found for id: "+strconv.Itoa(id), utils.USER_LOG_PATH)
@@ -43,11 +103,11 @@ func LookupUserById(id int) *User {
 	return user
 }
 
-// lookupUserByUsername looks up the user in the DB based on the given username.
-func lookupUserByUsername(username string) *User {
+// LookupUserByUsername looks up the user in the DB based on the given username.
+func LookupUserByUsername(username string) *User {
 	user := UNKNOWN_USER
 	var id int
-	err := db.QueryRow(LOOKUP_BY_USERNAME_QUERY, username).Scan(&id)
+	err := getDB().QueryRow(LOOKUP_BY_USERNAME_QUERY, username).Scan(&id)
 	switch {
 	case err == sql.ErrNoRows:
 		utils.LogMessage("Warning: No user found for id: "+strconv.Itoa(id), utils.USER_LOG_PATH)
@@ -64,15 +124,17 @@ func lookupUserByUsername(username string) *User {
 	return user
 }
 
-// lookupUserByCookie looks up the user in the DB based on the given cookie.
-func lookupUserByCookie(cookie *http.Cookie) *User {
+// lookupUserBySessionToken looks up the user in the DB based on the hash of
+// the session token. Expired sessions do not match.
+func lookupUserBySessionToken(tokenHash string) *User {
 	user := UNKNOWN_USER
 	var id int
 	var username string
-	err := db.QueryRow(LOOKUP_BY_COOKIE_QUERY, cookie.Value).Scan(&id, &username)
+	var isGuest bool
+	err := getDB().QueryRow(LOOKUP_BY_SESSION_QUERY, tokenHash).Scan(&id, &username, &isGuest)
 	switch {
 	case err == sql.ErrNoRows:
-		utils.LogMessage("Warning: No user found for id: "+strconv.Itoa(id), utils.USER_LOG_PATH)
+		utils.LogMessage("Warning: No active session found", utils.USER_LOG_PATH)
 		user = UNKNOWN_USER
 	case err != nil:
 		utils.LogMessage("Error while looking up user: "+err.Error(), utils.USER_LOG_PATH)
@@ -80,36 +142,250 @@ func lookupUserByCookie(cookie *http.Cookie) *User {
 		user = new(User)
 		user.UserId = id
 		user.Username = username
+		user.IsGuest = isGuest
 		utils.LogMessage("Found a User! "+user.Username, utils.USER_LOG_PATH)
 	}
 
 	return user
 }
 
+// lookupUserByAPIToken looks up the user in the DB based on the hash of an
+// API token. Tokens of deleted users do not match.
+func lookupUserByAPIToken(tokenHash string) *User {
+	user := UNKNOWN_USER
+	var id int
+	var username string
+	var isGuest bool
+	err := getDB().QueryRow(LOOKUP_BY_API_TOKEN_QUERY, tokenHash).Scan(&id, &username, &isGuest)
+	switch {
+	case err == sql.ErrNoRows:
+		utils.LogMessage("Warning: No API token found", utils.USER_LOG_PATH)
+		user = UNKNOWN_USER
+	case err != nil:
+		utils.LogMessage("Error while looking up user: "+err.Error(), utils.USER_LOG_PATH)
+	default:
+		user = new(User)
+		user.UserId = id
+		user.Username = username
+		user.IsGuest = isGuest
+	}
+
+	return user
+}
+
+// lookupBotByUsername looks up the bot user with the given username.
+func lookupBotByUsername(username string) *User {
+	var id int
+	err := getDB().QueryRow(LOOKUP_BOT_BY_USERNAME, username).Scan(&id)
+	switch {
+	case err == sql.ErrNoRows:
+		return UNKNOWN_USER
+	case err != nil:
+		utils.LogMessage("Error while looking up bot: "+err.Error(), utils.USER_LOG_PATH)
+		return UNKNOWN_USER
+	}
+
+	return &User{Username: username, UserId: id}
+}
+
+// persistBot stores a new bot user in the DB and returns its user id.
+func persistBot(username string) (int, error) {
+	result, err := getDB().Exec(PERSIST_BOT_QUERY, username)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
 // persistUser stores the user in the DB, effectively completing registration
 // of a user.
 func persistUser(username string, password string) error {
-	_, err := db.Exec(PERSIST_USER_QUERY, username, password)
+	_, err := getDB().Exec(PERSIST_USER_QUERY, username, password)
+	return err
+}
+
+// persistGuest stores a new guest user in the DB and returns its user id.
+func persistGuest(username string) (int, error) {
+	result, err := getDB().Exec(PERSIST_GUEST_QUERY, username)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// convertGuest turns the given guest user id into a full user.
+func convertGuest(id int, username string, password string) error {
+	_, err := getDB().Exec(CONVERT_GUEST_QUERY, username, password, id)
+	return err
+}
+
+// touchUser marks the given user id as active right now.
+func touchUser(id int) error {
+	_, err := getDB().Exec(TOUCH_USER_QUERY, id)
+	return err
+}
+
+// removeInactiveGuests deletes or anonymizes guests who have not been active
+// for the given duration, and ends their sessions.
+func removeInactiveGuests(inactivity time.Duration) error {
+	_, err := getDB().Exec(DELETE_INACTIVE_GUESTS_QUERY, int(inactivity.Seconds()))
+	if err != nil {
+		return err
+	}
+	_, err = getDB().Exec(ANONYMIZE_INACTIVE_GUESTS_QUERY, int(inactivity.Seconds()))
+	if err != nil {
+		return err
+	}
+	_, err = getDB().Exec(DELETE_ORPHANED_SESSIONS_QUERY)
+	return err
+}
+
+// updatePassword changes the password of the given user id.
+func updatePassword(id int, password string) error {
+	_, err := getDB().Exec(UPDATE_PASSWORD_QUERY, password, id)
+	return err
+}
+
+// updateUsername changes the username of the given user id.
+func updateUsername(id int, username string) error {
+	_, err := getDB().Exec(UPDATE_USERNAME_QUERY, username, id)
+	return err
+}
+
+// anonymizeUser removes everything identifying from the given user id
+// and marks the user as deleted so they can no longer log in.
+func anonymizeUser(id int) error {
+	_, err := getDB().Exec(ANONYMIZE_USER_QUERY, anonymizedUsername(id), id)
 	return err
 }
 
 // validateUserCredentials validates the given username and password combination.
 func validateUserCredentials(user string, pass string) (int, bool) {
 	var id int
-	err := db.QueryRow(CREDENTIALS_QUERY, user, pass).Scan(&id)
+	err := getDB().QueryRow(CREDENTIALS_QUERY, user, pass).Scan(&id)
 	switch {
 	case err == sql.ErrNoRows:
-		utils.LogMessage("Login failed for username: "+user+" using password: "+pass, utils.USER_LOG_PATH)
+		utils.LogMessage("Login failed for username: "+user, utils.USER_LOG_PATH)
 		return 0, false
 	case err != nil:
 		utils.LogMessage("Error while looking up user: "+err.Error(), utils.USER_LOG_PATH)
+		return 0, false
 	default:
 	}
 	return id, true
 }
 
-// storeCookie stores the cookie in the DB for the given user id.
-func storeCookie(id int, cookie *http.Cookie) error {
-	_, err := db.Exec(PERSIST_COOKIE_QUERY, cookie.Value, id)
+// persistSession stores a new session in the DB for the given user id,
+// clearing out any of the user's sessions that have already expired.
+func persistSession(id int, tokenHash string, csrfToken string, duration time.Duration) error {
+	_, err := getDB().Exec(DELETE_EXPIRED_QUERY, id)
+	if err != nil {
+		return err
+	}
+	_, err = getDB().Exec(PERSIST_SESSION_QUERY, id, tokenHash, csrfToken, int(duration.Seconds()))
+	return err
+}
+
+// lookupCSRFToken looks up the CSRF token of the session with the given
+// token hash. Returns an empty string if there is no such session.
+func lookupCSRFToken(tokenHash string) string {
+	var csrfToken string
+	err := getDB().QueryRow(LOOKUP_CSRF_TOKEN_QUERY, tokenHash).Scan(&csrfToken)
+	if err != nil && err != sql.ErrNoRows {
+		utils.LogMessage("Error while looking up CSRF token: "+err.Error(), utils.USER_LOG_PATH)
+	}
+	return csrfToken
+}
+
+// deleteSession removes the session with the given token hash from the DB.
+func deleteSession(tokenHash string) error {
+	_, err := getDB().Exec(DELETE_SESSION_QUERY, tokenHash)
+	return err
+}
+
+// deleteOtherSessions removes all the sessions of the given user id except
+// the one with the given token hash.
+func deleteOtherSessions(id int, tokenHash string) error {
+	_, err := getDB().Exec(DELETE_OTHER_SESSIONS, id, tokenHash)
+	return err
+}
+
+// deleteAllSessions removes all the sessions of the given user id.
+func deleteAllSessions(id int) error {
+	_, err := getDB().Exec(DELETE_ALL_SESSIONS, id)
+	return err
+}
+
+// persistLoginAttempt stores a login attempt in the DB.
+func persistLoginAttempt(username string, ipAddress string, success bool, throttled bool) error {
+	_, err := getDB().Exec(PERSIST_LOGIN_ATTEMPT_QUERY, username, ipAddress, success, throttled)
+	return err
+}
+
+// countFailedLoginsByUsername counts the recent failed logins for the given
+// username. Also returns the unix time of the last failure and the current
+// unix time according to the DB.
+func countFailedLoginsByUsername(username string, window time.Duration) (int, int64, int64, error) {
+	var failures int
+	var lastFailure, now int64
+	err := getDB().QueryRow(FAILED_LOGINS_BY_USERNAME_QUERY, username, int(window.Seconds()), username).Scan(&failures, &lastFailure, &now)
+	return failures, lastFailure, now, err
+}
+
+// countFailedLoginsByIp counts the recent failed logins from the given IP
+// address. Also returns the unix time of the last failure and the current
+// unix time according to the DB.
+func countFailedLoginsByIp(ipAddress string, window time.Duration) (int, int64, int64, error) {
+	var failures int
+	var lastFailure, now int64
+	err := getDB().QueryRow(FAILED_LOGINS_BY_IP_QUERY, ipAddress, int(window.Seconds())).Scan(&failures, &lastFailure, &now)
+	return failures, lastFailure, now, err
+}
+
+// persistAPIToken stores a new API token in the DB for the given user id.
+func persistAPIToken(id int, name string, tokenHash string) error {
+	_, err := getDB().Exec(PERSIST_API_TOKEN_QUERY, id, name, tokenHash)
+	return err
+}
+
+// listAPITokens gets the API tokens of the given user id, oldest first.
+func listAPITokens(id int) ([]APIToken, error) {
+	tokens := make([]APIToken, 0)
+	rows, err := getDB().Query(LIST_API_TOKENS_QUERY, id)
+	if err != nil {
+		return tokens, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var token APIToken
+		var lastUsed sql.NullString
+		err = rows.Scan(&token.TokenId, &token.Name, &token.CreationDate, &lastUsed)
+		if err != nil {
+			return tokens, err
+		}
+		token.LastUsed = lastUsed.String
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// touchAPIToken marks the API token with the given hash as used right now.
+func touchAPIToken(tokenHash string) error {
+	_, err := getDB().Exec(TOUCH_API_TOKEN_QUERY, tokenHash)
+	return err
+}
+
+// deleteAPIToken removes the given API token of the given user id.
+func deleteAPIToken(id int, tokenId int) error {
+	_, err := getDB().Exec(DELETE_API_TOKEN_QUERY, id, tokenId)
+	return err
+}
+
+// deleteAllAPITokens removes all the API tokens of the given user id.
+func deleteAllAPITokens(id int) error {
+	_, err := getDB().Exec(DELETE_ALL_API_TOKENS, id)
 	return err
 }
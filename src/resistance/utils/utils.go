@@ -10,6 +10,7 @@ import (
 )
 
 const (
+	HTTP_PORT           = "8080"
 	WSP_PORT            = "8081"
 	GAME_REP_REQ_PORT   = "8082"
 	GAME_PUB_SUB_PORT   = "8083"
@@ -20,6 +21,10 @@ const (
 	RWSP_LOG_PATH       = "logs/rWSP.log"
 )
 
+// Origins, as scheme://host[:port], besides our own HTTP server that are
+// allowed to open a websocket to the proxy.
+var ALLOWED_ORIGINS = []string{}
+
 // createLogger creates a logger that will log to the given file
 func createLogger(filename string) (*log.Logger, *os.File, error) {
 	logFile, err := os.OpenFile(filename, os.O_RDWR|os.O_APPEND, 0666)
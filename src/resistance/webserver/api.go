@@ -0,0 +1,253 @@
+package webserver
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"resistance/game"
+	"resistance/rpc"
+	"resistance/users"
+	"resistance/utils"
+	"strconv"
+	"strings"
+)
+
+const (
+	API_PATH = "/api/v1/"
+
+	// Request bodies are small, anything bigger is a mistake or worse
+	MAX_API_BODY_BYTES = 1 << 16
+)
+
+type apiGameSummary struct {
+	GameId int    `json:"gameId"`
+	Title  string `json:"title"`
+	Host   string `json:"host"`
+	Ranked bool   `json:"ranked"`
+}
+
+type apiUser struct {
+	UserId            int    `json:"userId"`
+	Username          string `json:"username"`
+	GamesPlayed       int    `json:"gamesPlayed"`
+	Wins              int    `json:"wins"`
+	GamesAsResistance int    `json:"gamesAsResistance"`
+	WinsAsResistance  int    `json:"winsAsResistance"`
+	GamesAsSpy        int    `json:"gamesAsSpy"`
+	WinsAsSpy         int    `json:"winsAsSpy"`
+}
+
+type apiCreateGameRequest struct {
+	Title    string `json:"title"`
+	Ranked   bool   `json:"ranked"`
+	Private  bool   `json:"private"`
+	Password string `json:"password"`
+}
+
+type apiJoinGameRequest struct {
+	InviteCode string `json:"inviteCode"`
+	Password   string `json:"password"`
+}
+
+// apiHandler serves everything under /api/v1. Callers are either logged
+// in, or send an API token from their account page as a Bearer token.
+func apiHandler(writer http.ResponseWriter, request *http.Request) {
+	utils.LogMessage(request.URL.Path+" was requested", utils.RHTTP_LOG_PATH)
+
+	user := users.ValidateRequest(request)
+	if !user.IsValidUser() {
+		writer.Header().Set("WWW-Authenticate", users.API_TOKEN_CREDENTIAL)
+		writeAPIError(writer, http.StatusUnauthorized, "You need to be logged in or send an API token.")
+		return
+	}
+
+	// Scripts on our own pages are logged in with the session cookie, so
+	// anything they change has to carry the CSRF token. A page on some
+	// other site can't send an API token, so callers using one don't.
+	if request.Method != "GET" && users.GetBearerToken(request) == "" && !users.ValidateCSRFHeader(request) {
+		writeAPIError(writer, http.StatusForbidden, INVALID_FORM_MESSAGE)
+		return
+	}
+
+	path := strings.Split(strings.Trim(strings.TrimPrefix(request.URL.Path, API_PATH), "/"), "/")
+	var method string
+	var handler func()
+	switch {
+	case len(path) == 1 && path[0] == "games" && request.Method == "POST":
+		method, handler = "POST", func() { apiCreateGame(writer, request) }
+	case len(path) == 1 && path[0] == "games":
+		method, handler = "GET", func() { apiListGames(writer) }
+	case len(path) == 2 && path[0] == "games":
+		method, handler = "GET", func() { apiGetGame(writer, request, path[1]) }
+	case len(path) == 3 && path[0] == "games" && path[2] == "history":
+		method, handler = "GET", func() { apiGetGameHistory(writer, request, path[1]) }
+	case len(path) == 3 && path[0] == "games" && path[2] == "join":
+		method, handler = "POST", func() { apiJoinGame(writer, request, path[1]) }
+	case len(path) == 1 && path[0] == "me":
+		method, handler = "GET", func() { apiGetUser(writer, user.Username) }
+	case len(path) == 2 && path[0] == "users":
+		method, handler = "GET", func() { apiGetUser(writer, path[1]) }
+	default:
+		writeAPIError(writer, http.StatusNotFound, "Not found.")
+		return
+	}
+
+	if request.Method != method {
+		writeAPIError(writer, http.StatusMethodNotAllowed, "Method not allowed.")
+		return
+	}
+	handler()
+}
+
+// apiListGames lists the games waiting in the lobby.
+func apiListGames(writer http.ResponseWriter) {
+	reply, err := backend.GetAllGames(&rpc.GetAllGamesRequest{})
+	if err != nil {
+		writeBackendError(writer, err, http.StatusBadRequest)
+		return
+	}
+
+	games := make([]apiGameSummary, 0)
+	for _, summary := range reply.Games {
+		games = append(games, apiGameSummary{
+			GameId: summary.GameId,
+			Title:  summary.Title,
+			Host:   summary.HostUsername,
+			Ranked: summary.Ranked})
+	}
+	writeAPIResponse(writer, http.StatusOK, map[string]interface{}{"games": games})
+}
+
+// apiCreateGame creates a new game hosted by the caller.
+func apiCreateGame(writer http.ResponseWriter, request *http.Request) {
+	var createRequest apiCreateGameRequest
+	err := json.NewDecoder(http.MaxBytesReader(writer, request.Body, MAX_API_BODY_BYTES)).Decode(&createRequest)
+	if err != nil {
+		writeAPIError(writer, http.StatusBadRequest, "Request body is not valid JSON.")
+		return
+	}
+
+	err = game.ValidateTitle(createRequest.Title)
+	if err != nil {
+		writeAPIError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = game.ValidateGamePassword(createRequest.Password)
+	if err != nil {
+		writeAPIError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reply, err := backend.CreateGame(&rpc.CreateGameRequest{
+		Title:      createRequest.Title,
+		Ranked:     createRequest.Ranked,
+		Private:    createRequest.Private,
+		Password:   createRequest.Password,
+		UserCookie: users.GetCredential(request)})
+	if err != nil {
+		writeBackendError(writer, err, http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]interface{}{"gameId": reply.GameId}
+	if reply.InviteCode != "" {
+		response["inviteCode"] = reply.InviteCode
+	}
+	writeAPIResponse(writer, http.StatusCreated, response)
+}
+
+// apiGetGame gets the state of a game as anyone not playing it sees it.
+func apiGetGame(writer http.ResponseWriter, request *http.Request, gameId string) {
+	reply, err := backend.GetGame(&rpc.GetGameRequest{
+		GameId:     gameId,
+		UserCookie: users.GetCredential(request)})
+	if err != nil {
+		writeBackendError(writer, err, http.StatusNotFound)
+		return
+	}
+	writeAPIResponse(writer, http.StatusOK, reply.State)
+}
+
+// apiGetGameHistory gets every team proposed and mission played in a game.
+func apiGetGameHistory(writer http.ResponseWriter, request *http.Request, gameId string) {
+	reply, err := backend.GetGame(&rpc.GetGameRequest{
+		GameId:     gameId,
+		UserCookie: users.GetCredential(request)})
+	if err != nil {
+		writeBackendError(writer, err, http.StatusNotFound)
+		return
+	}
+	writeAPIResponse(writer, http.StatusOK, reply.History)
+}
+
+// apiJoinGame checks that the caller can join the game. The seat itself is
+// taken by connecting to the websocket proxy with the same credentials and
+// sending playerConnect, as the game page does. Private games need their
+// invite code or password in the body.
+func apiJoinGame(writer http.ResponseWriter, request *http.Request, gameId string) {
+	var joinRequest apiJoinGameRequest
+	err := json.NewDecoder(http.MaxBytesReader(writer, request.Body, MAX_API_BODY_BYTES)).Decode(&joinRequest)
+	if err != nil && err != io.EOF {
+		writeAPIError(writer, http.StatusBadRequest, "Request body is not valid JSON.")
+		return
+	}
+
+	reply, err := backend.IsValidGame(&rpc.IsValidGameRequest{
+		GameId:     gameId,
+		InviteCode: joinRequest.InviteCode,
+		Password:   joinRequest.Password,
+		UserCookie: users.GetCredential(request)})
+	if err != nil {
+		writeBackendError(writer, err, http.StatusBadRequest)
+		return
+	}
+
+	parsedGameId, _ := strconv.Atoi(gameId)
+	writeAPIResponse(writer, http.StatusOK, map[string]interface{}{
+		"gameId": parsedGameId,
+		"title":  reply.GameTitle})
+}
+
+// apiGetUser gets a user's profile and record.
+func apiGetUser(writer http.ResponseWriter, username string) {
+	reply, err := backend.GetUserStats(&rpc.GetUserStatsRequest{Username: username})
+	if err != nil {
+		writeBackendError(writer, err, http.StatusNotFound)
+		return
+	}
+
+	writeAPIResponse(writer, http.StatusOK, &apiUser{
+		UserId:            reply.UserId,
+		Username:          reply.Username,
+		GamesPlayed:       reply.GamesPlayed,
+		Wins:              reply.Wins,
+		GamesAsResistance: reply.GamesAsResistance,
+		WinsAsResistance:  reply.WinsAsResistance,
+		GamesAsSpy:        reply.GamesAsSpy,
+		WinsAsSpy:         reply.WinsAsSpy})
+}
+
+// writeBackendError answers with the error from the game server. Errors the
+// game server replied with are the caller's fault and get the given status,
+// anything else means we couldn't reach it.
+func writeBackendError(writer http.ResponseWriter, err error, status int) {
+	if _, ok := err.(*rpc.Error); !ok {
+		utils.LogMessage("Error calling the game server: "+err.Error(), utils.RHTTP_LOG_PATH)
+		status = http.StatusBadGateway
+	}
+	writeAPIError(writer, status, err.Error())
+}
+
+func writeAPIError(writer http.ResponseWriter, status int, message string) {
+	writeAPIResponse(writer, status, map[string]interface{}{"error": message})
+}
+
+func writeAPIResponse(writer http.ResponseWriter, status int, response interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	err := json.NewEncoder(writer).Encode(response)
+	if err != nil {
+		utils.LogMessage("Error writing API response: "+err.Error(), utils.RHTTP_LOG_PATH)
+	}
+}
@@ -0,0 +1,212 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"resistance/protocol"
+	"resistance/pubsub"
+	"resistance/rpc"
+	"resistance/users"
+	"resistance/utils"
+	"strconv"
+	"time"
+)
+
+const (
+	// Comments are sent this often so proxies don't time out an idle stream.
+	EVENTS_HEARTBEAT_INTERVAL = 15 * time.Second
+)
+
+// eventsHandler streams the messages published to a game as Server-Sent
+// Events, for players whose websocket doesn't get through. Opening the
+// stream connects the player to the game, closing it disconnects them.
+// Spectators ask for spectate=true and get the game's spectator topic.
+func eventsHandler(writer http.ResponseWriter, request *http.Request) {
+	utils.LogMessage(request.URL.Path+" was requested", utils.RHTTP_LOG_PATH)
+
+	user := users.ValidateUserCookie(request.Cookies())
+	if !user.IsValidUser() {
+		http.Error(writer, "You need to be logged in.", http.StatusUnauthorized)
+		return
+	}
+
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		http.Error(writer, "Streaming not supported.", http.StatusInternalServerError)
+		return
+	}
+
+	gameId, err := strconv.Atoi(request.FormValue(protocol.GAME_ID_KEY))
+	if err != nil {
+		http.Error(writer, "Game Id is not valid.", http.StatusBadRequest)
+		return
+	}
+	gameIdString := strconv.Itoa(gameId)
+
+	spectate := request.FormValue(protocol.SPECTATE_KEY) == "true"
+	connectMessage := protocol.PLAYER_CONNECT_MESSAGE
+	if spectate {
+		connectMessage = protocol.SPECTATE_MESSAGE
+	}
+
+	// Subscribe before connecting so the messages about this player
+	// joining aren't missed.
+	subscriptions := make([]pubsub.Subscription, 0)
+	defer func() {
+		for _, subscription := range subscriptions {
+			subscription.Close()
+		}
+	}()
+	for _, topic := range pubsub.GetTopics(gameIdString, user.UserId, spectate) {
+		subscription, err := subscriber.Subscribe(topic)
+		if err != nil {
+			utils.LogMessage("Error subscribing to "+topic+": "+err.Error(), utils.RHTTP_LOG_PATH)
+			http.Error(writer, err.Error(), http.StatusBadGateway)
+			return
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	// The page says which protocol version it speaks in the query string,
+	// pass it on so the game server can turn it away if need be
+	protocolVersion, _ := strconv.Atoi(request.FormValue(protocol.PROTOCOL_VERSION_KEY))
+	payload, _ := json.Marshal(map[string]interface{}{
+		protocol.MESSAGE_KEY:          connectMessage,
+		protocol.GAME_ID_KEY:          gameIdString,
+		protocol.PROTOCOL_VERSION_KEY: protocolVersion})
+	userCookie := getUserCookie(request)
+	reply, err := backend.SendClientMessage(&rpc.ClientMessageRequest{
+		UserCookie: userCookie,
+		Payload:    payload})
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if !reply.AcceptUser {
+		// An EventSource can't read the body of an error, so a protocol
+		// error goes out as the only event of the stream instead
+		var parsedReply map[string]interface{}
+		json.Unmarshal(reply.Payload, &parsedReply)
+		if parsedReply[protocol.MESSAGE_KEY] == protocol.PROTOCOL_ERROR_MESSAGE {
+			writer.Header().Set("Content-Type", "text/event-stream")
+			writeEvent(writer, reply.Payload)
+			return
+		}
+		http.Error(writer, "Cannot join this game.", http.StatusForbidden)
+		return
+	}
+	defer func() {
+		_, err := backend.PlayerDisconnect(&rpc.PlayerDisconnectRequest{
+			GameId:     gameIdString,
+			Spectate:   spectate,
+			UserCookie: userCookie})
+		if err != nil {
+			utils.LogMessage("Could not send message to game backend:"+err.Error(), utils.RHTTP_LOG_PATH)
+		}
+	}()
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	// Stops nginx from buffering the stream
+	writer.Header().Set("X-Accel-Buffering", "no")
+
+	writeEvent(writer, reply.Payload)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(EVENTS_HEARTBEAT_INTERVAL)
+	defer heartbeat.Stop()
+
+	// Every subscription feeds the one stream, which ends as soon as any
+	// of them is closed
+	messages := make(chan []byte)
+	closed := make(chan pubsub.Subscription, len(subscriptions))
+	for _, subscription := range subscriptions {
+		go func(subscription pubsub.Subscription) {
+			for message := range subscription.Messages() {
+				select {
+				case messages <- message:
+				case <-request.Context().Done():
+					return
+				}
+			}
+			closed <- subscription
+		}(subscription)
+	}
+
+	for {
+		select {
+		case message := <-messages:
+			writeEvent(writer, message)
+		case subscription := <-closed:
+			// The player will reconnect by themselves and catch up
+			if err := subscription.Err(); err != nil {
+				utils.LogMessage("Event stream for game "+gameIdString+" cut off: "+err.Error(), utils.RHTTP_LOG_PATH)
+			}
+			return
+		case <-heartbeat.C:
+			writer.Write([]byte(": ping\n\n"))
+		case <-request.Context().Done():
+			utils.LogMessage("Event stream for game "+gameIdString+" closed", utils.RHTTP_LOG_PATH)
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// actionHandler takes the messages players on the event stream would
+// otherwise send over the websocket, and replies with the game server's
+// answer.
+func actionHandler(writer http.ResponseWriter, request *http.Request) {
+	utils.LogMessage(request.URL.Path+" was requested", utils.RHTTP_LOG_PATH)
+
+	if request.Method != "POST" {
+		http.Error(writer, "Method not allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := users.ValidateUserCookie(request.Cookies())
+	if !user.IsValidUser() {
+		http.Error(writer, "You need to be logged in.", http.StatusUnauthorized)
+		return
+	}
+
+	if !users.ValidateCSRFToken(request) {
+		http.Error(writer, INVALID_FORM_MESSAGE, http.StatusForbidden)
+		return
+	}
+
+	payload := []byte(request.PostFormValue(protocol.MESSAGE_KEY))
+	var parsedMessage map[string]interface{}
+	err := json.Unmarshal(payload, &parsedMessage)
+	if err != nil {
+		http.Error(writer, "Message is not valid.", http.StatusBadRequest)
+		return
+	}
+
+	// Connecting goes through the event stream, so a connection is always
+	// matched by a disconnect.
+	if parsedMessage[protocol.MESSAGE_KEY] == protocol.PLAYER_CONNECT_MESSAGE ||
+		parsedMessage[protocol.MESSAGE_KEY] == protocol.SPECTATE_MESSAGE {
+		http.Error(writer, "Connect through the event stream.", http.StatusBadRequest)
+		return
+	}
+
+	reply, err := backend.SendClientMessage(&rpc.ClientMessageRequest{
+		UserCookie: getUserCookie(request),
+		Payload:    payload})
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(reply.Payload)
+}
+
+// writeEvent writes a message as a single event. The messages are JSON
+// without newlines, so they always fit on one data line.
+func writeEvent(writer http.ResponseWriter, message []byte) {
+	writer.Write([]byte("data: "))
+	writer.Write(message)
+	writer.Write([]byte("\n\n"))
+}
@@ -0,0 +1,464 @@
+package webserver
+
+import (
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"resistance/game"
+	"resistance/pubsub"
+	"resistance/rpc"
+	"resistance/users"
+	"resistance/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TEMPLATE_PATH        = "src/resistance/frontend"
+	INDEX_TEMPLATE       = "index.html"
+	LOGIN_TEMPLATE       = "login.html"
+	SIGNUP_TEMPLATE      = "signup.html"
+	HOME_TEMPLATE        = "home.html"
+	CREATE_GAME_TEMPLATE = "create.html"
+	LOBBY_TEMPLATE       = "lobby.html"
+	HISTORY_TEMPLATE     = "history.html"
+	GAME_TEMPLATE        = "game.html"
+	GUEST_TEMPLATE       = "guest.html"
+	ACCOUNT_TEMPLATE     = "account.html"
+	JOIN_TEMPLATE        = "join.html"
+)
+
+const (
+	TITLE_KEY    = "title"
+	RANKED_KEY   = "ranked"
+	PRIVATE_KEY  = "private"
+	PASSWORD_KEY = "password"
+	INVITE_KEY   = "invite"
+	SPECTATE_KEY = "spectate"
+	ACTION_KEY   = "action"
+	NEXT_KEY     = "next"
+)
+
+const (
+	INVALID_FORM_MESSAGE = "This form has expired. Please try again."
+)
+
+const (
+	CHANGE_USERNAME_ACTION  = "changeUsername"
+	CHANGE_PASSWORD_ACTION  = "changePassword"
+	DELETE_ACCOUNT_ACTION   = "deleteAccount"
+	CONVERT_GUEST_ACTION    = "convertGuest"
+	CREATE_API_TOKEN_ACTION = "createApiToken"
+	REVOKE_API_TOKEN_ACTION = "revokeApiToken"
+)
+
+const (
+	GUEST_COLLECTION_INTERVAL = time.Hour
+)
+
+var (
+	backend    *rpc.Client
+	subscriber pubsub.Subscriber
+)
+
+func faviconHandler(writer http.ResponseWriter, request *http.Request) {
+	// no-op
+}
+
+func indexHandler(writer http.ResponseWriter, request *http.Request) {
+	utils.LogMessage(request.URL.Path+" was requested", utils.RHTTP_LOG_PATH)
+
+	// If this person has a valid cookie, send them to their homepage
+	user := users.ValidateUserCookie(request.Cookies())
+	if user.IsValidUser() {
+		utils.LogMessage("Valid User, redirecting to /home.html", utils.RHTTP_LOG_PATH)
+		http.Redirect(writer, request, "/home.html", 302)
+		return
+	}
+
+	renderTemplate(writer, INDEX_TEMPLATE, make(map[string]string))
+}
+
+func loginHandler(writer http.ResponseWriter, request *http.Request) {
+	utils.LogMessage(request.URL.Path+" was requested", utils.RHTTP_LOG_PATH)
+
+	// If this person has a valid cookie, send them to their homepage instead
+	user := users.ValidateUserCookie(request.Cookies())
+	if user.IsValidUser() {
+		utils.LogMessage("Valid User, redirecting to "+getNextPage(request), utils.RHTTP_LOG_PATH)
+		http.Redirect(writer, request, getNextPage(request), 302)
+		return
+	}
+
+	loginInfo := make(map[string]string)
+	loginInfo["Next"] = getNextPage(request)
+
+	err := request.ParseForm()
+	if err != nil {
+		utils.LogMessage("Error parsing form values", utils.RHTTP_LOG_PATH)
+	} else if request.Method == "POST" && !users.ValidateCSRFToken(request) {
+		loginInfo["Error"] = INVALID_FORM_MESSAGE
+	} else if request.Method == "POST" {
+		cookie, validUser, errorMessage := users.ValidateUser(request)
+		if validUser {
+			http.SetCookie(writer, cookie)
+			http.Redirect(writer, request, getNextPage(request), 302)
+			return
+		} else {
+			loginInfo["Error"] = errorMessage
+		}
+	}
+
+	loginInfo["CSRFToken"] = users.GetCSRFToken(writer, request)
+	renderTemplate(writer, LOGIN_TEMPLATE, loginInfo)
+}
+
+func guestHandler(writer http.ResponseWriter, request *http.Request) {
+	utils.LogMessage(request.URL.Path+" was requested", utils.RHTTP_LOG_PATH)
+
+	// Anyone already logged in (guest or not) can go straight on
+	user := users.ValidateUserCookie(request.Cookies())
+	if user.IsValidUser() {
+		http.Redirect(writer, request, getNextPage(request), 302)
+		return
+	}
+
+	guestInfo := make(map[string]string)
+	guestInfo["Next"] = getNextPage(request)
+
+	err := request.ParseForm()
+	if err != nil {
+		utils.LogMessage("Error parsing form values", utils.RHTTP_LOG_PATH)
+	} else if request.Method == "POST" && !users.ValidateCSRFToken(request) {
+		guestInfo["Error"] = INVALID_FORM_MESSAGE
+	} else if request.Method == "POST" {
+		cookie, hasGuestError, errorMessage := users.GuestSignIn(request)
+		if hasGuestError {
+			guestInfo["Error"] = errorMessage
+		} else {
+			http.SetCookie(writer, cookie)
+			http.Redirect(writer, request, getNextPage(request), 302)
+			return
+		}
+	}
+
+	guestInfo["CSRFToken"] = users.GetCSRFToken(writer, request)
+	renderTemplate(writer, GUEST_TEMPLATE, guestInfo)
+}
+
+func logoutHandler(writer http.ResponseWriter, request *http.Request) {
+	utils.LogMessage(request.URL.Path+" was requested", utils.RHTTP_LOG_PATH)
+
+	users.InvalidateSession(request.Cookies())
+	http.SetCookie(writer, users.ExpiredSessionCookie(request))
+	http.Redirect(writer, request, "/", 302)
+}
+
+func signupHandler(writer http.ResponseWriter, request *http.Request) {
+	utils.LogMessage(request.URL.Path+" was requested", utils.RHTTP_LOG_PATH)
+
+	signUpInfo := make(map[string]string)
+
+	err := request.ParseForm()
+	if err != nil {
+		utils.LogMessage("Error parsing form values", utils.RHTTP_LOG_PATH)
+	} else if request.Method == "POST" && !users.ValidateCSRFToken(request) {
+		signUpInfo["Error"] = INVALID_FORM_MESSAGE
+	} else if request.Method == "POST" {
+		hasSignUpError, errorMessage := users.UserSignUp(request)
+		if hasSignUpError {
+			signUpInfo["Error"] = errorMessage
+		} else {
+			// TODO: redirect to login page with success message
+			http.Redirect(writer, request, "/login.html", 302)
+			return
+		}
+	}
+
+	signUpInfo["CSRFToken"] = users.GetCSRFToken(writer, request)
+	renderTemplate(writer, SIGNUP_TEMPLATE, signUpInfo)
+}
+
+func homeHandler(writer http.ResponseWriter, request *http.Request) {
+	utils.LogMessage(request.URL.Path+" was requested", utils.RHTTP_LOG_PATH)
+
+	user := requiresLogin(writer, request)
+
+	renderTemplate(writer, HOME_TEMPLATE, user)
+}
+
+func accountHandler(writer http.ResponseWriter, request *http.Request) {
+	utils.LogMessage(request.URL.Path+" was requested", utils.RHTTP_LOG_PATH)
+
+	user := requiresLogin(writer, request)
+	if !user.IsValidUser() {
+		return
+	}
+
+	accountInfo := make(map[string]interface{})
+	err := request.ParseForm()
+	if err != nil {
+		utils.LogMessage("Error parsing form values", utils.RHTTP_LOG_PATH)
+	} else if request.Method == "POST" && !users.ValidateCSRFToken(request) {
+		accountInfo["Error"] = INVALID_FORM_MESSAGE
+	} else if request.Method == "POST" {
+		var hasError bool
+		var errorMessage string
+		var successMessage string
+		switch request.FormValue(ACTION_KEY) {
+		case CHANGE_USERNAME_ACTION:
+			hasError, errorMessage = users.ChangeUsername(user, request)
+			successMessage = "Your username has been changed."
+		case CHANGE_PASSWORD_ACTION:
+			hasError, errorMessage = users.ChangePassword(user, request)
+			successMessage = "Your password has been changed."
+		case CONVERT_GUEST_ACTION:
+			hasError, errorMessage = users.ConvertGuest(user, request)
+			successMessage = "Your account has been created."
+		case CREATE_API_TOKEN_ACTION:
+			var token string
+			token, hasError, errorMessage = users.CreateAPIToken(user, request)
+			// Only shown this once, we just keep a hash of it
+			accountInfo["NewAPIToken"] = token
+			successMessage = "Your token has been created. Copy it now, it won't be shown again."
+		case REVOKE_API_TOKEN_ACTION:
+			hasError, errorMessage = users.RevokeAPIToken(user, request)
+			successMessage = "Your token has been revoked."
+		case DELETE_ACCOUNT_ACTION:
+			hasError, errorMessage = users.DeleteAccount(user, request)
+			if !hasError {
+				http.SetCookie(writer, users.ExpiredSessionCookie(request))
+				http.Redirect(writer, request, "/", 302)
+				return
+			}
+		}
+
+		if hasError {
+			accountInfo["Error"] = errorMessage
+		} else {
+			accountInfo["Success"] = successMessage
+		}
+	}
+
+	accountInfo["Username"] = user.Username
+	accountInfo["IsGuest"] = user.IsGuest
+	if !user.IsGuest {
+		accountInfo["APITokens"] = users.GetAPITokens(user)
+	}
+	accountInfo["CSRFToken"] = users.GetCSRFToken(writer, request)
+	renderTemplate(writer, ACCOUNT_TEMPLATE, accountInfo)
+}
+
+func createGameHandler(writer http.ResponseWriter, request *http.Request) {
+	utils.LogMessage(request.URL.Path+" was requested", utils.RHTTP_LOG_PATH)
+
+	user := requiresLogin(writer, request)
+	if !user.IsValidUser() {
+		return
+	}
+
+	createInfo := make(map[string]interface{})
+	createInfo["Username"] = user.Username
+
+	err := request.ParseForm()
+	if err != nil {
+		utils.LogMessage("Error parsing form values", utils.RHTTP_LOG_PATH)
+	} else if request.Method == "POST" {
+		title := request.PostFormValue(TITLE_KEY)
+		private := request.PostFormValue(PRIVATE_KEY) != ""
+		password := ""
+		if private {
+			password = request.PostFormValue(PASSWORD_KEY)
+		}
+		if !users.ValidateCSRFToken(request) {
+			createInfo["Error"] = INVALID_FORM_MESSAGE
+		} else if err := game.ValidateTitle(title); err != nil {
+			createInfo["Error"] = err.Error()
+		} else if err := game.ValidateGamePassword(password); err != nil {
+			createInfo["Error"] = err.Error()
+		} else {
+			// The host is whoever the session belongs to, the backend
+			// works that out from the cookie.
+			reply, err := backend.CreateGame(&rpc.CreateGameRequest{
+				Title:      title,
+				Ranked:     request.PostFormValue(RANKED_KEY) != "",
+				Private:    private,
+				Password:   password,
+				UserCookie: getUserCookie(request)})
+			if err == nil && reply.GameId > 0 {
+				http.Redirect(writer, request, "/game.html?gameId="+strconv.Itoa(reply.GameId), 302)
+				return
+			} else if err != nil {
+				createInfo["Error"] = err.Error()
+			} else {
+				createInfo["Error"] = "Error creating game"
+			}
+		}
+	}
+
+	createInfo["CSRFToken"] = users.GetCSRFToken(writer, request)
+	renderTemplate(writer, CREATE_GAME_TEMPLATE, createInfo)
+}
+
+func lobbyHandler(writer http.ResponseWriter, request *http.Request) {
+	utils.LogMessage(request.URL.Path+" was requested", utils.RHTTP_LOG_PATH)
+
+	user := requiresLogin(writer, request)
+
+	if user.IsValidUser() {
+		reply, err := backend.GetAllGames(&rpc.GetAllGamesRequest{})
+		if err != nil {
+			utils.LogMessage("Error getting all games: "+err.Error(), utils.RHTTP_LOG_PATH)
+		}
+		renderTemplate(writer, LOBBY_TEMPLATE, reply)
+	}
+}
+
+func historyHandler(writer http.ResponseWriter, request *http.Request) {
+	utils.LogMessage(request.URL.Path+" was requested", utils.RHTTP_LOG_PATH)
+
+	user := requiresLogin(writer, request)
+
+	renderTemplate(writer, HISTORY_TEMPLATE, user)
+}
+
+func gameHandler(writer http.ResponseWriter, request *http.Request) {
+	utils.LogMessage(request.URL.Path+" was requested", utils.RHTTP_LOG_PATH)
+
+	user := requiresLogin(writer, request)
+
+	if user.IsValidUser() {
+		err := request.ParseForm()
+		if err != nil {
+			utils.LogMessage(err.Error(), utils.RHTTP_LOG_PATH)
+		} else if len(request.Form) > 0 {
+			// Anyone with a link with spectate=true can watch
+			spectate := request.FormValue(SPECTATE_KEY) == "true"
+			gameId := request.FormValue("gameId")
+
+			// The password of a private game is posted from the join page
+			password := ""
+			passwordPosted := request.Method == "POST" && users.ValidateCSRFToken(request)
+			if passwordPosted {
+				password = request.PostFormValue(PASSWORD_KEY)
+			}
+
+			reply, err := backend.IsValidGame(&rpc.IsValidGameRequest{
+				GameId:     gameId,
+				Spectate:   spectate,
+				InviteCode: request.FormValue(INVITE_KEY),
+				Password:   password,
+				UserCookie: getUserCookie(request)})
+			if err == nil && passwordPosted {
+				// The game server remembers who got in, so the password
+				// doesn't need to stay in the page
+				http.Redirect(writer, request, request.URL.RequestURI(), 302)
+			} else if err == nil {
+				utils.LogMessage(reply.GameTitle, utils.RESISTANCE_LOG_PATH)
+				gameInfo := make(map[string]interface{})
+				gameInfo["GameTitle"] = reply.GameTitle
+				gameInfo["GameId"] = gameId
+				gameInfo["Spectate"] = spectate
+				gameInfo["Private"] = reply.Private
+				// Needed to post actions when falling back to the event stream
+				gameInfo["CSRFToken"] = users.GetCSRFToken(writer, request)
+				renderTemplate(writer, GAME_TEMPLATE, gameInfo)
+			} else if err.Error() == game.ErrNotInvited.Error() {
+				joinInfo := make(map[string]interface{})
+				joinInfo["Error"] = err.Error()
+				if passwordPosted {
+					joinInfo["Error"] = "Wrong password."
+				}
+				joinInfo["CSRFToken"] = users.GetCSRFToken(writer, request)
+				renderTemplate(writer, JOIN_TEMPLATE, joinInfo)
+			} else {
+				// TODO: how do i redirect to home and pass in an error message?
+				writer.Write([]byte(err.Error()))
+			}
+		} else {
+			http.Redirect(writer, request, "/home.html", 302)
+		}
+	}
+}
+
+func renderTemplate(writer io.Writer, name string, parameters interface{}) {
+	filePath := filepath.Join(TEMPLATE_PATH, name)
+	templates := template.Must(template.ParseFiles(filePath))
+	templates.Execute(writer, parameters)
+}
+
+func requiresLogin(writer http.ResponseWriter, request *http.Request) *users.User {
+	// If this person has an invalid cookie, send them to the login page instead
+	user := users.ValidateUserCookie(request.Cookies())
+	if !user.IsValidUser() {
+		utils.LogMessage("Invalid User, redirecting to /login.html", utils.RHTTP_LOG_PATH)
+		http.Redirect(writer, request, "/login.html?"+NEXT_KEY+"="+url.QueryEscape(request.URL.RequestURI()), 302)
+	}
+	return user
+}
+
+// getNextPage gets the page to go to after logging in. Only pages on this
+// site are allowed, anything else goes to the homepage.
+func getNextPage(request *http.Request) string {
+	next := request.FormValue(NEXT_KEY)
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/home.html"
+	}
+	return next
+}
+
+// collectInactiveGuests is meant to run in the background. Periodically
+// cleans up the guest users that have stopped playing.
+func collectInactiveGuests() {
+	for {
+		users.CollectInactiveGuests()
+		time.Sleep(GUEST_COLLECTION_INTERVAL)
+	}
+}
+
+// getUserCookie gets the session cookie of the request in the form the
+// game backend expects it.
+func getUserCookie(request *http.Request) string {
+	cookie, err := request.Cookie(users.COOKIE_NAME)
+	if err != nil {
+		return ""
+	}
+	return cookie.Name + "=" + cookie.Value
+}
+
+// ListenAndServe serves the website on the given address, calling the
+// game server through the given client. The event streams get the messages
+// published to the games from the given subscriber.
+func ListenAndServe(address string, client *rpc.Client, gameSubscriber pubsub.Subscriber) error {
+	backend = client
+	subscriber = gameSubscriber
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", indexHandler)
+	mux.HandleFunc("/favicon.ico", faviconHandler)
+	mux.HandleFunc("/login.html", loginHandler)
+	mux.HandleFunc("/signup.html", signupHandler)
+	mux.HandleFunc("/guest.html", guestHandler)
+	mux.HandleFunc("/home.html", homeHandler)
+	mux.HandleFunc("/create.html", createGameHandler)
+	mux.HandleFunc("/lobby.html", lobbyHandler)
+	mux.HandleFunc("/history.html", historyHandler)
+	mux.HandleFunc("/game.html", gameHandler)
+	mux.HandleFunc("/logout.html", logoutHandler)
+	mux.HandleFunc("/account.html", accountHandler)
+	mux.HandleFunc("/events", eventsHandler)
+	mux.HandleFunc("/action", actionHandler)
+	mux.HandleFunc(API_PATH, apiHandler)
+	mux.Handle("/game.js", http.FileServer(http.Dir("src/resistance/frontend")))
+	mux.Handle("/protocol.js", http.FileServer(http.Dir("src/resistance/frontend")))
+	mux.Handle("/game.css", http.FileServer(http.Dir("src/resistance/frontend")))
+
+	go collectInactiveGuests()
+
+	utils.LogMessage("Starting TheResistance HTTP Server...", utils.RHTTP_LOG_PATH)
+
+	return http.ListenAndServe(address, mux)
+}
@@ -0,0 +1,122 @@
+package zmqtransport
+
+import (
+	zmq "github.com/alecthomas/gozmq"
+	"resistance/pubsub"
+	"resistance/utils"
+	"sync"
+	"time"
+)
+
+const (
+	// How long a subscription waits for a message before checking if it
+	// has been closed.
+	SUBSCRIPTION_POLL_TIMEOUT = 500 * time.Millisecond
+)
+
+// ZmqPublisher publishes messages on a ZMQ PUB socket. The topic is sent as
+// the first frame so subscribers can filter on it.
+type ZmqPublisher struct {
+	lock   sync.Mutex
+	socket *zmq.Socket
+}
+
+func NewZmqPublisher(socket *zmq.Socket) *ZmqPublisher {
+	return &ZmqPublisher{socket: socket}
+}
+
+func (publisher *ZmqPublisher) Publish(topic string, message []byte) error {
+	// ZMQ sockets can't be used from more than one go routine at once
+	publisher.lock.Lock()
+	defer publisher.lock.Unlock()
+
+	return publisher.socket.SendMultipart([][]byte{[]byte(topic), message}, 0)
+}
+
+// ZmqSubscriber subscribes to topics published on a ZMQ PUB socket.
+type ZmqSubscriber struct {
+	context *zmq.Context
+	address string
+}
+
+type zmqSubscription struct {
+	messages  chan []byte
+	done      chan bool
+	closeOnce sync.Once
+}
+
+func NewZmqSubscriber(context *zmq.Context, address string) *ZmqSubscriber {
+	return &ZmqSubscriber{context: context, address: address}
+}
+
+// Subscribe creates a SUB socket for the topic. The socket is owned by
+// the go routine receiving from it, which also closes it.
+func (subscriber *ZmqSubscriber) Subscribe(topic string) (pubsub.Subscription, error) {
+	socket, err := subscriber.context.NewSocket(zmq.SUB)
+	if err != nil {
+		return nil, err
+	}
+
+	err = socket.Connect(subscriber.address)
+	if err == nil {
+		err = socket.SetSockOptString(zmq.SUBSCRIBE, topic)
+	}
+	if err != nil {
+		socket.Close()
+		return nil, err
+	}
+	utils.LogMessage("SUBSCRIBER connected to "+subscriber.address+" with filter "+topic, utils.RESISTANCE_LOG_PATH)
+
+	subscription := &zmqSubscription{
+		messages: make(chan []byte, pubsub.DEFAULT_BUFFER_SIZE),
+		done:     make(chan bool)}
+	go subscription.receive(socket, topic)
+	return subscription, nil
+}
+
+// receive is meant to run in the background. Receives messages from the
+// socket until the subscription is closed.
+func (subscription *zmqSubscription) receive(socket *zmq.Socket, topic string) {
+	defer close(subscription.messages)
+	defer socket.Close()
+
+	pollItems := []zmq.PollItem{zmq.PollItem{Socket: socket, Events: zmq.POLLIN}}
+	for {
+		select {
+		case <-subscription.done:
+			return
+		default:
+		}
+
+		_, err := zmq.Poll(pollItems, SUBSCRIPTION_POLL_TIMEOUT)
+		if err != nil || pollItems[0].REvents&zmq.POLLIN == 0 {
+			continue
+		}
+
+		multiPartMessage, err := socket.RecvMultipart(0)
+		// ZMQ filters on prefixes, so game 1 also gets the messages of game 12
+		if err != nil || len(multiPartMessage) != 2 || string(multiPartMessage[0]) != topic {
+			continue
+		}
+
+		select {
+		case subscription.messages <- multiPartMessage[1]:
+		case <-subscription.done:
+			return
+		}
+	}
+}
+
+func (subscription *zmqSubscription) Messages() <-chan []byte {
+	return subscription.messages
+}
+
+func (subscription *zmqSubscription) Close() {
+	subscription.closeOnce.Do(func() {
+		close(subscription.done)
+	})
+}
+
+func (subscription *zmqSubscription) Err() error {
+	return nil
+}
@@ -0,0 +1,120 @@
+package zmqtransport
+
+import (
+	zmq "github.com/alecthomas/gozmq"
+	"resistance/rpc"
+	"resistance/utils"
+	"time"
+)
+
+const (
+	// How long the ROUTER socket waits for a request before checking for
+	// replies to send back.
+	ROUTER_POLL_TIMEOUT = 5 * time.Millisecond
+	REPLY_QUEUE_SIZE    = 64
+)
+
+// ZmqTransport carries requests to the game server over a ZMQ REQ socket.
+type ZmqTransport struct {
+	context *zmq.Context
+	address string
+}
+
+func NewZmqTransport(context *zmq.Context, address string) *ZmqTransport {
+	return &ZmqTransport{context: context, address: address}
+}
+
+// RoundTrip sends the request over a new REQ socket. A REQ socket that timed
+// out can't be used again, so every request gets its own socket.
+func (transport *ZmqTransport) RoundTrip(request []byte, timeout time.Duration) ([]byte, error) {
+	socket, err := transport.context.NewSocket(zmq.REQ)
+	if err != nil {
+		return nil, err
+	}
+	defer socket.Close()
+
+	// Don't hang on to unsent requests once we have given up on them
+	err = socket.SetLinger(0)
+	if err != nil {
+		return nil, err
+	}
+
+	err = socket.Connect(transport.address)
+	if err != nil {
+		return nil, err
+	}
+
+	err = socket.Send(request, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	pollItems := []zmq.PollItem{zmq.PollItem{Socket: socket, Events: zmq.POLLIN}}
+	count, err := zmq.Poll(pollItems, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		utils.LogMessage("Timed out waiting on "+transport.address, utils.RESISTANCE_LOG_PATH)
+		return nil, rpc.ErrTimeout
+	}
+
+	return socket.Recv(0)
+}
+
+// ServeZmq serves requests coming in on the given ROUTER socket forever.
+// The requests are handed to a dispatcher, so a slow request doesn't hold
+// up the others, and the replies are sent back as they are ready.
+func ServeZmq(socket *zmq.Socket, server *rpc.Server) {
+	dispatcher := rpc.NewDispatcher(server)
+
+	// Only this go routine may use the socket, so the replies are handed
+	// back to it to send.
+	replies := make(chan [][]byte, REPLY_QUEUE_SIZE)
+
+	pollItems := []zmq.PollItem{zmq.PollItem{Socket: socket, Events: zmq.POLLIN}}
+	for {
+		_, err := zmq.Poll(pollItems, ROUTER_POLL_TIMEOUT)
+		if err == nil && pollItems[0].REvents&zmq.POLLIN != 0 {
+			receiveRequest(socket, dispatcher, replies)
+		}
+
+		sendReplies(socket, replies)
+	}
+}
+
+// receiveRequest receives one request from the ROUTER socket and dispatches
+// it. The last frame is the request, everything before it is the envelope
+// needed to route the reply back to the REQ socket that sent it.
+func receiveRequest(socket *zmq.Socket, dispatcher *rpc.Dispatcher, replies chan [][]byte) {
+	multiPartMessage, err := socket.RecvMultipart(0)
+	if err != nil {
+		utils.LogMessage("Error receiving request: "+err.Error(), utils.RESISTANCE_LOG_PATH)
+		return
+	}
+	if len(multiPartMessage) < 2 {
+		utils.LogMessage("Received request without an envelope", utils.RESISTANCE_LOG_PATH)
+		return
+	}
+
+	envelope := multiPartMessage[:len(multiPartMessage)-1]
+	request := multiPartMessage[len(multiPartMessage)-1]
+	dispatcher.Dispatch(request, func(reply []byte) {
+		replies <- append(envelope, reply)
+	})
+}
+
+// sendReplies sends every reply that is ready.
+func sendReplies(socket *zmq.Socket, replies chan [][]byte) {
+	for {
+		select {
+		case reply := <-replies:
+			err := socket.SendMultipart(reply, 0)
+			if err != nil {
+				utils.LogMessage("Error sending reply: "+err.Error(), utils.RESISTANCE_LOG_PATH)
+			}
+		default:
+			return
+		}
+	}
+}